@@ -14,11 +14,14 @@ import (
 
 	"github.com/4aleksei/metricscum/cmd/server/migrate"
 	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/server/alerts"
 	"github.com/4aleksei/metricscum/internal/server/config"
 	grpcmetrics "github.com/4aleksei/metricscum/internal/server/grpcservice"
 	"github.com/4aleksei/metricscum/internal/server/handlers"
+	"github.com/4aleksei/metricscum/internal/server/keyring"
 	"github.com/4aleksei/metricscum/internal/server/resources"
 	"github.com/4aleksei/metricscum/internal/server/service"
+	"github.com/4aleksei/metricscum/internal/server/statsd"
 	"go.uber.org/zap"
 )
 
@@ -70,8 +73,51 @@ func run() error {
 		return errC
 	}
 
-	metricsService := service.NewHandlerStore(storageRes.Store)
-	server, errS := handlers.NewServer(metricsService, cfg, l)
+	var opts []service.Option
+	if storageRes.History != nil {
+		opts = append(opts, service.WithHistory(storageRes.History))
+	}
+	metricsService := service.NewHandlerStore(storageRes.Store, opts...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var serverOpts []handlers.Option
+	if cfg.RulesFile != "" {
+		rules, errR := alerts.LoadRules(cfg.RulesFile)
+		if errR != nil {
+			l.Error("Error load alerting rules:", zap.Error(errR))
+			return errR
+		}
+		var alertOpts []alerts.Option
+		if len(cfg.AlertWebhooks) > 0 {
+			notifier := alerts.NewNotifier(cfg.AlertWebhooks,
+				time.Duration(cfg.AlertRepeat)*time.Second, cfg.AlertGroupBy, cfg.Retry, l)
+			notifier.Run(ctx)
+			alertOpts = append(alertOpts, alerts.WithNotifier(notifier))
+		}
+		engine, errE := alerts.NewEngine(rules, metricsService, time.Duration(cfg.RulesInterval)*time.Second, l, alertOpts...)
+		if errE != nil {
+			l.Error("Error alerting rules:", zap.Error(errE))
+			return errE
+		}
+		engine.Run(ctx)
+		serverOpts = append(serverOpts, handlers.WithAlerts(engine))
+	}
+
+	keys, errK := keyring.Load(cfg.Key, cfg.KeyringFile)
+	if errK != nil {
+		l.Error("Error load keyring:", zap.Error(errK))
+		return errK
+	}
+	if cfg.KeyringFile != "" {
+		hups := make(chan os.Signal, 1)
+		signal.Notify(hups, syscall.SIGHUP)
+		keys.Watch(ctx, hups, l)
+	}
+	serverOpts = append(serverOpts, handlers.WithKeyring(keys))
+
+	server, errS := handlers.NewServer(metricsService, cfg, l, serverOpts...)
 	if errS != nil {
 		l.Error("Error server construct:", zap.Error(errS))
 		return errS
@@ -79,17 +125,29 @@ func run() error {
 
 	server.Serve()
 
-	grpcServ, errG := grpcmetrics.NewgPRC(metricsService, cfg, l)
+	grpcServ, errG := grpcmetrics.NewgPRC(metricsService, cfg, l, keys)
 	if errG != nil {
 		l.Error("Error server grpc construct:", zap.Error(errG))
 		return errG
 	}
 
+	var statsdServ *statsd.Server
+	if cfg.StatsdAddress != "" {
+		statsdServ, err = statsd.NewServer(metricsService, cfg.StatsdAddress,
+			time.Duration(cfg.StatsdFlush)*time.Second, cfg.StatsdBatch, l)
+		if err != nil {
+			l.Error("Error statsd listener construct:", zap.Error(err))
+			return err
+		}
+		statsdServ.Run(ctx)
+	}
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	sig := <-sigs
 
 	l.Info("Server is shutting down...", zap.String("signal", sig.String()))
+	cancel()
 
 	shutdownCtx, shutdownRelease := context.WithTimeout(context.Background(), time.Duration(defaultHTTPshutdown)*time.Second)
 	defer shutdownRelease()
@@ -102,6 +160,10 @@ func run() error {
 
 	grpcServ.StopServ()
 
+	if statsdServ != nil {
+		statsdServ.Stop()
+	}
+
 	errClose := storageRes.Close(context.Background())
 	if errClose != nil {
 		l.Error("Resources close error :", zap.Error(errClose))
@@ -0,0 +1,22 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() { //nolint:gochecknoinits // for goose migration
+	goose.AddMigrationContext(Up00003, Down00003)
+}
+
+func Up00003(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE metrics ADD COLUMN IF NOT EXISTS hist jsonb;`)
+	return err
+}
+
+func Down00003(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "ALTER TABLE metrics DROP COLUMN IF EXISTS hist;")
+	return err
+}
@@ -0,0 +1,34 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() { //nolint:gochecknoinits // for goose migration
+	goose.AddMigrationContext(Up00004, Down00004)
+}
+
+// Up00004 - labels become part of metric identity: name, labels, kind
+func Up00004(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels text not null DEFAULT '';
+		ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+		DROP INDEX IF EXISTS metrics_test_idx;
+		CREATE UNIQUE INDEX IF NOT EXISTS metrics_labels_idx ON metrics (name,labels,kind);
+	`)
+	return err
+}
+
+func Down00004(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM metrics WHERE labels <> '';
+		DROP INDEX IF EXISTS metrics_labels_idx;
+		ALTER TABLE metrics DROP COLUMN IF EXISTS labels;
+		ALTER TABLE metrics ADD PRIMARY KEY (name, kind);
+		CREATE UNIQUE INDEX IF NOT EXISTS metrics_test_idx ON metrics (name,kind);
+	`)
+	return err
+}
@@ -0,0 +1,32 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() { //nolint:gochecknoinits // for goose migration
+	goose.AddMigrationContext(Up00005, Down00005)
+}
+
+func Up00005(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS metrics_history (
+			name varchar(128) not null,
+			labels text not null DEFAULT '',
+			kind int4 not null,
+			delta bigint,
+			value double precision,
+			ts timestamptz not null DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS metrics_history_idx ON metrics_history (name,labels,kind,ts);
+	`)
+	return err
+}
+
+func Down00005(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS metrics_history;")
+	return err
+}
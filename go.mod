@@ -20,6 +20,7 @@ require (
 	golang.org/x/tools v0.34.0
 	google.golang.org/grpc v1.73.0
 	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 	honnef.co/go/tools v0.6.1
 )
 
@@ -62,5 +63,4 @@ require (
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -9,27 +9,42 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/4aleksei/metricscum/internal/agent/collector"
 	"github.com/4aleksei/metricscum/internal/agent/config"
 	"github.com/4aleksei/metricscum/internal/agent/gather"
+	"github.com/4aleksei/metricscum/internal/agent/gatherexec"
 	"github.com/4aleksei/metricscum/internal/agent/gatherps"
 	"github.com/4aleksei/metricscum/internal/agent/handlers"
 	"github.com/4aleksei/metricscum/internal/agent/poolclients"
+	"github.com/4aleksei/metricscum/internal/agent/probe"
+	"github.com/4aleksei/metricscum/internal/agent/pushapi"
 	"github.com/4aleksei/metricscum/internal/agent/service"
+	"github.com/4aleksei/metricscum/internal/agent/spool"
+	"github.com/4aleksei/metricscum/internal/agent/textfile"
 	"github.com/4aleksei/metricscum/internal/common/httpprof"
 	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/repository/aggstorage"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstoragemux"
 	"github.com/4aleksei/metricscum/internal/common/utils"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 )
 
+// newStore - gauges are last value, or last value with min, max and avg of report window
+func newStore(cfg *config.Config) service.AgentMetricsStorage {
+	if cfg.GaugeAggregate {
+		return aggstorage.NewStoreAgg()
+	}
+	return memstoragemux.NewStoreMux()
+}
+
 func registerSetLoggerLevel(ll *logger.Logger, cfg *config.Config, lc fx.Lifecycle) {
 	ll.SetLevel(cfg.Lcfg.Level)
 	lc.Append(utils.ToHook(ll))
 }
 
-func registerRunnersGather(gg *gather.AppGather, lc fx.Lifecycle) {
-	lc.Append(utils.ToHook(gg))
+func registerRunnersCollectors(ss *collector.Scheduler, lc fx.Lifecycle) {
+	lc.Append(utils.ToHook(ss))
 }
 
 func registerShutdowner(shutdowner fx.Shutdowner, ll *logger.Logger) {
@@ -44,20 +59,20 @@ func registerShutdowner(shutdowner fx.Shutdowner, ll *logger.Logger) {
 	}()
 }
 
-func registerRunnersGatherps(gg *gatherps.AppGatherMem, lc fx.Lifecycle) {
-	lc.Append(utils.ToHook(gg))
-}
-
 func registerRunnersSender(cc *handlers.App, lc fx.Lifecycle) {
 	lc.Append(utils.ToHook(cc))
 }
 
+func registerPushAPI(pp *pushapi.PushAPI, lc fx.Lifecycle) {
+	lc.Append(utils.ToHook(pp))
+}
+
 func registerHTTPprof(hh *httpprof.HTTPprof, lc fx.Lifecycle) {
 	lc.Append(utils.ToHook(hh))
 }
 
-// SetupFX - application constructor
-func SetupFX() *fx.App {
+// SetupFX - application constructor, opts - additional collectors (collector.Provide) and other fx options
+func SetupFX(opts ...fx.Option) *fx.App {
 	app := fx.New(
 		fx.Supply(logger.Config{Level: "debug"}),
 		fx.StopTimeout(1*time.Minute),
@@ -65,16 +80,28 @@ func SetupFX() *fx.App {
 			logger.NewLogger,
 			config.NewConfig,
 			httpprof.NewHTTPprof,
-			fx.Annotate(memstoragemux.NewStoreMux,
-				fx.As(new(service.AgentMetricsStorage))),
+			newStore,
+			spool.NewSpool,
 			service.NewHandlerStore,
-			gather.NewAppGather,
-			gatherps.NewGather,
-			poolclients.NewPoolClient,
+			collector.NewScheduler,
+			poolclients.NewPoolClients,
 			//httpclientpool.NewHandler,
 			handlers.NewApp,
+			pushapi.NewPushAPI,
 		),
 
+		collector.Provide(gather.NewRuntime),
+		collector.Provide(gatherps.NewPS),
+		collector.Provide(gatherps.NewDisk),
+		collector.Provide(gatherps.NewDiskIO),
+		collector.Provide(gatherps.NewNet),
+		collector.Provide(gatherps.NewLoad),
+		collector.Provide(gatherps.NewProcess),
+		collector.Provide(textfile.NewTextfile),
+		collector.Provide(gatherexec.NewExec),
+		collector.Provide(probe.NewProbe),
+		fx.Options(opts...),
+
 		fx.WithLogger(func(log *logger.Logger) fxevent.Logger {
 			return &fxevent.ZapLogger{Logger: log.L}
 		}),
@@ -82,8 +109,8 @@ func SetupFX() *fx.App {
 			registerSetLoggerLevel,
 			registerHTTPprof,
 			registerRunnersSender,
-			registerRunnersGather,
-			registerRunnersGatherps,
+			registerRunnersCollectors,
+			registerPushAPI,
 			registerShutdowner,
 		),
 	)
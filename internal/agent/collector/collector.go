@@ -0,0 +1,138 @@
+// Package collector - pluggable sources of agent metrics
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/agent/service"
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/utils"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Collector - source of metrics polled by scheduler
+type Collector interface {
+	// Name - unique name, key of collector settings in config
+	Name() string
+	// Interval - default poll interval, 0 - agent poll interval
+	Interval() time.Duration
+	Collect(ctx context.Context) ([]models.Metrics, error)
+}
+
+// GroupTag - fx value group of collectors
+const GroupTag = `group:"collectors"`
+
+// Provide - register collector constructor in fx value group
+func Provide(constructor any) fx.Option {
+	return fx.Provide(fx.Annotate(constructor,
+		fx.As(new(Collector)),
+		fx.ResultTags(GroupTag)))
+}
+
+// Gauge - gauge metric
+func Gauge(name string, v float64) models.Metrics {
+	return models.Metrics{ID: name, MType: "gauge", Value: &v}
+}
+
+// Counter - counter metric
+func Counter(name string, d int64) models.Metrics {
+	return models.Metrics{ID: name, MType: "counter", Delta: &d}
+}
+
+type Scheduler struct {
+	serv       *service.HandlerStore
+	l          *logger.Logger
+	cfg        *config.Config
+	collectors []Collector
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+type SchedulerParams struct {
+	fx.In
+
+	Serv       *service.HandlerStore
+	L          *logger.Logger
+	Cfg        *config.Config
+	Collectors []Collector `group:"collectors"`
+}
+
+func NewScheduler(p SchedulerParams) *Scheduler {
+	return &Scheduler{
+		serv:       p.Serv,
+		l:          p.L,
+		cfg:        p.Cfg,
+		collectors: p.Collectors,
+	}
+}
+
+// interval - config value, collector default or agent poll interval
+func (s *Scheduler) interval(c Collector) time.Duration {
+	if cc, ok := s.cfg.Collectors[c.Name()]; ok && cc.Interval > 0 {
+		return time.Duration(cc.Interval) * time.Second
+	}
+	if d := c.Interval(); d > 0 {
+		return d
+	}
+	return time.Duration(s.cfg.PollInterval) * time.Second
+}
+
+func (s *Scheduler) enabled(c Collector) bool {
+	if cc, ok := s.cfg.Collectors[c.Name()]; ok && cc.Enabled != nil {
+		return *cc.Enabled
+	}
+	return true
+}
+
+func (s *Scheduler) Start(ctx context.Context) error {
+	ctxCancel, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, c := range s.collectors {
+		if !s.enabled(c) {
+			s.l.L.Info("Collector disabled.", zap.String("name", c.Name()))
+			continue
+		}
+		s.wg.Add(1)
+		go s.run(ctxCancel, c, s.interval(c))
+	}
+	return nil
+}
+
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) run(ctx context.Context, c Collector, interval time.Duration) {
+	defer s.wg.Done()
+	s.l.L.Info("Start collector.", zap.String("name", c.Name()), zap.Duration("interval", interval))
+	for {
+		utils.SleepCancellable(ctx, interval)
+		select {
+		case <-ctx.Done():
+			s.l.L.Info("Stop collector.", zap.String("name", c.Name()))
+			return
+		default:
+			s.collect(ctx, c)
+		}
+	}
+}
+
+func (s *Scheduler) collect(ctx context.Context, c Collector) {
+	vals, err := c.Collect(ctx)
+	if err != nil {
+		s.l.L.Error("Collector error:", zap.String("name", c.Name()), zap.Error(err))
+	}
+	if len(vals) == 0 {
+		return
+	}
+	if _, err = s.serv.SetValueSModel(ctx, vals); err != nil {
+		s.l.L.Error("Collector store error:", zap.String("name", c.Name()), zap.Error(err))
+	}
+}
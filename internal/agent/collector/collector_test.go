@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/agent/service"
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/memstoragemux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+type fakeCollector struct {
+	err      error
+	name     string
+	interval time.Duration
+	calls    int
+	mux      sync.Mutex
+}
+
+func (f *fakeCollector) Name() string            { return f.name }
+func (f *fakeCollector) Interval() time.Duration { return f.interval }
+func (f *fakeCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.calls++
+	return []models.Metrics{Counter(f.name+"_calls", 1)}, f.err
+}
+
+func (f *fakeCollector) count() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.calls
+}
+
+func newTestScheduler(cfg *config.Config, cs ...Collector) (*Scheduler, *service.HandlerStore) {
+	l := logger.NewLogger(logger.Config{Level: "debug"})
+	serv := service.NewHandlerStore(memstoragemux.NewStoreMux(), nil, nil, cfg, l)
+	return NewScheduler(SchedulerParams{Serv: serv, L: l, Cfg: cfg, Collectors: cs}), serv
+}
+
+func Test_SchedulerSettings(t *testing.T) {
+	off := false
+	cfg := &config.Config{PollInterval: 2, Collectors: map[string]config.CollectorConfig{
+		"b": {Interval: 7},
+		"c": {Enabled: &off},
+	}}
+	a := &fakeCollector{name: "a"}
+	b := &fakeCollector{name: "b", interval: time.Second}
+	c := &fakeCollector{name: "c", interval: time.Second}
+	d := &fakeCollector{name: "d", interval: 3 * time.Second}
+	s, _ := newTestScheduler(cfg, a, b, c, d)
+
+	assert.Equal(t, 2*time.Second, s.interval(a), "agent poll interval")
+	assert.Equal(t, 7*time.Second, s.interval(b), "config overrides default")
+	assert.Equal(t, 3*time.Second, s.interval(d), "collector default")
+	assert.True(t, s.enabled(a))
+	assert.False(t, s.enabled(c))
+}
+
+func Test_SchedulerRun(t *testing.T) {
+	off := false
+	cfg := &config.Config{PollInterval: 1, Collectors: map[string]config.CollectorConfig{
+		"off": {Enabled: &off},
+	}}
+	fast := &fakeCollector{name: "fast", interval: 10 * time.Millisecond, err: errors.New("partial")}
+	disabled := &fakeCollector{name: "off", interval: 10 * time.Millisecond}
+	s, serv := newTestScheduler(cfg, fast, disabled)
+
+	require.NoError(t, s.Start(context.Background()))
+	assert.Eventually(t, func() bool { return fast.count() >= 3 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, s.Stop(context.Background()))
+	assert.Equal(t, 0, disabled.count())
+
+	var got []models.Metrics
+	require.NoError(t, serv.RangeMetricsJSONS(context.Background(), func(ctx context.Context, m []models.Metrics) error {
+		got = m
+		return nil
+	}))
+	require.Len(t, got, 1)
+	assert.Equal(t, "fast_calls", got[0].ID)
+	assert.Equal(t, int64(fast.count()), *got[0].Delta, "metrics of collector with error are stored")
+}
+
+func Test_Provide(t *testing.T) {
+	var got []Collector
+	app := fx.New(
+		fx.NopLogger,
+		Provide(func() *fakeCollector { return &fakeCollector{name: "one"} }),
+		Provide(func() *fakeCollector { return &fakeCollector{name: "two"} }),
+		fx.Invoke(func(p struct {
+			fx.In
+			Collectors []Collector `group:"collectors"`
+		}) {
+			got = p.Collectors
+		}),
+	)
+	require.NoError(t, app.Err())
+	names := make([]string, 0, len(got))
+	for _, c := range got {
+		names = append(names, c.Name())
+	}
+	assert.ElementsMatch(t, []string{"one", "two"}, names)
+}
@@ -3,43 +3,297 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/utils"
 	"go.uber.org/zap"
 )
 
 type Config struct {
-	Lcfg           *logger.Config
-	Address        string
-	Level          string
-	Key            string
-	PublicKeyFile  string
-	ReportInterval int64
-	PollInterval   int64
-	ContentBatch   int64
-	RateLimit      int64
-	ContentJSON    bool
-	ConfigJsonFile string
-	Grpc           bool
-	CertKeyFile    string
+	Lcfg            *logger.Config
+	Address         string
+	Level           string
+	Key             string
+	KeyID           string
+	PublicKeyFile   string
+	ReportInterval  int64
+	PollInterval    int64
+	ContentBatch    int64
+	RateLimit       int64
+	ContentJSON     bool
+	ConfigJsonFile  string
+	Grpc            bool
+	CertKeyFile     string // CA bundle of server certificate
+	ClientCertFile  string // client certificate of mutual TLS
+	ClientKeyFile   string
+	HTTPS           bool
+	SpoolDir        string
+	SpoolSegments   int64
+	PushAddress     string
+	Collectors      map[string]CollectorConfig
+	Processes       []ProcessMatch
+	TextfileDir     string
+	Exec            []ExecCommand
+	ExecTimeout     int64
+	ProbeHTTP       []string
+	ProbeTCP        []string
+	ProbeTimeout    int64
+	GaugeAggregate  bool
+	Destinations    []Destination
+	DestinationMode string
+	Retry           utils.RetryPolicy
 }
 
+// Destination modes
 const (
-	AddressDefault        string = ":8080"
-	ReportIntervalDefault int64  = 10
-	PollIntervalDefault   int64  = 2
-	LevelDefault          string = "info"
-	ContentJSONDefault    bool   = true
-	ContentBatchDefault   int64  = 0
-	KeyDefault            string = ""
-	RateLimitDefault      int64  = 10
-	ConfigDefaultJson     string = ""
-	PublicKeyDefault      string = ""
-	GrpcDefault           bool   = false
-	CertKeyFileDefault    string = ""
+	DestinationFanout   = "fanout"   // all metrics to every destination
+	DestinationFailover = "failover" // metrics to first healthy destination in list order
+)
+
+// Destination - server the agent reports to, nil fields are taken from main settings
+type Destination struct {
+	Address       string
+	Grpc          *bool
+	HTTPS         *bool
+	Key           *string
+	KeyID         *string
+	PublicKeyFile *string
+	CertKeyFile   *string
+	ContentBatch  *int64
+	RateLimit     *int64
+}
+
+// ParseDestination - address[,grpc=bool][,https=bool][,key=k][,key-id=id][,crypto-key=file][,crypto-cert=file][,batch=n][,rate-limit=n]
+func ParseDestination(s string) (Destination, error) {
+	var d Destination
+	items := strings.Split(strings.TrimSpace(s), ",")
+	d.Address = strings.TrimSpace(items[0])
+	if d.Address == "" || strings.Contains(d.Address, "=") {
+		return d, fmt.Errorf("destination %q: want address[,option=value]", s)
+	}
+	for _, item := range items[1:] {
+		name, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return d, fmt.Errorf("destination %q: want option=value, got %q", s, item)
+		}
+		switch name {
+		case "grpc", "https":
+			v, err := strconv.ParseBool(val)
+			if err != nil {
+				return d, fmt.Errorf("destination %q: %w", s, err)
+			}
+			if name == "grpc" {
+				d.Grpc = &v
+			} else {
+				d.HTTPS = &v
+			}
+		case "key":
+			d.Key = &val
+		case "key-id":
+			d.KeyID = &val
+		case "crypto-key":
+			d.PublicKeyFile = &val
+		case "crypto-cert":
+			d.CertKeyFile = &val
+		case "batch", "rate-limit":
+			v, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return d, fmt.Errorf("destination %q: %w", s, err)
+			}
+			if name == "batch" {
+				d.ContentBatch = &v
+			} else {
+				d.RateLimit = &v
+			}
+		default:
+			return d, fmt.Errorf("destination %q: unknown option %q", s, name)
+		}
+	}
+	return d, nil
+}
+
+// addDestinations - destinations separated by ';'
+func (cfg *Config) addDestinations(s string) error {
+	for _, spec := range strings.Split(s, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		d, err := ParseDestination(spec)
+		if err != nil {
+			return err
+		}
+		cfg.Destinations = append(cfg.Destinations, d)
+	}
+	return nil
+}
+
+// DestinationConfigs - settings of every destination, main settings without destinations list
+func (cfg *Config) DestinationConfigs() []*Config {
+	if len(cfg.Destinations) == 0 {
+		return []*Config{cfg}
+	}
+	res := make([]*Config, 0, len(cfg.Destinations))
+	for _, d := range cfg.Destinations {
+		c := *cfg
+		c.Destinations = nil
+		c.Address = d.Address
+		if d.Grpc != nil {
+			c.Grpc = *d.Grpc
+		}
+		if d.HTTPS != nil {
+			c.HTTPS = *d.HTTPS
+		}
+		if d.Key != nil {
+			c.Key = *d.Key
+		}
+		if d.KeyID != nil {
+			c.KeyID = *d.KeyID
+		}
+		if d.PublicKeyFile != nil {
+			c.PublicKeyFile = *d.PublicKeyFile
+		}
+		if d.CertKeyFile != nil {
+			c.CertKeyFile = *d.CertKeyFile
+		}
+		if d.ContentBatch != nil {
+			c.ContentBatch = *d.ContentBatch
+		}
+		if d.RateLimit != nil {
+			c.RateLimit = *d.RateLimit
+		}
+		if !c.ContentJSON {
+			c.ContentBatch = 0
+		}
+		if c.RateLimit <= 0 {
+			c.RateLimit = 1
+		}
+		res = append(res, &c)
+	}
+	return res
+}
+
+// ExecCommand - command run by exec collector, Name is value of label command
+type ExecCommand struct {
+	Name    string
+	Command []string
+}
+
+// ParseExecCommand - [name=]command args..., default name is base name of command
+func ParseExecCommand(s string) (ExecCommand, error) {
+	var c ExecCommand
+	spec := strings.TrimSpace(s)
+	if i := strings.IndexByte(spec, '='); i > 0 && !strings.ContainsAny(spec[:i], " /") {
+		c.Name, spec = spec[:i], spec[i+1:]
+	}
+	c.Command = strings.Fields(spec)
+	if len(c.Command) == 0 {
+		return c, fmt.Errorf("exec %q: want [name=]command args", s)
+	}
+	if c.Name == "" {
+		c.Name = filepath.Base(c.Command[0])
+	}
+	return c, nil
+}
+
+// addExec - commands separated by ';'
+func (cfg *Config) addExec(s string) error {
+	for _, spec := range strings.Split(s, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		c, err := ParseExecCommand(spec)
+		if err != nil {
+			return err
+		}
+		cfg.Exec = append(cfg.Exec, c)
+	}
+	return nil
+}
+
+// ProcessMatch - process watched by process collector, one of Name, Cmdline or Pidfile is set.
+// Label is value of label process, default is matcher value
+type ProcessMatch struct {
+	Label   string
+	Name    string
+	Cmdline string
+	Pidfile string
+}
+
+// ParseProcessMatch - [label=]name:value, [label=]cmdline:regexp or [label=]pidfile:path
+func ParseProcessMatch(s string) (ProcessMatch, error) {
+	var m ProcessMatch
+	spec := strings.TrimSpace(s)
+	if i := strings.IndexByte(spec, '='); i > 0 && !strings.Contains(spec[:i], ":") {
+		m.Label, spec = spec[:i], spec[i+1:]
+	}
+	kind, val, ok := strings.Cut(spec, ":")
+	if !ok || val == "" {
+		return m, fmt.Errorf("process %q: want [label=]name|cmdline|pidfile:value", s)
+	}
+	switch kind {
+	case "name":
+		m.Name = val
+	case "cmdline":
+		m.Cmdline = val
+	case "pidfile":
+		m.Pidfile = val
+	default:
+		return m, fmt.Errorf("process %q: want [label=]name|cmdline|pidfile:value", s)
+	}
+	return m, nil
+}
+
+// addProcesses - matchers separated by ';'
+func (cfg *Config) addProcesses(s string) error {
+	for _, spec := range strings.Split(s, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		m, err := ParseProcessMatch(spec)
+		if err != nil {
+			return err
+		}
+		cfg.Processes = append(cfg.Processes, m)
+	}
+	return nil
+}
+
+// CollectorConfig - settings of one collector, nil Enabled and zero Interval keep collector defaults
+type CollectorConfig struct {
+	Enabled  *bool
+	Interval int64
+}
+
+const (
+	AddressDefault         string = ":8080"
+	ReportIntervalDefault  int64  = 10
+	PollIntervalDefault    int64  = 2
+	LevelDefault           string = "info"
+	ContentJSONDefault     bool   = true
+	ContentBatchDefault    int64  = 0
+	KeyDefault             string = ""
+	KeyIDDefault           string = ""
+	RateLimitDefault       int64  = 10
+	ConfigDefaultJson      string = ""
+	PublicKeyDefault       string = ""
+	GrpcDefault            bool   = false
+	CertKeyFileDefault     string = ""
+	ClientCertFileDefault  string = ""
+	ClientKeyFileDefault   string = ""
+	HTTPSDefault           bool   = false
+	SpoolDirDefault        string = ""
+	SpoolSegmentsDefault   int64  = 1000
+	PushAddressDefault     string = ""
+	TextfileDirDefault     string = ""
+	ExecTimeoutDefault     int64  = 10
+	ProbeTimeoutDefault    int64  = 5
+	GaugeAggregateDefault  bool   = false
+	DestinationModeDefault string = DestinationFanout
 )
 
 func initDefaultCfg() *Config {
@@ -47,6 +301,7 @@ func initDefaultCfg() *Config {
 	cfg.Address = AddressDefault
 	cfg.Level = LevelDefault
 	cfg.Key = KeyDefault
+	cfg.KeyID = KeyIDDefault
 	cfg.ConfigJsonFile = ConfigDefaultJson
 
 	cfg.ReportInterval = ReportIntervalDefault
@@ -58,6 +313,18 @@ func initDefaultCfg() *Config {
 	cfg.PublicKeyFile = PublicKeyDefault
 	cfg.Grpc = GrpcDefault
 	cfg.CertKeyFile = CertKeyFileDefault
+	cfg.ClientCertFile = ClientCertFileDefault
+	cfg.ClientKeyFile = ClientKeyFileDefault
+	cfg.HTTPS = HTTPSDefault
+	cfg.SpoolDir = SpoolDirDefault
+	cfg.SpoolSegments = SpoolSegmentsDefault
+	cfg.PushAddress = PushAddressDefault
+	cfg.TextfileDir = TextfileDirDefault
+	cfg.ExecTimeout = ExecTimeoutDefault
+	cfg.ProbeTimeout = ProbeTimeoutDefault
+	cfg.GaugeAggregate = GaugeAggregateDefault
+	cfg.DestinationMode = DestinationModeDefault
+	cfg.Retry = utils.DefaultRetryPolicy()
 	return cfg
 }
 
@@ -76,6 +343,51 @@ func getJsonFileName(key string, cfg *Config) bool {
 	return true
 }
 
+// splitList - comma separated list without empty items
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (cfg *Config) collector(name string) CollectorConfig {
+	if cfg.Collectors == nil {
+		cfg.Collectors = make(map[string]CollectorConfig)
+	}
+	return cfg.Collectors[name]
+}
+
+// setCollectorsEnabled - enable or disable collectors by comma separated names
+func (cfg *Config) setCollectorsEnabled(s string, enabled bool) {
+	for _, name := range splitList(s) {
+		cc := cfg.collector(name)
+		cc.Enabled = &enabled
+		cfg.Collectors[name] = cc
+	}
+}
+
+// setCollectorsInterval - name=seconds,name2=seconds
+func (cfg *Config) setCollectorsInterval(s string) error {
+	for _, item := range splitList(s) {
+		name, val, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("collector interval %q: want name=seconds", item)
+		}
+		sec, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || sec <= 0 {
+			return fmt.Errorf("collector interval %q: want name=seconds", item)
+		}
+		cc := cfg.collector(name)
+		cc.Interval = sec
+		cfg.Collectors[name] = cc
+	}
+	return nil
+}
+
 func NewConfig(l *logger.Logger) (*Config, error) {
 	cfg := initDefaultCfg()
 
@@ -100,14 +412,81 @@ func NewConfig(l *logger.Logger) (*Config, error) {
 
 	flag.BoolVar(&cfg.Grpc, "g", cfg.Grpc, "gRPC client true/false")
 
+	flag.BoolVar(&cfg.GaugeAggregate, "gauge-aggregate", cfg.GaugeAggregate, "Report min, max and avg of gauges between reports true/false")
+
 	flag.Int64Var(&cfg.ContentBatch, "b", cfg.ContentBatch, "ContentBatch size uint")
 
 	flag.StringVar(&cfg.Key, "k", cfg.Key, "key for signature")
 
+	flag.StringVar(&cfg.KeyID, "key-id", cfg.KeyID, "id of signature key in server keyring")
+
+	destinationsFlag := false
+	flag.Func("destination", "Server address[,grpc=bool,https=bool,key=k,key-id=id,crypto-key=file,crypto-cert=file,batch=n,rate-limit=n], ';' separated, may be repeated", func(s string) error {
+		if !destinationsFlag {
+			// flags replace destinations of config file
+			cfg.Destinations = nil
+			destinationsFlag = true
+		}
+		return cfg.addDestinations(s)
+	})
+	flag.StringVar(&cfg.DestinationMode, "destination-mode", cfg.DestinationMode, "Destinations mode fanout/failover")
+
 	flag.Int64Var(&cfg.RateLimit, "l", cfg.RateLimit, "RateLimit, pool workers")
 
 	flag.StringVar(&cfg.PublicKeyFile, "crypto-key", cfg.PublicKeyFile, "Public key file name")
 	flag.StringVar(&cfg.CertKeyFile, "crypto-cert", cfg.CertKeyFile, "Public cert file name")
+	flag.StringVar(&cfg.ClientCertFile, "client-cert", cfg.ClientCertFile, "Client cert file name (pem) for mutual TLS")
+	flag.StringVar(&cfg.ClientKeyFile, "client-key", cfg.ClientKeyFile, "Client key file name (pem) for mutual TLS")
+	flag.BoolVar(&cfg.HTTPS, "https", cfg.HTTPS, "HTTP client over TLS true/false")
+
+	flag.StringVar(&cfg.SpoolDir, "spool-dir", cfg.SpoolDir, "Directory for unsent metrics, empty - no spool")
+	flag.Int64Var(&cfg.SpoolSegments, "spool-segments", cfg.SpoolSegments, "Max count of unsent batches in spool")
+
+	flag.StringVar(&cfg.PushAddress, "push", cfg.PushAddress, "Local push API address host:port or unix:/path, empty - disabled")
+
+	flag.Func("collectors-enable", "Collectors to enable, comma separated", func(s string) error {
+		cfg.setCollectorsEnabled(s, true)
+		return nil
+	})
+	flag.Func("collectors-disable", "Collectors to disable, comma separated", func(s string) error {
+		cfg.setCollectorsEnabled(s, false)
+		return nil
+	})
+	flag.StringVar(&cfg.TextfileDir, "textfile-dir", cfg.TextfileDir, "Directory with *.json and *.prom metric files, empty - disabled")
+
+	execFlag := false
+	flag.Func("exec", "Command of exec collector [name=]command args, ';' separated, may be repeated", func(s string) error {
+		if !execFlag {
+			// flags replace commands of config file
+			cfg.Exec = nil
+			execFlag = true
+		}
+		return cfg.addExec(s)
+	})
+	flag.Int64Var(&cfg.ExecTimeout, "exec-timeout", cfg.ExecTimeout, "Timeout of exec collector command")
+
+	flag.Func("probe-http", "URLs checked by probe collector, comma separated", func(s string) error {
+		cfg.ProbeHTTP = splitList(s)
+		return nil
+	})
+	flag.Func("probe-tcp", "host:port endpoints checked by probe collector, comma separated", func(s string) error {
+		cfg.ProbeTCP = splitList(s)
+		return nil
+	})
+	flag.Int64Var(&cfg.ProbeTimeout, "probe-timeout", cfg.ProbeTimeout, "Timeout of probe")
+
+	processesFlag := false
+	flag.Func("process", "Process to watch [label=]name|cmdline|pidfile:value, ';' separated, may be repeated", func(s string) error {
+		if !processesFlag {
+			// flags replace processes of config file
+			cfg.Processes = nil
+			processesFlag = true
+		}
+		return cfg.addProcesses(s)
+	})
+	flag.Func("collector-interval", "Poll interval of collectors in seconds, name=seconds comma separated", cfg.setCollectorsInterval)
+
+	utils.RetryFlags(&cfg.Retry)
 
 	flag.Parse()
 
@@ -122,10 +501,132 @@ func NewConfig(l *logger.Logger) (*Config, error) {
 		cfg.Key = envKey
 	}
 
+	if envKeyID := os.Getenv("KEY_ID"); envKeyID != "" {
+		cfg.KeyID = envKeyID
+	}
+
 	if envPublicKey := os.Getenv("CRYPTO_KEY"); envPublicKey != "" {
 		cfg.PublicKeyFile = envPublicKey
 	}
 
+	if envClientCert := os.Getenv("CLIENT_CERT"); envClientCert != "" {
+		cfg.ClientCertFile = envClientCert
+	}
+
+	if envClientKey := os.Getenv("CLIENT_KEY"); envClientKey != "" {
+		cfg.ClientKeyFile = envClientKey
+	}
+
+	if envHTTPS := os.Getenv("HTTPS"); envHTTPS != "" {
+		val, err := strconv.ParseBool(envHTTPS)
+		if err != nil {
+			l.L.Debug("Error in env HTTPS:", zap.Error(err))
+			return nil, err
+		}
+		cfg.HTTPS = val
+	}
+
+	if envDestinations := os.Getenv("DESTINATIONS"); envDestinations != "" {
+		cfg.Destinations = nil
+		if err := cfg.addDestinations(envDestinations); err != nil {
+			l.L.Debug("Error in env destinations:", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	if envDestinationMode := os.Getenv("DESTINATION_MODE"); envDestinationMode != "" {
+		cfg.DestinationMode = envDestinationMode
+	}
+
+	if envSpoolDir := os.Getenv("SPOOL_DIR"); envSpoolDir != "" {
+		cfg.SpoolDir = envSpoolDir
+	}
+
+	if envPushAddress := os.Getenv("PUSH_ADDRESS"); envPushAddress != "" {
+		cfg.PushAddress = envPushAddress
+	}
+
+	if envEnable := os.Getenv("COLLECTORS_ENABLE"); envEnable != "" {
+		cfg.setCollectorsEnabled(envEnable, true)
+	}
+	if envDisable := os.Getenv("COLLECTORS_DISABLE"); envDisable != "" {
+		cfg.setCollectorsEnabled(envDisable, false)
+	}
+	if envIntervals := os.Getenv("COLLECTOR_INTERVALS"); envIntervals != "" {
+		if err := cfg.setCollectorsInterval(envIntervals); err != nil {
+			l.L.Debug("Error in env collector intervals:", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	if envTextfileDir := os.Getenv("TEXTFILE_DIR"); envTextfileDir != "" {
+		cfg.TextfileDir = envTextfileDir
+	}
+
+	if envExec := os.Getenv("EXEC"); envExec != "" {
+		cfg.Exec = nil
+		if err := cfg.addExec(envExec); err != nil {
+			l.L.Debug("Error in env exec:", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	if envExecTimeout := os.Getenv("EXEC_TIMEOUT"); envExecTimeout != "" {
+		val, err := strconv.Atoi(envExecTimeout)
+		if err != nil {
+			l.L.Debug("Error in converting env exec timeout to int:", zap.Error(err))
+			return nil, err
+		} else {
+			cfg.ExecTimeout = int64(val)
+		}
+	}
+
+	if envProbeHTTP := os.Getenv("PROBE_HTTP"); envProbeHTTP != "" {
+		cfg.ProbeHTTP = splitList(envProbeHTTP)
+	}
+
+	if envProbeTCP := os.Getenv("PROBE_TCP"); envProbeTCP != "" {
+		cfg.ProbeTCP = splitList(envProbeTCP)
+	}
+
+	if envProbeTimeout := os.Getenv("PROBE_TIMEOUT"); envProbeTimeout != "" {
+		val, err := strconv.Atoi(envProbeTimeout)
+		if err != nil {
+			l.L.Debug("Error in converting env probe timeout to int:", zap.Error(err))
+			return nil, err
+		} else {
+			cfg.ProbeTimeout = int64(val)
+		}
+	}
+
+	if envProcesses := os.Getenv("PROCESSES"); envProcesses != "" {
+		cfg.Processes = nil
+		if err := cfg.addProcesses(envProcesses); err != nil {
+			l.L.Debug("Error in env processes:", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	if envSpoolSegments := os.Getenv("SPOOL_SEGMENTS"); envSpoolSegments != "" {
+		val, err := strconv.Atoi(envSpoolSegments)
+		if err != nil {
+			l.L.Debug("Error in converting env spool segments to int:", zap.Error(err))
+			return nil, err
+		} else {
+			cfg.SpoolSegments = int64(val)
+		}
+	}
+
+	if envGaugeAggregate := os.Getenv("GAUGE_AGGREGATE"); envGaugeAggregate != "" {
+		val, err := strconv.ParseBool(envGaugeAggregate)
+		if err != nil {
+			l.L.Debug("Error in converting env gauge aggregate to bool:", zap.Error(err))
+			return nil, err
+		} else {
+			cfg.GaugeAggregate = val
+		}
+	}
+
 	if envReportInterval := os.Getenv("REPORT_INTERVAL"); envReportInterval != "" {
 		val, err := strconv.Atoi(envReportInterval)
 		if err != nil {
@@ -146,6 +647,19 @@ func NewConfig(l *logger.Logger) (*Config, error) {
 		}
 	}
 
+	if err := utils.RetryEnv(&cfg.Retry); err != nil {
+		l.L.Debug("Error in env retry policy:", zap.Error(err))
+		return nil, err
+	}
+
+	if err := cfg.Retry.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.DestinationMode != DestinationFanout && cfg.DestinationMode != DestinationFailover {
+		return nil, fmt.Errorf("destination mode %q: want %s or %s", cfg.DestinationMode, DestinationFanout, DestinationFailover)
+	}
+
 	if !cfg.ContentJSON {
 		cfg.ContentBatch = 0
 	}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseProcessMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    ProcessMatch
+		wantErr bool
+	}{
+		{name: "name", spec: "name:nginx", want: ProcessMatch{Name: "nginx"}},
+		{name: "label", spec: "web=name:nginx", want: ProcessMatch{Label: "web", Name: "nginx"}},
+		{name: "cmdline with =", spec: "billing=cmdline:java .*-Dapp=billing", want: ProcessMatch{Label: "billing", Cmdline: "java .*-Dapp=billing"}},
+		{name: "cmdline without label", spec: "cmdline:-Dapp=billing", want: ProcessMatch{Cmdline: "-Dapp=billing"}},
+		{name: "pidfile", spec: " pidfile:/run/redis.pid ", want: ProcessMatch{Pidfile: "/run/redis.pid"}},
+		{name: "unknown kind", spec: "user:root", wantErr: true},
+		{name: "no value", spec: "name:", wantErr: true},
+		{name: "no kind", spec: "nginx", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProcessMatch(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_ParseExecCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    ExecCommand
+		wantErr bool
+	}{
+		{name: "command", spec: "/usr/local/bin/check_backup --db main", want: ExecCommand{Name: "check_backup", Command: []string{"/usr/local/bin/check_backup", "--db", "main"}}},
+		{name: "named", spec: "backup=/usr/local/bin/check_backup", want: ExecCommand{Name: "backup", Command: []string{"/usr/local/bin/check_backup"}}},
+		{name: "arg with =", spec: "check --db=main", want: ExecCommand{Name: "check", Command: []string{"check", "--db=main"}}},
+		{name: "empty", spec: "backup= ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExecCommand(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_ParseDestination(t *testing.T) {
+	yes := true
+	key := "k2"
+	keyID := "agent-2"
+	batch := int64(100)
+	tests := []struct {
+		name    string
+		spec    string
+		want    Destination
+		wantErr bool
+	}{
+		{name: "address", spec: " new.example:8080 ", want: Destination{Address: "new.example:8080"}},
+		{name: "options", spec: "new.example:3200,grpc=true,key=k2,batch=100",
+			want: Destination{Address: "new.example:3200", Grpc: &yes, Key: &key, ContentBatch: &batch}},
+		{name: "key id", spec: "new.example:8080,key=k2,key-id=agent-2",
+			want: Destination{Address: "new.example:8080", Key: &key, KeyID: &keyID}},
+		{name: "https", spec: "new.example:8443,https=true",
+			want: Destination{Address: "new.example:8443", HTTPS: &yes}},
+		{name: "no address", spec: "grpc=true", wantErr: true},
+		{name: "unknown option", spec: "new.example:8080,tls=true", wantErr: true},
+		{name: "bad bool", spec: "new.example:8080,grpc=maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDestination(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_DestinationConfigs(t *testing.T) {
+	cfg := &Config{Address: "localhost:8080", Key: "k1", ContentJSON: true, ContentBatch: 10, RateLimit: 4}
+	assert.Equal(t, []*Config{cfg}, cfg.DestinationConfigs())
+
+	yes := true
+	key := "k2"
+	cfg.Destinations = []Destination{{Address: "old:8080"}, {Address: "new:3200", Grpc: &yes, Key: &key}}
+	got := cfg.DestinationConfigs()
+	assert.Len(t, got, 2)
+	assert.Equal(t, "old:8080", got[0].Address)
+	assert.Equal(t, "k1", got[0].Key)
+	assert.False(t, got[0].Grpc)
+	assert.Equal(t, "new:3200", got[1].Address)
+	assert.Equal(t, "k2", got[1].Key)
+	assert.True(t, got[1].Grpc)
+	assert.Equal(t, int64(10), got[1].ContentBatch)
+	assert.Nil(t, got[1].Destinations)
+	assert.Equal(t, "localhost:8080", cfg.Address)
+}
@@ -3,9 +3,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/utils"
 )
 
 type Duration time.Duration
@@ -35,11 +39,64 @@ type Jsonconfig struct {
 	PublicKeyFile  *string   `json:"crypto_key,omitempty"`
 	Level          *string   `json:"level,omitempty"`
 	Key            *string   `json:"key,omitempty"`
+	KeyID          *string   `json:"key_id,omitempty"`
 	ContentBatch   *int64    `json:"content_batch,omitempty"`
 	RateLimit      *int64    `json:"rate_limit,omitempty"`
 	ContentJSON    *bool     `json:"content_json,omitempty"`
 	Grpc           *bool     `json:"grpc,omitempty"`
 	CertFile       *string   `json:"crypto_cert,omitempty"`
+	ClientCert     *string   `json:"client_cert,omitempty"`
+	ClientKey      *string   `json:"client_key,omitempty"`
+	HTTPS          *bool     `json:"https,omitempty"`
+	SpoolDir       *string   `json:"spool_dir,omitempty"`
+	SpoolSegments  *int64    `json:"spool_segments,omitempty"`
+	PushAddress    *string   `json:"push_address,omitempty"`
+	TextfileDir    *string   `json:"textfile_dir,omitempty"`
+	GaugeAggregate *bool     `json:"gauge_aggregate,omitempty"`
+
+	Collectors map[string]JSONCollector `json:"collectors,omitempty"`
+	Processes  []JSONProcess            `json:"processes,omitempty"`
+
+	Exec        []JSONExec `json:"exec,omitempty"`
+	ExecTimeout *Duration  `json:"exec_timeout,omitempty"`
+
+	ProbeHTTP    []string  `json:"probe_http,omitempty"`
+	ProbeTCP     []string  `json:"probe_tcp,omitempty"`
+	ProbeTimeout *Duration `json:"probe_timeout,omitempty"`
+
+	Destinations    []JSONDestination `json:"destinations,omitempty"`
+	DestinationMode *string           `json:"destination_mode,omitempty"`
+
+	Retry *utils.JSONRetry `json:"retry,omitempty"`
+}
+
+type JSONDestination struct {
+	Address       string  `json:"address"`
+	Grpc          *bool   `json:"grpc,omitempty"`
+	HTTPS         *bool   `json:"https,omitempty"`
+	Key           *string `json:"key,omitempty"`
+	KeyID         *string `json:"key_id,omitempty"`
+	PublicKeyFile *string `json:"crypto_key,omitempty"`
+	CertKeyFile   *string `json:"crypto_cert,omitempty"`
+	ContentBatch  *int64  `json:"content_batch,omitempty"`
+	RateLimit     *int64  `json:"rate_limit,omitempty"`
+}
+
+type JSONExec struct {
+	Name    string   `json:"name,omitempty"`
+	Command []string `json:"command"`
+}
+
+type JSONProcess struct {
+	Label   string `json:"label,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Cmdline string `json:"cmdline,omitempty"`
+	Pidfile string `json:"pidfile,omitempty"`
+}
+
+type JSONCollector struct {
+	Enabled  *bool     `json:"enabled,omitempty"`
+	Interval *Duration `json:"interval,omitempty"`
 }
 
 func jsonConfigDecode(body io.ReadCloser) (*Jsonconfig, error) {
@@ -89,6 +146,10 @@ func loadConfigJson(name string, cfg *Config) error {
 		cfg.Key = *jsonconfig.Key
 	}
 
+	if jsonconfig.KeyID != nil {
+		cfg.KeyID = *jsonconfig.KeyID
+	}
+
 	if jsonconfig.PublicKeyFile != nil {
 		cfg.PublicKeyFile = *jsonconfig.PublicKeyFile
 	}
@@ -104,9 +165,99 @@ func loadConfigJson(name string, cfg *Config) error {
 		cfg.Grpc = *jsonconfig.Grpc
 	}
 
+	if jsonconfig.GaugeAggregate != nil {
+		cfg.GaugeAggregate = *jsonconfig.GaugeAggregate
+	}
+
 	if jsonconfig.CertFile != nil {
 		cfg.CertKeyFile = *jsonconfig.CertFile
 	}
 
+	if jsonconfig.ClientCert != nil {
+		cfg.ClientCertFile = *jsonconfig.ClientCert
+	}
+
+	if jsonconfig.ClientKey != nil {
+		cfg.ClientKeyFile = *jsonconfig.ClientKey
+	}
+
+	if jsonconfig.HTTPS != nil {
+		cfg.HTTPS = *jsonconfig.HTTPS
+	}
+
+	if jsonconfig.SpoolDir != nil {
+		cfg.SpoolDir = *jsonconfig.SpoolDir
+	}
+
+	if jsonconfig.SpoolSegments != nil {
+		cfg.SpoolSegments = *jsonconfig.SpoolSegments
+	}
+
+	if jsonconfig.PushAddress != nil {
+		cfg.PushAddress = *jsonconfig.PushAddress
+	}
+
+	if jsonconfig.TextfileDir != nil {
+		cfg.TextfileDir = *jsonconfig.TextfileDir
+	}
+
+	for _, e := range jsonconfig.Exec {
+		if len(e.Command) == 0 {
+			return errors.New("exec without command")
+		}
+		if e.Name == "" {
+			e.Name = filepath.Base(e.Command[0])
+		}
+		cfg.Exec = append(cfg.Exec, ExecCommand(e))
+	}
+
+	if jsonconfig.ExecTimeout != nil {
+		cfg.ExecTimeout = int64(*jsonconfig.ExecTimeout) / 1000000000
+	}
+
+	if jsonconfig.ProbeHTTP != nil {
+		cfg.ProbeHTTP = jsonconfig.ProbeHTTP
+	}
+
+	if jsonconfig.ProbeTCP != nil {
+		cfg.ProbeTCP = jsonconfig.ProbeTCP
+	}
+
+	if jsonconfig.ProbeTimeout != nil {
+		cfg.ProbeTimeout = int64(*jsonconfig.ProbeTimeout) / 1000000000
+	}
+
+	for _, d := range jsonconfig.Destinations {
+		if d.Address == "" {
+			return errors.New("destination without address")
+		}
+		cfg.Destinations = append(cfg.Destinations, Destination(d))
+	}
+
+	if jsonconfig.DestinationMode != nil {
+		cfg.DestinationMode = *jsonconfig.DestinationMode
+	}
+
+	if jsonconfig.Retry != nil {
+		if err := jsonconfig.Retry.Apply(&cfg.Retry); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range jsonconfig.Processes {
+		cfg.Processes = append(cfg.Processes, ProcessMatch(p))
+	}
+
+	for name, c := range jsonconfig.Collectors {
+		cc := cfg.collector(name)
+		if c.Enabled != nil {
+			cc.Enabled = c.Enabled
+		}
+		if c.Interval != nil {
+			cc.Interval = int64(*c.Interval) / 1000000000
+		}
+		cfg.Collectors[name] = cc
+	}
+
 	return nil
 }
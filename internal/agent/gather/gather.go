@@ -4,44 +4,43 @@ import (
 	"context"
 	"crypto/rand"
 	"math/big"
-	"runtime"
-	"sync"
+	"runtime/debug"
+	"runtime/metrics"
 	"time"
 
-	"github.com/4aleksei/metricscum/internal/agent/config"
-	"github.com/4aleksei/metricscum/internal/agent/service"
-	"github.com/4aleksei/metricscum/internal/common/logger"
-	"github.com/4aleksei/metricscum/internal/common/utils"
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/common/models"
 )
 
-type AppGather struct {
-	serv   *service.HandlerStore
-	l      *logger.Logger
-	cfg    *config.Config
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+// Runtime - all supported runtime/metrics values, MemStats compatible gauges,
+// poll counter and random value. Nothing stops the world
+type Runtime struct {
+	samples []metrics.Sample
+	index   map[string]int
+	prev    map[string][]uint64
 }
 
-func NewAppGather(serv *service.HandlerStore, l *logger.Logger, cfg *config.Config) *AppGather {
-	return &AppGather{
-		l:    l,
-		serv: serv,
-		cfg:  cfg,
+func NewRuntime() *Runtime {
+	c := &Runtime{
+		index: make(map[string]int),
+		prev:  make(map[string][]uint64),
 	}
+	for _, d := range metrics.All() {
+		if d.Kind == metrics.KindBad {
+			continue
+		}
+		c.index[d.Name] = len(c.samples)
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+	}
+	return c
 }
 
-func (app *AppGather) Start(ctx context.Context) error {
-	ctxCancel, cancel := context.WithCancel(context.Background())
-	app.cancel = cancel
-	app.wg.Add(1)
-	go app.mainGather(ctxCancel)
-	return nil
+func (c *Runtime) Name() string {
+	return "runtime"
 }
 
-func (app *AppGather) Stop(ctx context.Context) error {
-	app.cancel()
-	app.wg.Wait()
-	return nil
+func (c *Runtime) Interval() time.Duration {
+	return 0
 }
 
 const maxInt int64 = 1 << 53
@@ -58,49 +57,94 @@ func RandFloat64() float64 {
 	return float64(Intn()) / float64(maxInt)
 }
 
-func (app *AppGather) mainGather(ctx context.Context) {
-	defer app.wg.Done()
-	var m runtime.MemStats
-
-	app.l.L.Info("Start gathering stats.")
-
-	for {
-		utils.SleepCancellable(ctx, time.Duration(app.cfg.PollInterval)*time.Second)
-		select {
-		case <-ctx.Done():
-			app.l.L.Info("Stop gathering.")
-			return
+func (c *Runtime) Collect(ctx context.Context) ([]models.Metrics, error) {
+	metrics.Read(c.samples)
+	res := c.memStats()
+	for _, s := range c.samples {
+		name := MetricName(s.Name)
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			res = append(res, collector.Gauge(name, float64(s.Value.Uint64())))
+		case metrics.KindFloat64:
+			res = append(res, collector.Gauge(name, s.Value.Float64()))
+		case metrics.KindFloat64Histogram:
+			if h := c.histDelta(s.Name, s.Value.Float64Histogram()); h != nil {
+				res = append(res, models.Metrics{ID: name, MType: "histogram", Histogram: h})
+			}
 		default:
-			runtime.ReadMemStats(&m)
-			_, _ = app.serv.SetGauge(ctx, "Alloc", float64(m.Alloc))
-			_, _ = app.serv.SetGauge(ctx, "BuckHashSys", float64(m.BuckHashSys))
-			_, _ = app.serv.SetGauge(ctx, "Frees", float64(m.Frees))
-			_, _ = app.serv.SetGauge(ctx, "GCCPUFraction", float64(m.GCCPUFraction))
-			_, _ = app.serv.SetGauge(ctx, "GCSys", float64(m.GCSys))
-			_, _ = app.serv.SetGauge(ctx, "HeapAlloc", float64(m.HeapAlloc))
-			_, _ = app.serv.SetGauge(ctx, "HeapIdle", float64(m.HeapIdle))
-			_, _ = app.serv.SetGauge(ctx, "HeapInuse", float64(m.HeapInuse))
-			_, _ = app.serv.SetGauge(ctx, "HeapObjects", float64(m.HeapObjects))
-			_, _ = app.serv.SetGauge(ctx, "HeapReleased", float64(m.HeapReleased))
-			_, _ = app.serv.SetGauge(ctx, "HeapSys", float64(m.HeapSys))
-			_, _ = app.serv.SetGauge(ctx, "LastGC", float64(m.LastGC))
-			_, _ = app.serv.SetGauge(ctx, "Lookups", float64(m.Lookups))
-			_, _ = app.serv.SetGauge(ctx, "MCacheInuse", float64(m.MCacheInuse))
-			_, _ = app.serv.SetGauge(ctx, "MCacheSys", float64(m.MCacheSys))
-			_, _ = app.serv.SetGauge(ctx, "MSpanInuse", float64(m.MSpanInuse))
-			_, _ = app.serv.SetGauge(ctx, "MSpanSys", float64(m.MSpanSys))
-			_, _ = app.serv.SetGauge(ctx, "Mallocs", float64(m.Mallocs))
-			_, _ = app.serv.SetGauge(ctx, "NextGC", float64(m.NextGC))
-			_, _ = app.serv.SetGauge(ctx, "NumForcedGC", float64(m.NumForcedGC))
-			_, _ = app.serv.SetGauge(ctx, "NumGC", float64(m.NumGC))
-			_, _ = app.serv.SetGauge(ctx, "OtherSys", float64(m.OtherSys))
-			_, _ = app.serv.SetGauge(ctx, "PauseTotalNs", float64(m.PauseTotalNs))
-			_, _ = app.serv.SetGauge(ctx, "StackInuse", float64(m.StackInuse))
-			_, _ = app.serv.SetGauge(ctx, "StackSys", float64(m.StackSys))
-			_, _ = app.serv.SetGauge(ctx, "Sys", float64(m.Sys))
-			_, _ = app.serv.SetGauge(ctx, "TotalAlloc", float64(m.TotalAlloc))
-			_, _ = app.serv.SetCounter(ctx, "PollCount", 1)
-			_, _ = app.serv.SetGauge(ctx, "RandomValue", RandFloat64())
 		}
 	}
+	return append(res,
+		collector.Counter("PollCount", 1),
+		collector.Gauge("RandomValue", RandFloat64()),
+	), nil
+}
+
+// value - uint64 or float64 sample, zero for metric unknown to runtime
+func (c *Runtime) value(name string) float64 {
+	i, ok := c.index[name]
+	if !ok {
+		return 0
+	}
+	switch v := c.samples[i].Value; v.Kind() {
+	case metrics.KindUint64:
+		return float64(v.Uint64())
+	case metrics.KindFloat64:
+		return v.Float64()
+	default:
+		return 0
+	}
+}
+
+// memStats - gauges with names and meaning of runtime.MemStats fields
+func (c *Runtime) memStats() []models.Metrics {
+	v := c.value
+	heapObjects := v("/memory/classes/heap/objects:bytes")
+	heapUnused := v("/memory/classes/heap/unused:bytes")
+	heapFree := v("/memory/classes/heap/free:bytes")
+	heapReleased := v("/memory/classes/heap/released:bytes")
+	stackInuse := v("/memory/classes/heap/stacks:bytes")
+	tiny := v("/gc/heap/tiny/allocs:objects")
+
+	var gcCPU float64
+	if total := v("/cpu/classes/total:cpu-seconds"); total > 0 {
+		gcCPU = v("/cpu/classes/gc/total:cpu-seconds") / total
+	}
+	// last GC time and pause total are not in runtime/metrics, ReadGCStats does not stop the world
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	var lastGC float64
+	if !gc.LastGC.IsZero() {
+		lastGC = float64(gc.LastGC.UnixNano())
+	}
+
+	return []models.Metrics{
+		collector.Gauge("Alloc", heapObjects),
+		collector.Gauge("BuckHashSys", v("/memory/classes/profiling/buckets:bytes")),
+		collector.Gauge("Frees", v("/gc/heap/frees:objects")+tiny),
+		collector.Gauge("GCCPUFraction", gcCPU),
+		collector.Gauge("GCSys", v("/memory/classes/metadata/other:bytes")),
+		collector.Gauge("HeapAlloc", heapObjects),
+		collector.Gauge("HeapIdle", heapFree+heapReleased),
+		collector.Gauge("HeapInuse", heapObjects+heapUnused),
+		collector.Gauge("HeapObjects", v("/gc/heap/objects:objects")),
+		collector.Gauge("HeapReleased", heapReleased),
+		collector.Gauge("HeapSys", heapObjects+heapUnused+heapFree+heapReleased),
+		collector.Gauge("LastGC", lastGC),
+		collector.Gauge("Lookups", 0),
+		collector.Gauge("MCacheInuse", v("/memory/classes/metadata/mcache/inuse:bytes")),
+		collector.Gauge("MCacheSys", v("/memory/classes/metadata/mcache/inuse:bytes")+v("/memory/classes/metadata/mcache/free:bytes")),
+		collector.Gauge("MSpanInuse", v("/memory/classes/metadata/mspan/inuse:bytes")),
+		collector.Gauge("MSpanSys", v("/memory/classes/metadata/mspan/inuse:bytes")+v("/memory/classes/metadata/mspan/free:bytes")),
+		collector.Gauge("Mallocs", v("/gc/heap/allocs:objects")+tiny),
+		collector.Gauge("NextGC", v("/gc/heap/goal:bytes")),
+		collector.Gauge("NumForcedGC", v("/gc/cycles/forced:gc-cycles")),
+		collector.Gauge("NumGC", v("/gc/cycles/total:gc-cycles")),
+		collector.Gauge("OtherSys", v("/memory/classes/other:bytes")),
+		collector.Gauge("PauseTotalNs", float64(gc.PauseTotal.Nanoseconds())),
+		collector.Gauge("StackInuse", stackInuse),
+		collector.Gauge("StackSys", stackInuse+v("/memory/classes/os-stacks:bytes")),
+		collector.Gauge("Sys", v("/memory/classes/total:bytes")),
+		collector.Gauge("TotalAlloc", v("/gc/heap/allocs:bytes")),
+	}
 }
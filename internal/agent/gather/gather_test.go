@@ -0,0 +1,77 @@
+package gather
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MetricName(t *testing.T) {
+	assert.Equal(t, "GoSchedGoroutinesGoroutines", MetricName("/sched/goroutines:goroutines"))
+	assert.Equal(t, "GoGcHeapAllocsBySizeBytes", MetricName("/gc/heap/allocs-by-size:bytes"))
+	assert.Equal(t, "GoCpuClassesGcTotalCpuSeconds", MetricName("/cpu/classes/gc/total:cpu-seconds"))
+}
+
+func Test_convertHist(t *testing.T) {
+	h := convertHist([]float64{math.Inf(-1), 0, 1, 2, math.Inf(1)}, []uint64{0, 2, 1, 1})
+	require.NoError(t, h.Validate())
+	assert.Equal(t, []float64{0, 1, 2}, h.Bounds)
+	assert.Equal(t, []uint64{0, 2, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.InDelta(t, 0.5*2+1.5+2, h.Sum, 1e-9)
+
+	// range without +Inf bucket
+	h = convertHist([]float64{1, 9, 17}, []uint64{3, 1})
+	require.NoError(t, h.Validate())
+	assert.Equal(t, []float64{9, 17}, h.Bounds)
+	assert.Equal(t, []uint64{3, 1, 0}, h.Counts)
+}
+
+func collect(t *testing.T, c *Runtime) map[string]models.Metrics {
+	vals, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	res := make(map[string]models.Metrics, len(vals))
+	for _, m := range vals {
+		_, err := m.ConvertModelToMetric()
+		require.NoError(t, err, m.ID)
+		assert.NotContains(t, res, m.ID)
+		res[m.ID] = m
+	}
+	return res
+}
+
+func Test_RuntimeCollect(t *testing.T) {
+	c := NewRuntime()
+	runtime.GC()
+	got := collect(t, c)
+
+	for _, name := range []string{"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc",
+		"HeapIdle", "HeapInuse", "HeapObjects", "HeapReleased", "HeapSys", "LastGC", "Lookups",
+		"MCacheInuse", "MCacheSys", "MSpanInuse", "MSpanSys", "Mallocs", "NextGC", "NumForcedGC",
+		"NumGC", "OtherSys", "PauseTotalNs", "StackInuse", "StackSys", "Sys", "TotalAlloc", "RandomValue"} {
+		require.Contains(t, got, name)
+		assert.Equal(t, "gauge", got[name].MType, name)
+	}
+	assert.Equal(t, int64(1), *got["PollCount"].Delta)
+	assert.Greater(t, *got["HeapAlloc"].Value, 0.0)
+	assert.GreaterOrEqual(t, *got["NumForcedGC"].Value, 1.0)
+	assert.Greater(t, *got["LastGC"].Value, 0.0)
+	assert.Greater(t, *got["GoSchedGoroutinesGoroutines"].Value, 0.0)
+
+	pauses := got["GoGcPausesSeconds"]
+	require.NotNil(t, pauses.Histogram)
+	first := pauses.Histogram.Count
+	assert.Greater(t, first, uint64(0))
+
+	// next read has only pauses of GC since previous read
+	runtime.GC()
+	got = collect(t, c)
+	require.Contains(t, got, "GoGcPausesSeconds")
+	assert.Greater(t, got["GoGcPausesSeconds"].Histogram.Count, uint64(0))
+	assert.LessOrEqual(t, got["GoGcPausesSeconds"].Histogram.Count, first)
+}
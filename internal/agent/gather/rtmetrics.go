@@ -0,0 +1,76 @@
+package gather
+
+import (
+	"math"
+	"runtime/metrics"
+	"strings"
+	"unicode"
+
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+// MetricName - runtime/metrics name in metric form: /sched/goroutines:goroutines is GoSchedGoroutinesGoroutines
+func MetricName(name string) string {
+	var b strings.Builder
+	b.WriteString("Go")
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]))
+		b.WriteString(part[1:])
+	}
+	return b.String()
+}
+
+// histDelta - observations since previous read, nil if there are none.
+// Runtime histograms are cumulative, agent histograms are sent per report as in DoUpdate/DoRead
+func (c *Runtime) histDelta(name string, h *metrics.Float64Histogram) *valuemetric.Histogram {
+	prev := c.prev[name]
+	if len(prev) != len(h.Counts) {
+		prev = nil
+	}
+	delta := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, n := range h.Counts {
+		if prev != nil && n >= prev[i] {
+			n -= prev[i]
+		}
+		delta[i] = n
+		total += n
+	}
+	c.prev[name] = append(prev[:0], h.Counts...)
+	if total == 0 {
+		return nil
+	}
+	return convertHist(h.Buckets, delta)
+}
+
+// convertHist - runtime buckets [Buckets[i], Buckets[i+1]) to upper bounds.
+// Runtime does not keep sum of observations, it is estimated by bucket midpoints
+func convertHist(buckets []float64, counts []uint64) *valuemetric.Histogram {
+	last := len(buckets) - 1
+	bounds := buckets[1:]
+	if math.IsInf(buckets[last], 1) {
+		bounds = buckets[1:last]
+	}
+	res := valuemetric.NewHistogram(bounds)
+	copy(res.Counts, counts)
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+		lo, hi := buckets[i], buckets[i+1]
+		mid := (lo + hi) / 2
+		switch {
+		case math.IsInf(lo, -1) && math.IsInf(hi, 1):
+			mid = 0
+		case math.IsInf(lo, -1):
+			mid = hi
+		case math.IsInf(hi, 1):
+			mid = lo
+		}
+		res.Sum += mid * float64(n)
+		res.Count += n
+	}
+	return res
+}
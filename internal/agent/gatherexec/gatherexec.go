@@ -0,0 +1,170 @@
+// Package gatherexec - metrics printed by scripts and commands
+package gatherexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+// waitDelay - time to wait for output pipes after command is killed
+const waitDelay = time.Second
+
+var (
+	ErrBadLine = errors.New("invalid output line")
+)
+
+// Exec - runs configured commands concurrently, each with timeout.
+// Stdout lines are models.Metrics JSON or type/name/value plain form, counters are deltas.
+// ExecExitCode (-1 if command was not started or killed), ExecDuration in seconds and
+// ExecParseErrors are reported with label command
+type Exec struct {
+	commands []config.ExecCommand
+	timeout  time.Duration
+}
+
+func NewExec(cfg *config.Config) *Exec {
+	return &Exec{
+		commands: cfg.Exec,
+		timeout:  time.Duration(cfg.ExecTimeout) * time.Second,
+	}
+}
+
+func (c *Exec) Name() string {
+	return "exec"
+}
+
+func (c *Exec) Interval() time.Duration {
+	return 0
+}
+
+func (c *Exec) Collect(ctx context.Context) ([]models.Metrics, error) {
+	if len(c.commands) == 0 {
+		return nil, nil
+	}
+	results := make([][]models.Metrics, len(c.commands))
+	errs := make([]error, len(c.commands))
+	var wg sync.WaitGroup
+	for i, cmd := range c.commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.run(ctx, cmd)
+		}()
+	}
+	wg.Wait()
+
+	var res []models.Metrics
+	for _, r := range results {
+		res = append(res, r...)
+	}
+	return res, errors.Join(errs...)
+}
+
+func labeled(m models.Metrics, name string) models.Metrics {
+	m.Labels = map[string]string{"command": name}
+	return m
+}
+
+func (c *Exec) run(ctx context.Context, command config.ExecCommand) ([]models.Metrics, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, command.Command[0], command.Command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.WaitDelay = waitDelay
+
+	start := time.Now()
+	errRun := cmd.Run()
+	duration := time.Since(start)
+
+	code := -1
+	if cmd.ProcessState != nil {
+		code = cmd.ProcessState.ExitCode()
+	}
+	var err error
+	if errRun != nil {
+		err = fmt.Errorf("exec %s: %w", command.Name, errRun)
+	}
+
+	res, bad := ParseOutput(&stdout)
+	return append(res,
+		labeled(collector.Gauge("ExecExitCode", float64(code)), command.Name),
+		labeled(collector.Gauge("ExecDuration", duration.Seconds()), command.Name),
+		labeled(collector.Gauge("ExecParseErrors", float64(bad)), command.Name),
+	), err
+}
+
+// ParseOutput - valid metrics of output and count of broken lines
+func ParseOutput(r io.Reader) ([]models.Metrics, int) {
+	var res []models.Metrics
+	bad := 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		m, err := ParseLine(line)
+		if err != nil {
+			bad++
+			continue
+		}
+		res = append(res, m)
+	}
+	if sc.Err() != nil {
+		bad++
+	}
+	return res, bad
+}
+
+// ParseLine - models.Metrics JSON object or type/name/value
+func ParseLine(line string) (models.Metrics, error) {
+	var m models.Metrics
+	if line[0] == '{' {
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			return m, fmt.Errorf("%w: %w", ErrBadLine, err)
+		}
+	} else {
+		first := strings.IndexByte(line, '/')
+		last := strings.LastIndexByte(line, '/')
+		if first <= 0 || last <= first+1 {
+			return m, ErrBadLine
+		}
+		kind, err := valuemetric.GetKind(line[:first])
+		if err != nil {
+			return m, fmt.Errorf("%w: %w", ErrBadLine, err)
+		}
+		val, err := valuemetric.ConvertToValueMetric(kind, line[last+1:])
+		if err != nil {
+			return m, fmt.Errorf("%w: %w", ErrBadLine, err)
+		}
+		m.ConvertMetricToModel(line[first+1:last], *val)
+	}
+	if m.ID == "" {
+		return m, ErrBadLine
+	}
+	if _, err := m.SeriesKey(); err != nil {
+		return m, fmt.Errorf("%w: %w", ErrBadLine, err)
+	}
+	if _, err := m.ConvertModelToMetric(); err != nil {
+		return m, fmt.Errorf("%w: %w", ErrBadLine, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,77 @@
+package gatherexec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func values(t *testing.T, ms []models.Metrics) map[string]string {
+	res := make(map[string]string, len(ms))
+	for _, m := range ms {
+		key, err := m.SeriesKey()
+		require.NoError(t, err)
+		res[key] = m.MType + ":" + m.ConvertMetricToValue()
+	}
+	return res
+}
+
+func Test_ParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain gauge", line: "gauge/queue_len/4.5", want: "queue_len gauge:4.5"},
+		{name: "plain counter", line: "counter/jobs_done/3", want: "jobs_done counter:3"},
+		{name: "plain labels", line: `gauge/disk{path="/var/lib"}/10`, want: `disk{path="/var/lib"} gauge:10`},
+		{name: "json", line: `{"id":"jobs","type":"counter","delta":2,"labels":{"queue":"mail"}}`, want: `jobs{queue="mail"} counter:2`},
+		{name: "plain bad type", line: "set/users/1", wantErr: true},
+		{name: "plain bad value", line: "counter/jobs/1.5", wantErr: true},
+		{name: "plain no name", line: "gauge//1", wantErr: true},
+		{name: "json no value", line: `{"id":"jobs","type":"counter"}`, wantErr: true},
+		{name: "json broken", line: `{"id":`, wantErr: true},
+		{name: "text", line: "backup finished", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBadLine)
+				return
+			}
+			require.NoError(t, err)
+			key, _ := got.SeriesKey()
+			assert.Equal(t, tt.want, key+" "+got.MType+":"+got.ConvertMetricToValue())
+		})
+	}
+}
+
+func Test_ExecCollect(t *testing.T) {
+	c := NewExec(&config.Config{ExecTimeout: 1, Exec: []config.ExecCommand{
+		{Name: "ok", Command: []string{"/bin/sh", "-c", `echo gauge/queue_len/4; echo '{"id":"jobs","type":"counter","delta":2}'; echo oops`}},
+		{Name: "fail", Command: []string{"/bin/sh", "-c", "echo counter/errors/1; exit 3"}},
+		{Name: "slow", Command: []string{"/bin/sh", "-c", "exec sleep 10"}},
+		{Name: "missing", Command: []string{"/nonexistent/command"}},
+	}})
+	res, err := c.Collect(context.Background())
+	assert.Error(t, err)
+	got := values(t, res)
+
+	assert.Equal(t, "gauge:4", got["queue_len"])
+	assert.Equal(t, "counter:2", got["jobs"])
+	assert.Equal(t, "gauge:0", got[`ExecExitCode{command="ok"}`])
+	assert.Equal(t, "gauge:1", got[`ExecParseErrors{command="ok"}`])
+
+	assert.Equal(t, "counter:1", got["errors"], "output of failed command is kept")
+	assert.Equal(t, "gauge:3", got[`ExecExitCode{command="fail"}`])
+
+	assert.Equal(t, "gauge:-1", got[`ExecExitCode{command="slow"}`])
+	assert.Contains(t, got, `ExecDuration{command="slow"}`)
+	assert.Equal(t, "gauge:-1", got[`ExecExitCode{command="missing"}`])
+}
@@ -0,0 +1,49 @@
+package gatherps
+
+import (
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/common/models"
+)
+
+// deltas - monotonic host counters to counter deltas between polls
+type deltas struct {
+	prev map[string]uint64
+	next map[string]uint64
+}
+
+func newDeltas() *deltas {
+	return &deltas{prev: make(map[string]uint64)}
+}
+
+// begin - start of poll, series not seen in poll are forgotten
+func (d *deltas) begin() {
+	d.next = make(map[string]uint64, len(d.prev))
+}
+
+func (d *deltas) end() {
+	d.prev = d.next
+}
+
+// add - counter delta since previous poll, nothing on first poll of series.
+// Decreased value means counter reset, value is delta since reset
+func (d *deltas) add(res []models.Metrics, name, label, labelVal string, cur uint64) []models.Metrics {
+	key := name + "\x00" + labelVal
+	d.next[key] = cur
+	prev, ok := d.prev[key]
+	if !ok {
+		return res
+	}
+	delta := cur - prev
+	if cur < prev {
+		delta = cur
+	}
+	m := collector.Counter(name, int64(delta))
+	m.Labels = map[string]string{label: labelVal}
+	return append(res, m)
+}
+
+func labeledGauge(name, label, labelVal string, v float64) models.Metrics {
+	m := collector.Gauge(name, v)
+	m.Labels = map[string]string{label: labelVal}
+	return m
+}
@@ -0,0 +1,97 @@
+package gatherps
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// Disk - usage of mounted filesystems, gauges with label mount
+type Disk struct {
+	partitions func(ctx context.Context, all bool) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+}
+
+func NewDisk() *Disk {
+	return &Disk{
+		partitions: disk.PartitionsWithContext,
+		usage:      disk.UsageWithContext,
+	}
+}
+
+func (c *Disk) Name() string {
+	return "disk"
+}
+
+func (c *Disk) Interval() time.Duration {
+	return 0
+}
+
+func (c *Disk) Collect(ctx context.Context) ([]models.Metrics, error) {
+	parts, err := c.partitions(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	var res []models.Metrics
+	var errs []error
+	seen := make(map[string]bool, len(parts))
+	for _, p := range parts {
+		if seen[p.Mountpoint] {
+			continue
+		}
+		seen[p.Mountpoint] = true
+		u, errU := c.usage(ctx, p.Mountpoint)
+		if errU != nil {
+			errs = append(errs, errU)
+			continue
+		}
+		res = append(res,
+			labeledGauge("DiskTotal", "mount", p.Mountpoint, float64(u.Total)),
+			labeledGauge("DiskUsed", "mount", p.Mountpoint, float64(u.Used)),
+			labeledGauge("DiskFree", "mount", p.Mountpoint, float64(u.Free)),
+			labeledGauge("DiskUsedPercent", "mount", p.Mountpoint, u.UsedPercent),
+		)
+	}
+	return res, errors.Join(errs...)
+}
+
+// DiskIO - per device read/write counters, deltas between polls with label device
+type DiskIO struct {
+	counters func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error)
+	deltas   *deltas
+}
+
+func NewDiskIO() *DiskIO {
+	return &DiskIO{
+		counters: disk.IOCountersWithContext,
+		deltas:   newDeltas(),
+	}
+}
+
+func (c *DiskIO) Name() string {
+	return "diskio"
+}
+
+func (c *DiskIO) Interval() time.Duration {
+	return 0
+}
+
+func (c *DiskIO) Collect(ctx context.Context) ([]models.Metrics, error) {
+	stats, err := c.counters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []models.Metrics
+	c.deltas.begin()
+	for dev, s := range stats {
+		res = c.deltas.add(res, "DiskReadBytes", "device", dev, s.ReadBytes)
+		res = c.deltas.add(res, "DiskWriteBytes", "device", dev, s.WriteBytes)
+		res = c.deltas.add(res, "DiskReads", "device", dev, s.ReadCount)
+		res = c.deltas.add(res, "DiskWrites", "device", dev, s.WriteCount)
+	}
+	c.deltas.end()
+	return res, nil
+}
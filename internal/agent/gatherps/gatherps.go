@@ -2,72 +2,45 @@ package gatherps
 
 import (
 	"context"
-
 	"strconv"
-	"sync"
 	"time"
 
-	"github.com/4aleksei/metricscum/internal/agent/config"
-	"github.com/4aleksei/metricscum/internal/agent/service"
-	"github.com/4aleksei/metricscum/internal/common/logger"
-	"github.com/4aleksei/metricscum/internal/common/utils"
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/common/models"
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/mem"
 )
 
-type AppGatherMem struct {
-	serv   *service.HandlerStore
-	l      *logger.Logger
-	cfg    *config.Config
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
-}
+// PS - memory and cpu utilization of host
+type PS struct{}
 
-func NewGather(serv *service.HandlerStore, l *logger.Logger, cfg *config.Config) *AppGatherMem {
-	return &AppGatherMem{
-		l:    l,
-		serv: serv,
-		wg:   sync.WaitGroup{},
-		cfg:  cfg,
-	}
+func NewPS() *PS {
+	return &PS{}
 }
 
-func (app *AppGatherMem) Start(ctx context.Context) error {
-	ctxCancel, cancel := context.WithCancel(context.Background())
-	app.cancel = cancel
-	app.wg.Add(1)
-	go app.mainGather(ctxCancel)
-	return nil
+func (c *PS) Name() string {
+	return "ps"
 }
 
-func (app *AppGatherMem) Stop(ctx context.Context) error {
-	app.cancel()
-	app.wg.Wait()
-	return nil
+func (c *PS) Interval() time.Duration {
+	return 0
 }
 
-func (app *AppGatherMem) mainGather(ctx context.Context) {
-	defer app.wg.Done()
-
-	app.l.L.Info("Start gatheringPS stats.")
-
-	for {
-		utils.SleepCancellable(ctx, time.Duration(app.cfg.PollInterval)*time.Second)
-		select {
-		case <-ctx.Done():
-			return
-		default:
-
-			v, _ := mem.VirtualMemory()
-
-			_, _ = app.serv.SetGauge(ctx, "TotalMemory", float64(v.Total))
-			_, _ = app.serv.SetGauge(ctx, "FreeMemory", float64(v.Free))
-			c, _ := cpu.Percent(0, true)
-			var name []string
-			for i := range c {
-				name = append(name, "CPUutilization"+strconv.Itoa(i+1))
-			}
-			_, _ = app.serv.SetGaugeMulti(ctx, name, c)
-		}
+func (c *PS) Collect(ctx context.Context) ([]models.Metrics, error) {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := []models.Metrics{
+		collector.Gauge("TotalMemory", float64(v.Total)),
+		collector.Gauge("FreeMemory", float64(v.Free)),
+	}
+	percent, err := cpu.PercentWithContext(ctx, 0, true)
+	if err != nil {
+		return res, err
+	}
+	for i, p := range percent {
+		res = append(res, collector.Gauge("CPUutilization"+strconv.Itoa(i+1), p))
 	}
+	return res, nil
 }
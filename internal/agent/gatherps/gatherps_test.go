@@ -0,0 +1,111 @@
+package gatherps
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// values - series key to value
+func values(t *testing.T, ms []models.Metrics) map[string]string {
+	res := make(map[string]string, len(ms))
+	for _, m := range ms {
+		key, err := m.SeriesKey()
+		require.NoError(t, err)
+		res[key] = m.MType + ":" + m.ConvertMetricToValue()
+	}
+	return res
+}
+
+func Test_NetDeltas(t *testing.T) {
+	stats := []net.IOCountersStat{{Name: "eth0", BytesSent: 100, BytesRecv: 200, PacketsSent: 1, PacketsRecv: 2}}
+	c := NewNet()
+	c.counters = func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error) {
+		return stats, nil
+	}
+
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, res, "first poll is baseline")
+
+	stats[0].BytesSent, stats[0].BytesRecv, stats[0].PacketsSent = 150, 260, 4
+	res, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	got := values(t, res)
+	assert.Equal(t, "counter:50", got[`NetBytesSent{interface="eth0"}`])
+	assert.Equal(t, "counter:60", got[`NetBytesRecv{interface="eth0"}`])
+	assert.Equal(t, "counter:3", got[`NetPacketsSent{interface="eth0"}`])
+	assert.Equal(t, "counter:0", got[`NetPacketsRecv{interface="eth0"}`])
+
+	stats[0].BytesSent = 10
+	res, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "counter:10", values(t, res)[`NetBytesSent{interface="eth0"}`], "counter reset")
+}
+
+func Test_DiskIODeltas(t *testing.T) {
+	stats := map[string]disk.IOCountersStat{"sda": {ReadBytes: 1000, WriteBytes: 10, ReadCount: 5, WriteCount: 1}}
+	c := NewDiskIO()
+	c.counters = func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) {
+		return stats, nil
+	}
+	_, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	stats = map[string]disk.IOCountersStat{
+		"sda": {ReadBytes: 1500, WriteBytes: 10, ReadCount: 7, WriteCount: 1},
+		"sdb": {ReadBytes: 1},
+	}
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		`DiskReadBytes{device="sda"}`:  "counter:500",
+		`DiskWriteBytes{device="sda"}`: "counter:0",
+		`DiskReads{device="sda"}`:      "counter:2",
+		`DiskWrites{device="sda"}`:     "counter:0",
+	}, values(t, res), "new device starts with baseline")
+
+	c.counters = func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error) {
+		return nil, errors.New("no stats")
+	}
+	_, err = c.Collect(context.Background())
+	assert.Error(t, err)
+}
+
+func Test_Disk(t *testing.T) {
+	c := NewDisk()
+	c.partitions = func(ctx context.Context, all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{{Mountpoint: "/"}, {Mountpoint: "/"}, {Mountpoint: "/broken"}}, nil
+	}
+	c.usage = func(ctx context.Context, path string) (*disk.UsageStat, error) {
+		if path == "/broken" {
+			return nil, errors.New("stale mount")
+		}
+		return &disk.UsageStat{Total: 100, Used: 25, Free: 75, UsedPercent: 25}, nil
+	}
+	res, err := c.Collect(context.Background())
+	assert.Error(t, err, "usage error is reported")
+	assert.Equal(t, map[string]string{
+		`DiskTotal{mount="/"}`:       "gauge:100",
+		`DiskUsed{mount="/"}`:        "gauge:25",
+		`DiskFree{mount="/"}`:        "gauge:75",
+		`DiskUsedPercent{mount="/"}`: "gauge:25",
+	}, values(t, res))
+}
+
+func Test_Load(t *testing.T) {
+	c := NewLoad()
+	c.avg = func(ctx context.Context) (*load.AvgStat, error) {
+		return &load.AvgStat{Load1: 0.5, Load5: 1, Load15: 1.5}, nil
+	}
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Load1": "gauge:0.5", "Load5": "gauge:1", "Load15": "gauge:1.5"}, values(t, res))
+}
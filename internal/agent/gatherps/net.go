@@ -0,0 +1,80 @@
+package gatherps
+
+import (
+	"context"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// Net - per interface bytes, packets and errors, deltas between polls with label interface
+type Net struct {
+	counters func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error)
+	deltas   *deltas
+}
+
+func NewNet() *Net {
+	return &Net{
+		counters: net.IOCountersWithContext,
+		deltas:   newDeltas(),
+	}
+}
+
+func (c *Net) Name() string {
+	return "net"
+}
+
+func (c *Net) Interval() time.Duration {
+	return 0
+}
+
+func (c *Net) Collect(ctx context.Context) ([]models.Metrics, error) {
+	stats, err := c.counters(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	var res []models.Metrics
+	c.deltas.begin()
+	for _, s := range stats {
+		res = c.deltas.add(res, "NetBytesSent", "interface", s.Name, s.BytesSent)
+		res = c.deltas.add(res, "NetBytesRecv", "interface", s.Name, s.BytesRecv)
+		res = c.deltas.add(res, "NetPacketsSent", "interface", s.Name, s.PacketsSent)
+		res = c.deltas.add(res, "NetPacketsRecv", "interface", s.Name, s.PacketsRecv)
+		res = c.deltas.add(res, "NetErrIn", "interface", s.Name, s.Errin)
+		res = c.deltas.add(res, "NetErrOut", "interface", s.Name, s.Errout)
+	}
+	c.deltas.end()
+	return res, nil
+}
+
+// Load - 1, 5 and 15 minute load average
+type Load struct {
+	avg func(ctx context.Context) (*load.AvgStat, error)
+}
+
+func NewLoad() *Load {
+	return &Load{avg: load.AvgWithContext}
+}
+
+func (c *Load) Name() string {
+	return "load"
+}
+
+func (c *Load) Interval() time.Duration {
+	return 0
+}
+
+func (c *Load) Collect(ctx context.Context) ([]models.Metrics, error) {
+	a, err := c.avg(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []models.Metrics{
+		collector.Gauge("Load1", a.Load1),
+		collector.Gauge("Load5", a.Load5),
+		collector.Gauge("Load15", a.Load15),
+	}, nil
+}
@@ -0,0 +1,213 @@
+package gatherps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// proc - part of *process.Process used by collector
+type proc interface {
+	NameWithContext(ctx context.Context) (string, error)
+	CmdlineWithContext(ctx context.Context) (string, error)
+	PercentWithContext(ctx context.Context, interval time.Duration) (float64, error)
+	MemoryInfoWithContext(ctx context.Context) (*process.MemoryInfoStat, error)
+	NumFDsWithContext(ctx context.Context) (int32, error)
+	NumThreadsWithContext(ctx context.Context) (int32, error)
+	CreateTimeWithContext(ctx context.Context) (int64, error)
+}
+
+type processMatcher struct {
+	cmdline *regexp.Regexp
+	label   string
+	name    string
+	pidfile string
+}
+
+type cachedProc struct {
+	p       proc
+	created int64
+}
+
+// Process - cpu, memory, descriptors, threads and uptime of configured processes
+// with labels process and pid. ProcessCount is reported for every matcher
+type Process struct {
+	list     func(ctx context.Context) (map[int32]proc, error)
+	byPid    func(ctx context.Context, pid int32) (proc, error)
+	now      func() time.Time
+	cache    map[int32]cachedProc
+	matchers []processMatcher
+}
+
+func NewProcess(cfg *config.Config) (*Process, error) {
+	c := &Process{
+		list:  listProcesses,
+		byPid: func(ctx context.Context, pid int32) (proc, error) { return process.NewProcessWithContext(ctx, pid) },
+		now:   time.Now,
+		cache: make(map[int32]cachedProc),
+	}
+	for _, m := range cfg.Processes {
+		pm := processMatcher{label: m.Label, name: m.Name, pidfile: m.Pidfile}
+		switch {
+		case m.Name != "":
+			if pm.label == "" {
+				pm.label = m.Name
+			}
+		case m.Cmdline != "":
+			re, err := regexp.Compile(m.Cmdline)
+			if err != nil {
+				return nil, fmt.Errorf("process cmdline %q: %w", m.Cmdline, err)
+			}
+			pm.cmdline = re
+			if pm.label == "" {
+				pm.label = m.Cmdline
+			}
+		case m.Pidfile != "":
+			if pm.label == "" {
+				pm.label = strings.TrimSuffix(filepath.Base(m.Pidfile), ".pid")
+			}
+		default:
+			return nil, errors.New("process matcher without name, cmdline or pidfile")
+		}
+		c.matchers = append(c.matchers, pm)
+	}
+	return c, nil
+}
+
+func listProcesses(ctx context.Context) (map[int32]proc, error) {
+	ps, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int32]proc, len(ps))
+	for _, p := range ps {
+		res[p.Pid] = p
+	}
+	return res, nil
+}
+
+func (c *Process) Name() string {
+	return "process"
+}
+
+func (c *Process) Interval() time.Duration {
+	return 0
+}
+
+func readPidfile(name string) (int32, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("pidfile %s: %w", name, err)
+	}
+	return int32(pid), nil
+}
+
+// match - pids of processes matched by m
+func (c *Process) match(ctx context.Context, m processMatcher, all map[int32]proc) (map[int32]proc, error) {
+	res := make(map[int32]proc)
+	if m.pidfile != "" {
+		pid, err := readPidfile(m.pidfile)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return res, nil
+			}
+			return res, err
+		}
+		if p, ok := all[pid]; ok {
+			res[pid] = p
+		} else if p, err := c.byPid(ctx, pid); err == nil {
+			res[pid] = p
+		}
+		return res, nil
+	}
+	for pid, p := range all {
+		if m.name != "" {
+			if name, err := p.NameWithContext(ctx); err == nil && name == m.name {
+				res[pid] = p
+			}
+			continue
+		}
+		if cmd, err := p.CmdlineWithContext(ctx); err == nil && m.cmdline.MatchString(cmd) {
+			res[pid] = p
+		}
+	}
+	return res, nil
+}
+
+// cached - process object of previous poll keeps cpu times for percent,
+// pid reused by other process gets new object
+func (c *Process) cached(ctx context.Context, pid int32, p proc, seen map[int32]cachedProc) (proc, int64) {
+	created, _ := p.CreateTimeWithContext(ctx)
+	if cp, ok := c.cache[pid]; ok && cp.created == created {
+		p = cp.p
+	}
+	seen[pid] = cachedProc{p: p, created: created}
+	return p, created
+}
+
+func (c *Process) Collect(ctx context.Context) ([]models.Metrics, error) {
+	if len(c.matchers) == 0 {
+		return nil, nil
+	}
+	all, err := c.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res []models.Metrics
+	var errs []error
+	seen := make(map[int32]cachedProc)
+	for _, m := range c.matchers {
+		procs, errM := c.match(ctx, m, all)
+		if errM != nil {
+			errs = append(errs, errM)
+		}
+		res = append(res, labeledGauge("ProcessCount", "process", m.label, float64(len(procs))))
+		for pid, p := range procs {
+			p, created := c.cached(ctx, pid, p, seen)
+			res = append(res, c.procMetrics(ctx, m.label, pid, p, created)...)
+		}
+	}
+	c.cache = seen
+	return res, errors.Join(errs...)
+}
+
+func (c *Process) procMetrics(ctx context.Context, label string, pid int32, p proc, created int64) []models.Metrics {
+	labels := map[string]string{"process": label, "pid": strconv.Itoa(int(pid))}
+	var res []models.Metrics
+	add := func(name string, v float64) {
+		m := collector.Gauge(name, v)
+		m.Labels = labels
+		res = append(res, m)
+	}
+	if v, err := p.PercentWithContext(ctx, 0); err == nil {
+		add("ProcessCPUPercent", v)
+	}
+	if v, err := p.MemoryInfoWithContext(ctx); err == nil {
+		add("ProcessRSS", float64(v.RSS))
+	}
+	if v, err := p.NumFDsWithContext(ctx); err == nil {
+		add("ProcessOpenFDs", float64(v))
+	}
+	if v, err := p.NumThreadsWithContext(ctx); err == nil {
+		add("ProcessThreads", float64(v))
+	}
+	if created > 0 {
+		add("ProcessUptime", c.now().Sub(time.UnixMilli(created)).Seconds())
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package gatherps
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProc struct {
+	name    string
+	cmdline string
+	created int64
+	polls   int
+}
+
+func (f *fakeProc) NameWithContext(ctx context.Context) (string, error)    { return f.name, nil }
+func (f *fakeProc) CmdlineWithContext(ctx context.Context) (string, error) { return f.cmdline, nil }
+func (f *fakeProc) PercentWithContext(ctx context.Context, interval time.Duration) (float64, error) {
+	f.polls++
+	return float64(f.polls * 10), nil
+}
+func (f *fakeProc) MemoryInfoWithContext(ctx context.Context) (*process.MemoryInfoStat, error) {
+	return &process.MemoryInfoStat{RSS: 4096}, nil
+}
+func (f *fakeProc) NumFDsWithContext(ctx context.Context) (int32, error)     { return 12, nil }
+func (f *fakeProc) NumThreadsWithContext(ctx context.Context) (int32, error) { return 3, nil }
+func (f *fakeProc) CreateTimeWithContext(ctx context.Context) (int64, error) { return f.created, nil }
+
+func Test_ProcessCollect(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	procs := map[int32]proc{
+		10: &fakeProc{name: "nginx", cmdline: "nginx: master", created: start.UnixMilli()},
+		11: &fakeProc{name: "nginx", cmdline: "nginx: worker", created: start.UnixMilli()},
+		20: &fakeProc{name: "java", cmdline: "java -jar billing.jar", created: start.UnixMilli()},
+	}
+	c, err := NewProcess(&config.Config{Processes: []config.ProcessMatch{
+		{Name: "nginx"},
+		{Label: "billing", Cmdline: `billing\.jar`},
+		{Name: "redis"},
+	}})
+	require.NoError(t, err)
+	c.list = func(ctx context.Context) (map[int32]proc, error) { return procs, nil }
+	c.now = func() time.Time { return start.Add(time.Minute) }
+
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	got := values(t, res)
+	assert.Equal(t, "gauge:2", got[`ProcessCount{process="nginx"}`])
+	assert.Equal(t, "gauge:1", got[`ProcessCount{process="billing"}`])
+	assert.Equal(t, "gauge:0", got[`ProcessCount{process="redis"}`])
+	assert.Equal(t, "gauge:4096", got[`ProcessRSS{pid="20",process="billing"}`])
+	assert.Equal(t, "gauge:12", got[`ProcessOpenFDs{pid="10",process="nginx"}`])
+	assert.Equal(t, "gauge:3", got[`ProcessThreads{pid="11",process="nginx"}`])
+	assert.Equal(t, "gauge:60", got[`ProcessUptime{pid="10",process="nginx"}`])
+	assert.Equal(t, "gauge:10", got[`ProcessCPUPercent{pid="10",process="nginx"}`])
+
+	// new object of same process keeps cpu state of previous poll
+	procs[10] = &fakeProc{name: "nginx", created: start.UnixMilli()}
+	// pid reused by other process
+	procs[11] = &fakeProc{name: "nginx", created: start.Add(time.Second).UnixMilli()}
+	res, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	got = values(t, res)
+	assert.Equal(t, "gauge:20", got[`ProcessCPUPercent{pid="10",process="nginx"}`])
+	assert.Equal(t, "gauge:10", got[`ProcessCPUPercent{pid="11",process="nginx"}`])
+}
+
+func Test_ProcessPidfile(t *testing.T) {
+	dir := t.TempDir()
+	pidfile := filepath.Join(dir, "agent.pid")
+	require.NoError(t, os.WriteFile(pidfile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600))
+
+	c, err := NewProcess(&config.Config{Processes: []config.ProcessMatch{
+		{Pidfile: pidfile},
+		{Pidfile: filepath.Join(dir, "missing.pid")},
+	}})
+	require.NoError(t, err)
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	got := values(t, res)
+	pid := strconv.Itoa(os.Getpid())
+	assert.Equal(t, "gauge:1", got[`ProcessCount{process="agent"}`])
+	assert.Equal(t, "gauge:0", got[`ProcessCount{process="missing"}`])
+	assert.Contains(t, got, `ProcessRSS{pid="`+pid+`",process="agent"}`)
+	assert.Contains(t, got, `ProcessThreads{pid="`+pid+`",process="agent"}`)
+}
+
+func Test_NewProcessBadRegexp(t *testing.T) {
+	_, err := NewProcess(&config.Config{Processes: []config.ProcessMatch{{Cmdline: "("}}})
+	assert.Error(t, err)
+}
@@ -4,28 +4,27 @@ package grpcclient
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net"
-	"os"
 	"sync"
 	"time"
 
+	"github.com/4aleksei/metricscum/internal/common/grpcmetrics/grpcsign"
 	pb "github.com/4aleksei/metricscum/internal/common/grpcmetrics/proto"
 	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
 
 	"crypto/rsa"
-	"crypto/x509"
 
 	"github.com/4aleksei/metricscum/internal/agent/config"
-	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
-	"github.com/4aleksei/metricscum/internal/common/utils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"github.com/4aleksei/metricscum/internal/common/job"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type (
@@ -49,13 +48,17 @@ type (
 	}
 )
 
-func newClientInstance(cfg *config.Config, p *rsa.PublicKey) *clientInstance {
+func newClientInstance(cfg *config.Config, p *rsa.PublicKey) (*clientInstance, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return &clientInstance{
 		execFn:    poolOptions(cfg),
-		client:    newClient(cfg),
+		client:    client,
 		cfg:       cfg,
 		publicKey: p,
-	}
+	}, nil
 }
 
 func poolOptions(cfg *config.Config) functioExec {
@@ -70,53 +73,52 @@ func poolOptions(cfg *config.Config) functioExec {
 	}
 }
 
+// loadCert - TLS if server CA or client certificate is set, server name is taken from address
 func loadCert(cfg *config.Config) (grpc.DialOption, error) {
-
-	if cfg.CertKeyFile != "" {
-		caCert, err := os.ReadFile(cfg.CertKeyFile)
-		if err != nil {
-			return grpc.WithTransportCredentials(insecure.NewCredentials()), err
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-		// Create TLS configuration
-		tlsCredentials := credentials.NewClientTLSFromCert(caCertPool, "") // "localhost" must match server certificate's CN or SAN
-
-		return grpc.WithTransportCredentials(tlsCredentials), nil
+	tlsConfig, err := tlsconfig.Client(cfg.CertKeyFile, cfg.ClientCertFile, cfg.ClientKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), nil
 	}
 
 	return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
 }
 
-func newClient(cfg *config.Config) *agentClient {
+func newClient(cfg *config.Config) (*agentClient, error) {
 	agclient := &agentClient{}
 	myDialer := net.Dialer{Timeout: 30 * time.Second,
 		KeepAlive: 30 * time.Second}
 
 	dialOpt, err := loadCert(cfg)
 	if err != nil {
-		fmt.Println("Error load cert ", err)
+		return nil, err
 	}
-	conn, err := grpc.NewClient(cfg.Address, dialOpt,
+	opts := []grpc.DialOption{dialOpt,
 		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
 			conn, err := myDialer.DialContext(ctx, "tcp", addr)
 			if err == nil {
 				agclient.localAddr = conn.LocalAddr().(*net.TCPAddr).IP.String()
 			}
 			return conn, err
-		}))
-
+		})}
+	if cfg.Key != "" {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(grpcsign.UnaryClientInterceptor(cfg.KeyID, []byte(cfg.Key))))
+	}
+	conn, err := grpc.NewClient(cfg.Address, opts...)
 	if err != nil {
-		fmt.Println("Error grpc.NewClient ", err)
+		return nil, err
 	}
 
 	c := pb.NewStreamMultiServiceClient(conn)
 	agclient.client = c
 	agclient.connection = conn
-	return agclient
+	return agclient, nil
 }
 
-func NewgRPC(cfg *config.Config) *GRPCPool {
+// NewgRPC - pool of clients, fails if TLS files can not be loaded
+func NewgRPC(cfg *config.Config) (*GRPCPool, error) {
 	p := &GRPCPool{
 		WorkerCount: int(cfg.RateLimit),
 		cfg:         cfg,
@@ -124,9 +126,13 @@ func NewgRPC(cfg *config.Config) *GRPCPool {
 	}
 
 	for i := 0; i < p.WorkerCount; i++ {
-		p.clients[i] = *newClientInstance(cfg, nil)
+		c, err := newClientInstance(cfg, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.clients[i] = *c
 	}
-	return p
+	return p, nil
 }
 
 func (p *GRPCPool) GracefulStop() {
@@ -149,7 +155,9 @@ func workerBatch(ctx context.Context, c *clientInstance, wg *sync.WaitGroup, job
 		case <-ctx.Done():
 			return
 		default:
-			err := sendBatch(ctx, c.client, j.Value)
+			err := c.cfg.Retry.Do(ctx, func(ctx context.Context) error {
+				return sendBatch(ctx, c.client, j.Value)
+			}, ProbeGRPC)
 			if err != nil && errors.Is(err, context.Canceled) {
 				return
 			}
@@ -169,7 +177,9 @@ func workerSingle(ctx context.Context, c *clientInstance, wg *sync.WaitGroup, jo
 		case <-ctx.Done():
 			return
 		default:
-			err := sendSingle(ctx, c.client, &j.Value[0])
+			err := c.cfg.Retry.Do(ctx, func(ctx context.Context) error {
+				return sendSingle(ctx, c.client, &j.Value[0])
+			}, ProbeGRPC)
 			if err != nil && errors.Is(err, context.Canceled) {
 				return
 			}
@@ -182,16 +192,24 @@ func workerSingle(ctx context.Context, c *clientInstance, wg *sync.WaitGroup, jo
 	}
 }
 
+// Probe - see ProbeGRPC
+func (p *GRPCPool) Probe(err error) bool {
+	return ProbeGRPC(err)
+}
+
+// ProbeGRPC - transient codes are retryable
+func ProbeGRPC(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
 func sendSingle(ctx context.Context, client *agentClient, data *models.Metrics) error {
-	k, _ := valuemetric.GetKind(data.MType)
 	md := metadata.New(map[string]string{"X-Real-IP": client.localAddr})
 	ctxReq := metadata.NewOutgoingContext(ctx, md)
-	_, err := client.client.UpdateRequest(ctxReq, &pb.Request{Value: &pb.Metric{
-		Name:    data.ID,
-		Counter: utils.Setint64(data.Delta),
-		Gauge:   utils.Setfloat64(data.Value),
-		Type:    pb.Metric_Type(k),
-	}},
+	_, err := client.client.UpdateRequest(ctxReq, &pb.Request{Value: data.ConvertModelToProto()},
 		grpc.UseCompressor(gzip.Name))
 
 	if err != nil {
@@ -203,13 +221,7 @@ func sendSingle(ctx context.Context, client *agentClient, data *models.Metrics)
 func sendBatch(ctx context.Context, client *agentClient, data []models.Metrics) error {
 	var metrics []*pb.Metric
 	for _, val := range data {
-		k, _ := valuemetric.GetKind(val.MType)
-		metrics = append(metrics, &pb.Metric{
-			Name:    val.ID,
-			Counter: utils.Setint64(val.Delta),
-			Gauge:   utils.Setfloat64(val.Value),
-			Type:    pb.Metric_Type(k),
-		})
+		metrics = append(metrics, val.ConvertModelToProto())
 	}
 	md := metadata.New(map[string]string{"X-Real-IP": client.localAddr})
 	ctxReq := metadata.NewOutgoingContext(ctx, md)
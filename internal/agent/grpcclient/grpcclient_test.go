@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -13,7 +14,9 @@ import (
 	"github.com/4aleksei/metricscum/internal/common/models"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -103,3 +106,32 @@ func Test_GRCP(t *testing.T) {
 	wg.Wait()
 	close(results)
 }
+
+func Test_ProbeGRPC(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: status.Error(codes.Unavailable, "down"), want: true},
+		{err: status.Error(codes.ResourceExhausted, "busy"), want: true},
+		{err: status.Error(codes.DeadlineExceeded, "slow"), want: true},
+		{err: status.Error(codes.InvalidArgument, "bad"), want: false},
+		{err: status.Error(codes.Unauthenticated, "key"), want: false},
+		{err: context.Canceled, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, ProbeGRPC(tt.err))
+		})
+	}
+}
+
+func Test_NewgRPCBadTLS(t *testing.T) {
+	cfg := &config.Config{
+		Address:     "localhost:3200",
+		RateLimit:   1,
+		CertKeyFile: filepath.Join(t.TempDir(), "missing.pem"),
+	}
+	_, err := NewgRPC(cfg)
+	assert.Error(t, err, "no fallback to system roots")
+}
@@ -2,16 +2,17 @@
 package httpaes
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"io"
 	"os"
+
+	"github.com/4aleksei/metricscum/internal/common/middleware/aescoder"
 )
 
 var (
@@ -28,49 +29,41 @@ func generateRandom(size int) ([]byte, error) {
 	return b, nil
 }
 
+// AesWriter - aescoder envelope with random key, Close must be called after last Write
 type AesWriter struct {
-	w      io.Writer
-	nonce  []byte
-	aesgcm cipher.AEAD
-	key    string
+	ew  *aescoder.EnvelopeWriter
+	key string
 }
 
+// NewWriter - key of envelope is wrapped with RSA-OAEP SHA-256, see GetKey
 func NewWriter(w io.Writer, pub *rsa.PublicKey) (*AesWriter, error) {
-	key, err := generateRandom(2 * aes.BlockSize)
-	if err != nil {
-		return nil, err
-	}
-	cipherKeyLoaded, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
-
+	key, err := generateRandom(aescoder.KeySize)
 	if err != nil {
 		return nil, err
 	}
-	aesblock, err := aes.NewCipher(key)
+	cipherKeyLoaded, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	aesgcm, err := cipher.NewGCM(aesblock)
+	ew, err := aescoder.NewEnvelopeWriter(w, key)
 	if err != nil {
 		return nil, err
 	}
-
 	return &AesWriter{
-		w:      w,
-		aesgcm: aesgcm,
-		nonce:  key[len(key)-aesgcm.NonceSize():],
-		key:    hex.EncodeToString(cipherKeyLoaded),
+		ew:  ew,
+		key: hex.EncodeToString(cipherKeyLoaded),
 	}, nil
 }
 
 func (a *AesWriter) Write(p []byte) (int, error) {
-	if p == nil {
-		return 0, nil
-	}
-	_ = a.aesgcm.Seal(p[0:], a.nonce, p, nil)
-	return a.w.Write(p)
+	return a.ew.Write(p)
+}
+
+func (a *AesWriter) Close() error {
+	return a.ew.Close()
 }
 
+// GetKey - value of aescoder.HeaderEnvelope header
 func (a *AesWriter) GetKey() string {
 	return a.key
 }
@@ -4,6 +4,7 @@ package httpclientpool
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"sync"
 
 	"net"
+	"net/url"
 	"time"
 
 	"bytes"
@@ -21,8 +23,10 @@ import (
 	"github.com/4aleksei/metricscum/internal/common/job"
 
 	"github.com/4aleksei/metricscum/internal/agent/handlers/httpclientpool/httpaes"
+	"github.com/4aleksei/metricscum/internal/common/middleware/aescoder"
 	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
 	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
 	"github.com/4aleksei/metricscum/internal/common/utils"
 )
 
@@ -61,7 +65,8 @@ type (
 	}
 )
 
-func NewHandler(cfg *config.Config) *PoolHandler {
+// NewHandler - pool of clients, fails if TLS files can not be loaded
+func NewHandler(cfg *config.Config) (*PoolHandler, error) {
 	p := new(PoolHandler)
 	p.WorkerCount = int(cfg.RateLimit)
 	p.clients = make([]clientInstance, p.WorkerCount)
@@ -78,18 +83,26 @@ func NewHandler(cfg *config.Config) *PoolHandler {
 	}
 
 	for i := 0; i < p.WorkerCount; i++ {
-		p.clients[i] = *newClientInstance(cfg, p.publicKey)
+		c, err := newClientInstance(cfg, p.publicKey)
+		if err != nil {
+			return nil, err
+		}
+		p.clients[i] = *c
 	}
-	return p
+	return p, nil
 }
 
-func newClientInstance(cfg *config.Config, p *rsa.PublicKey) *clientInstance {
+func newClientInstance(cfg *config.Config, p *rsa.PublicKey) (*clientInstance, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return &clientInstance{
 		execFn:    poolOptions(cfg),
-		client:    newClient(),
+		client:    client,
 		cfg:       cfg,
 		publicKey: p,
-	}
+	}, nil
 }
 
 func poolOptions(cfg *config.Config) functioExec {
@@ -104,12 +117,22 @@ func poolOptions(cfg *config.Config) functioExec {
 	}
 }
 
-func newClient() *agentClient {
+// newClient - HTTPS client verifies server with CA of crypto-cert and sends client certificate if set
+func newClient(cfg *config.Config) (*agentClient, error) {
 	connection := &net.Dialer{
 		Timeout: 2 * time.Second,
 	}
 	agclient := &agentClient{}
 
+	var tlsConfig *tls.Config
+	if cfg.HTTPS {
+		var err error
+		tlsConfig, err = tlsconfig.Client(cfg.CertKeyFile, cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var netTransport = &http.Transport{
 		Dial: func(network, addr string) (net.Conn, error) {
 			conn, err := connection.Dial(network, addr)
@@ -119,26 +142,35 @@ func newClient() *agentClient {
 			return conn, err
 		},
 		TLSHandshakeTimeout: 2 * time.Second,
+		TLSClientConfig:     tlsConfig,
 	}
 
 	agclient.client = &http.Client{
 		Transport: netTransport,
 	}
-	return agclient
+	return agclient, nil
+}
+
+// baseURL - scheme and address of server
+func baseURL(cfg *config.Config) string {
+	if cfg.HTTPS {
+		return "https://" + cfg.Address
+	}
+	return "http://" + cfg.Address
 }
 
 func workerJSONBatch(ctx context.Context, wg *sync.WaitGroup, client *agentClient,
 
 	jobs <-chan job.Job, results chan<- job.Result, cfg *config.Config, pub *rsa.PublicKey) {
 	defer wg.Done()
-	server := "http://" + cfg.Address + "/updates/"
+	server := baseURL(cfg) + "/updates/"
 	for j := range jobs {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 
-			err := jsonModelSFunc(ctx, server, client, j.Value, cfg.Key, pub)
+			err := jsonModelSFunc(ctx, server, client, j.Value, cfg, pub)
 			if err != nil && errors.Is(err, context.Canceled) {
 				return
 			}
@@ -154,13 +186,13 @@ func workerJSONBatch(ctx context.Context, wg *sync.WaitGroup, client *agentClien
 func workerJSON(ctx context.Context, wg *sync.WaitGroup, client *agentClient,
 	jobs <-chan job.Job, results chan<- job.Result, cfg *config.Config, pub *rsa.PublicKey) {
 	defer wg.Done()
-	server := "http://" + cfg.Address + "/update/"
+	server := baseURL(cfg) + "/update/"
 	for j := range jobs {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			err := jsonModelFunc(ctx, server, client, &j.Value[0], cfg.Key, pub)
+			err := jsonModelFunc(ctx, server, client, &j.Value[0], cfg, pub)
 			if err != nil && errors.Is(err, context.Canceled) {
 				return
 			}
@@ -176,14 +208,14 @@ func workerJSON(ctx context.Context, wg *sync.WaitGroup, client *agentClient,
 func workerPlain(ctx context.Context, wg *sync.WaitGroup, client *agentClient,
 	jobs <-chan job.Job, results chan<- job.Result, cfg *config.Config, pub *rsa.PublicKey) {
 	defer wg.Done()
-	server := "http://" + cfg.Address + "/update/"
+	server := baseURL(cfg) + "/update/"
 	for j := range jobs {
 		select {
 		case <-ctx.Done():
 			return
 		default:
 			data := j.Value[0].MType + "/" + j.Value[0].ID + "/" + j.Value[0].ConvertMetricToValue()
-			err := plainTxtFunc(ctx, client, server, data)
+			err := plainTxtFunc(ctx, client, server, data, cfg)
 			if err != nil && errors.Is(err, context.Canceled) {
 				return
 			}
@@ -202,6 +234,11 @@ func (p *PoolHandler) GracefulStop() {
 	}
 }
 
+// Probe - see utils.ProbeHTTP
+func (p *PoolHandler) Probe(err error) bool {
+	return utils.ProbeHTTP(err)
+}
+
 func (p *PoolHandler) StartPool(ctx context.Context, jobs chan job.Job, results chan job.Result, wg *sync.WaitGroup) {
 	for i := 0; i < p.WorkerCount; i++ {
 		wg.Add(1)
@@ -209,103 +246,131 @@ func (p *PoolHandler) StartPool(ctx context.Context, jobs chan job.Job, results
 	}
 }
 
-func plainTxtFunc(ctx context.Context, client *agentClient, server, data string) error {
-	err := utils.RetryAction(ctx, utils.RetryTimes(), func(ctx context.Context) error {
-		return newPPostReq(ctx, client, server+data, http.NoBody)
-	})
+func plainTxtFunc(ctx context.Context, client *agentClient, server, data string, cfg *config.Config) error {
+	err := cfg.Retry.Do(ctx, func(ctx context.Context) error {
+		return newPPostReq(ctx, client, server+data, http.NoBody, cfg.KeyID, cfg.Key)
+	}, utils.ProbeHTTP)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func jsonModelFunc(ctx context.Context, server string, client *agentClient, data *models.Metrics, cfgkey string, pub *rsa.PublicKey) error {
-	var requestBody bytes.Buffer
-	var aeskey string
-	var key string
-	var twr io.Writer
-
-	gz := gzip.NewWriter(&requestBody)
-	var hmac *hmacsha256.HmacWriter
-	if cfgkey != "" {
-		hmac = hmacsha256.NewWriter(gz, []byte(cfgkey))
-		twr = hmac
-	} else {
-		twr = gz
+// encodeBody - JSON is compressed and then sealed in envelope, returned header holds key of envelope.
+// Plain JSON is returned for signature, server opens envelope, decompresses and checks signature
+// of JSON in reverse order
+func encodeBody(pub *rsa.PublicKey, encode func(w io.Writer) error) ([]byte, []byte, http.Header, error) {
+	var plain bytes.Buffer
+	if err := encode(&plain); err != nil {
+		return nil, nil, nil, err
 	}
 
+	var requestBody bytes.Buffer
+	var out io.Writer = &requestBody
+	header := make(http.Header)
+
+	var aestwr *httpaes.AesWriter
 	if pub != nil {
-		aestwr, err := httpaes.NewWriter(twr, pub)
+		var err error
+		aestwr, err = httpaes.NewWriter(&requestBody, pub)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
-		twr = aestwr
-		aeskey = aestwr.GetKey()
+		out = aestwr
+		header.Set(aescoder.HeaderEnvelope, aestwr.GetKey())
 	}
 
-	err := data.JSONEncodeBytes(twr)
-	if err != nil {
-		return err
+	gz := gzip.NewWriter(out)
+	if _, err := gz.Write(plain.Bytes()); err != nil {
+		return nil, nil, nil, err
 	}
-	gz.Close()
-
-	if hmac != nil {
-		key = hex.EncodeToString(hmac.GetSig())
+	if err := gz.Close(); err != nil {
+		return nil, nil, nil, err
+	}
+	if aestwr != nil {
+		if err := aestwr.Close(); err != nil {
+			return nil, nil, nil, err
+		}
 	}
+	return requestBody.Bytes(), plain.Bytes(), header, nil
+}
 
-	err = utils.RetryAction(ctx, utils.RetryTimes(), func(ctx context.Context) error {
-		return newJPostReq(ctx, client, server, &requestBody, key, aeskey)
-	})
+func jsonModelFunc(ctx context.Context, server string, client *agentClient, data *models.Metrics, cfg *config.Config, pub *rsa.PublicKey) error {
+	body, plain, header, err := encodeBody(pub, data.JSONEncodeBytes)
 	if err != nil {
 		return err
 	}
-	return nil
+	return postJSON(ctx, server, client, cfg, body, plain, header)
 }
 
-func jsonModelSFunc(ctx context.Context, server string, client *agentClient, data []models.Metrics, cfgkey string, pub *rsa.PublicKey) error {
-	var requestBody bytes.Buffer
-	var key string
-	var twr io.Writer
-	var aeskey string
-	gz := gzip.NewWriter(&requestBody)
-	var hmac *hmacsha256.HmacWriter
-
-	if cfgkey != "" {
-		hmac = hmacsha256.NewWriter(gz, []byte(cfgkey))
-		twr = hmac
-	} else {
-		twr = gz
+func jsonModelSFunc(ctx context.Context, server string, client *agentClient, data []models.Metrics, cfg *config.Config, pub *rsa.PublicKey) error {
+	body, plain, header, err := encodeBody(pub, func(w io.Writer) error {
+		return models.JSONSEncodeBytes(w, data)
+	})
+	if err != nil {
+		return err
 	}
+	return postJSON(ctx, server, client, cfg, body, plain, header)
+}
 
-	if pub != nil {
-		aestwr, err := httpaes.NewWriter(twr, pub)
-		if err != nil {
-			return err
-		}
-		twr = aestwr
-		aeskey = aestwr.GetKey()
+func requestURI(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
 	}
+	return u.RequestURI(), nil
+}
 
-	err := models.JSONSEncodeBytes(twr, data)
+// signBody - signature of method, target, new timestamp and nonce and plain body in header.
+// Empty keyID is legacy shared key
+func signBody(header http.Header, method, target string, plain []byte, keyID, key string) error {
+	timestamp, nonce, err := hmacsha256.NewNonce(time.Now())
 	if err != nil {
 		return err
 	}
-	gz.Close()
-
-	if hmac != nil {
-		key = hex.EncodeToString(hmac.GetSig())
+	hmac := hmacsha256.NewWriter(io.Discard, []byte(key))
+	hmac.Seed(hmacsha256.SignedPrefix(method, target, timestamp, nonce))
+	if _, err := hmac.Write(plain); err != nil {
+		return err
+	}
+	header.Set(hmacsha256.HeaderTimestamp, timestamp)
+	header.Set(hmacsha256.HeaderNonce, nonce)
+	header.Set(hmacsha256.HeaderHash, hex.EncodeToString(hmac.GetSig()))
+	if keyID != "" {
+		header.Set(hmacsha256.HeaderKeyID, keyID)
 	}
+	return nil
+}
 
-	err = utils.RetryAction(ctx, utils.RetryTimes(), func(ctx context.Context) error {
-		return newJPostReq(ctx, client, server, &requestBody, key, aeskey)
-	})
+// signPlain - plain request has no body, signature covers method, target, timestamp and nonce
+func signPlain(req *http.Request, keyID, key string) error {
+	return signBody(req.Header, req.Method, req.URL.RequestURI(), nil, keyID, key)
+}
+
+// postJSON - every attempt is signed with new timestamp and nonce, server accepts nonce once
+// even if request failed after signature check
+func postJSON(ctx context.Context, server string, client *agentClient, cfg *config.Config, body, plain []byte, header http.Header) error {
+	target, err := requestURI(server)
+	if err != nil {
+		return err
+	}
+	err = cfg.Retry.Do(ctx, func(ctx context.Context) error {
+		attempt := header.Clone()
+		if cfg.Key != "" {
+			if err := signBody(attempt, http.MethodPost, target, plain, cfg.KeyID, cfg.Key); err != nil {
+				return err
+			}
+		}
+		// every attempt reads body from the start
+		return newJPostReq(ctx, client, server, bytes.NewReader(body), attempt)
+	}, utils.ProbeHTTP)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func newPPostReq(ctx context.Context, client *agentClient, server string, requestBody io.Reader) error {
+func newPPostReq(ctx context.Context, client *agentClient, server string, requestBody io.Reader, keyID, key string) error {
 	req, err := http.NewRequestWithContext(ctx, "POST", server, requestBody)
 
 	if err != nil {
@@ -313,6 +378,12 @@ func newPPostReq(ctx context.Context, client *agentClient, server string, reques
 	}
 	req.Header.Set("Content-Type", textPlainContent)
 
+	if key != "" {
+		if err := signPlain(req, keyID, key); err != nil {
+			return err
+		}
+	}
+
 	if client.localAddr != "" {
 		req.Header.Set("X-Real-IP", client.localAddr)
 	}
@@ -327,22 +398,18 @@ func newPPostReq(ctx context.Context, client *agentClient, server string, reques
 	if errcoppy != nil {
 		return err
 	}
-	return nil
+	return checkStatus(resp)
 }
 
-func newJPostReq(ctx context.Context, client *agentClient, server string, requestBody io.Reader, key string, aeskey string) error {
+func newJPostReq(ctx context.Context, client *agentClient, server string, requestBody io.Reader, header http.Header) error {
 	req, err := http.NewRequestWithContext(ctx, "POST", server, requestBody)
 
 	if err != nil {
 		return err
 	}
 
-	if key != "" {
-		req.Header.Set("HashSHA256", key)
-	}
-
-	if aeskey != "" {
-		req.Header.Set("AES-256", aeskey)
+	for k, v := range header {
+		req.Header[k] = v
 	}
 
 	if client.localAddr != "" {
@@ -362,5 +429,13 @@ func newJPostReq(ctx context.Context, client *agentClient, server string, reques
 	if errcoppy != nil {
 		return err
 	}
+	return checkStatus(resp)
+}
+
+// checkStatus - non 2xx response is utils.StatusError
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return &utils.StatusError{StatusCode: resp.StatusCode}
+	}
 	return nil
 }
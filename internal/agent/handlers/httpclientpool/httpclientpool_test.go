@@ -1,18 +1,34 @@
 package httpclientpool
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/4aleksei/metricscum/internal/agent/config"
 	"github.com/4aleksei/metricscum/internal/common/job"
+	"github.com/4aleksei/metricscum/internal/common/middleware/aescoder"
+	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
 	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig/tlstest"
+	"github.com/4aleksei/metricscum/internal/common/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_NewPool(t *testing.T) {
@@ -23,11 +39,31 @@ func Test_NewPool(t *testing.T) {
 	}
 
 	t.Run("Test NewPool", func(t *testing.T) {
-		p := NewHandler(cfg)
+		p, err := NewHandler(cfg)
+		require.NoError(t, err)
 		assert.NotNil(t, p)
 	})
 }
 
+func Test_NewPoolBadTLS(t *testing.T) {
+	f := tlstest.Generate(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	tests := []struct {
+		cfg  *config.Config
+		name string
+	}{
+		{name: "missing CA", cfg: &config.Config{RateLimit: 1, HTTPS: true, CertKeyFile: missing}},
+		{name: "missing client key", cfg: &config.Config{RateLimit: 1, HTTPS: true, CertKeyFile: f.CA,
+			ClientCertFile: f.ClientCert, ClientKeyFile: missing}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHandler(tt.cfg)
+			assert.Error(t, err, "no fallback to system roots or no client certificate")
+		})
+	}
+}
+
 func Test_Plain(t *testing.T) {
 	cfg := &config.Config{
 		Address:     "127.0.0.1:8081",
@@ -183,3 +219,171 @@ func Test_JsonBatch(t *testing.T) {
 	wg.Wait()
 	close(results)
 }
+
+func Test_JsonRetry(t *testing.T) {
+	var calls atomic.Int32
+	var sizes []int64
+	var mux sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mux.Lock()
+		sizes = append(sizes, int64(len(body)))
+		mux.Unlock()
+		switch calls.Add(1) {
+		case 1:
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			rw.WriteHeader(http.StatusOK)
+		default:
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Retry: utils.RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxRetries: 3},
+	}
+	client := &agentClient{client: server.Client()}
+	var valint int64 = 100
+	data := []models.Metrics{{ID: "TEst", MType: "counter", Delta: &valint}}
+
+	err := jsonModelSFunc(context.Background(), server.URL, client, data, cfg, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.NotZero(t, sizes[0])
+	assert.Equal(t, sizes[0], sizes[1], "body is resent on retry")
+
+	err = jsonModelSFunc(context.Background(), server.URL, client, data, cfg, nil)
+	var se *utils.StatusError
+	assert.ErrorAs(t, err, &se)
+	assert.Equal(t, http.StatusBadRequest, se.StatusCode)
+	assert.Equal(t, int32(3), calls.Load(), "client error is not retried")
+}
+
+func Test_JsonRetrySigned(t *testing.T) {
+	const key = "secret"
+	var calls atomic.Int32
+	var nonces []string
+	var mux sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// server checks signature and uses up nonce before handler stores metrics
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		timestamp, nonce := req.Header.Get(hmacsha256.HeaderTimestamp), req.Header.Get(hmacsha256.HeaderNonce)
+		hr := hmacsha256.NewReader(gz, []byte(key))
+		hr.Seed(hmacsha256.SignedPrefix(req.Method, req.URL.RequestURI(), timestamp, nonce))
+		_, _ = io.ReadAll(hr)
+		mux.Lock()
+		defer mux.Unlock()
+		if req.Header.Get(hmacsha256.HeaderHash) != hex.EncodeToString(hr.GetSig()) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, n := range nonces {
+			if n == nonce {
+				rw.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		nonces = append(nonces, nonce)
+		if calls.Add(1) == 1 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Key:   key,
+		Retry: utils.RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxRetries: 2},
+	}
+	client := &agentClient{client: server.Client()}
+	var valint int64 = 100
+	data := []models.Metrics{{ID: "TEst", MType: "counter", Delta: &valint}}
+
+	err := jsonModelSFunc(context.Background(), server.URL+"/updates/", client, data, cfg, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+	require.Len(t, nonces, 2)
+	assert.NotEqual(t, nonces[0], nonces[1], "retry is signed with new nonce")
+}
+
+func Test_encodeBody(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cfg := &config.Config{Key: "secret", KeyID: "agent-1"}
+	data := []byte(`[{"id":"a","type":"gauge","value":1}]`)
+
+	body, plain, header, err := encodeBody(&privateKey.PublicKey, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, signBody(header, http.MethodPost, "/updates/", plain, cfg.KeyID, cfg.Key))
+
+	// server opens envelope, decompresses and checks signature of JSON
+	wrapped, err := hex.DecodeString(header.Get(aescoder.HeaderEnvelope))
+	require.NoError(t, err)
+	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, wrapped, nil)
+	require.NoError(t, err)
+	er, err := aescoder.NewEnvelopeReader(io.NopCloser(bytes.NewReader(body)), aesKey)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(er)
+	require.NoError(t, err)
+	hr := hmacsha256.NewReader(gz, []byte(cfg.Key))
+	timestamp, nonce := header.Get(hmacsha256.HeaderTimestamp), header.Get(hmacsha256.HeaderNonce)
+	sent, err := hmacsha256.ParseNonce(timestamp, nonce)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), sent, time.Minute)
+	hr.Seed(hmacsha256.SignedPrefix(http.MethodPost, "/updates/", timestamp, nonce))
+	got, err := io.ReadAll(hr)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, header.Get(hmacsha256.HeaderHash), hex.EncodeToString(hr.GetSig()))
+	assert.Equal(t, "agent-1", header.Get(hmacsha256.HeaderKeyID))
+}
+
+func Test_PlainMutualTLS(t *testing.T) {
+	f := tlstest.Generate(t)
+	serverTLS, err := tlsconfig.Server(f.ServerCert, f.ServerKey, f.CA)
+	require.NoError(t, err)
+	agents := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		agents <- tlsconfig.PeerIdentity(req.TLS)
+		rw.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = serverTLS
+	server.StartTLS()
+	defer server.Close()
+
+	cfg := &config.Config{
+		Address:        server.Listener.Addr().String(),
+		RateLimit:      1,
+		HTTPS:          true,
+		CertKeyFile:    f.CA,
+		ClientCertFile: f.ClientCert,
+		ClientKeyFile:  f.ClientKey,
+	}
+	p, err := NewHandler(cfg)
+	require.NoError(t, err)
+
+	wg := &sync.WaitGroup{}
+	jobs := make(chan job.Job, 1)
+	results := make(chan job.Result, 1)
+	var valint int64 = 100
+	val := []models.Metrics{{ID: "TEst", MType: "counter", Delta: &valint}}
+
+	p.StartPool(context.Background(), jobs, results, wg)
+	jobs <- job.Job{ID: job.JobID(1), Value: val}
+	res := <-results
+	assert.NoError(t, res.Err)
+	assert.Equal(t, tlstest.ClientName, <-agents)
+
+	close(jobs)
+	wg.Wait()
+	close(results)
+}
@@ -3,6 +3,7 @@ package poolclients
 
 import (
 	"context"
+	"fmt"
 
 	"sync"
 
@@ -15,24 +16,55 @@ import (
 type PoolClientI interface {
 	StartPool(ctx context.Context, jobs chan job.Job, results chan job.Result, wg *sync.WaitGroup)
 	GracefulStop()
+	Probe(err error) bool // error of job is retryable for transport of pool
 }
 
 type PoolClient struct {
-	pool        PoolClientI
-	WorkerCount int
+	pool         PoolClientI
+	Name         string // destination address
+	WorkerCount  int
+	ContentBatch int64
 }
 
-func NewPoolClient(cfg *config.Config) *PoolClient {
+func NewPoolClient(cfg *config.Config) (*PoolClient, error) {
+	var (
+		pool PoolClientI
+		err  error
+	)
 	if cfg.Grpc {
-		return &PoolClient{
-			WorkerCount: int(cfg.RateLimit),
-			pool:        grpcclient.NewgRPC(cfg),
-		}
+		pool, err = grpcclient.NewgRPC(cfg)
 	} else {
-		return &PoolClient{
-			WorkerCount: int(cfg.RateLimit),
-			pool:        httpclientpool.NewHandler(cfg),
+		pool, err = httpclientpool.NewHandler(cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("destination %s: %w", cfg.Address, err)
+	}
+	return &PoolClient{
+		Name:         cfg.Address,
+		WorkerCount:  int(cfg.RateLimit),
+		ContentBatch: cfg.ContentBatch,
+		pool:         pool,
+	}, nil
+}
+
+// NewPoolClients - pool for every destination in priority order
+func NewPoolClients(cfg *config.Config) ([]*PoolClient, error) {
+	var res []*PoolClient
+	for _, c := range cfg.DestinationConfigs() {
+		p, err := NewPoolClient(c)
+		if err != nil {
+			return nil, err
 		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// New - facade for pool realization
+func New(pool PoolClientI, workerCount int) *PoolClient {
+	return &PoolClient{
+		WorkerCount: workerCount,
+		pool:        pool,
 	}
 }
 
@@ -43,3 +75,8 @@ func (po *PoolClient) StartPool(ctx context.Context, jobs chan job.Job, results
 func (po *PoolClient) GracefulStop() {
 	po.pool.GracefulStop()
 }
+
+// Probe - error of job is retryable, otherwise server rejected the job
+func (po *PoolClient) Probe(err error) bool {
+	return po.pool.Probe(err)
+}
@@ -0,0 +1,162 @@
+// Package probe - blackbox checks of HTTP and TCP endpoints
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+)
+
+// maxBody - read limit of probed response body
+const maxBody = 1 << 20
+
+// Probe - checks configured URLs and TCP endpoints concurrently. Gauges have labels probe (http, tcp) and target:
+// ProbeSuccess 0/1, ProbeDuration in seconds, ProbeHTTPStatus and
+// ProbeCertExpiry - seconds until earliest peer certificate expiry of https target
+type Probe struct {
+	client  *http.Client
+	now     func() time.Time
+	http    []string
+	tcp     []string
+	timeout time.Duration
+}
+
+func NewProbe(cfg *config.Config) *Probe {
+	timeout := time.Duration(cfg.ProbeTimeout) * time.Second
+	return &Probe{
+		client:  &http.Client{Timeout: timeout},
+		now:     time.Now,
+		http:    cfg.ProbeHTTP,
+		tcp:     cfg.ProbeTCP,
+		timeout: timeout,
+	}
+}
+
+func (c *Probe) Name() string {
+	return "probe"
+}
+
+func (c *Probe) Interval() time.Duration {
+	return 0
+}
+
+type result struct {
+	vals []models.Metrics
+	err  error
+}
+
+func (c *Probe) Collect(ctx context.Context) ([]models.Metrics, error) {
+	if len(c.http)+len(c.tcp) == 0 {
+		return nil, nil
+	}
+	results := make([]result, len(c.http)+len(c.tcp))
+	var wg sync.WaitGroup
+	for i, target := range c.http {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].vals, results[i].err = c.probeHTTP(ctx, target)
+		}()
+	}
+	for i, target := range c.tcp {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[len(c.http)+i].vals, results[len(c.http)+i].err = c.probeTCP(ctx, target)
+		}()
+	}
+	wg.Wait()
+
+	var res []models.Metrics
+	var errs []error
+	for _, r := range results {
+		res = append(res, r.vals...)
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+func gauge(name, kind, target string, v float64) models.Metrics {
+	m := collector.Gauge(name, v)
+	m.Labels = map[string]string{"probe": kind, "target": target}
+	return m
+}
+
+func success(ok bool) float64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// probeHTTP - GET target, success is response with status below 400. Error is returned for bad URL only
+func (c *Probe) probeHTTP(ctx context.Context, target string) ([]models.Metrics, error) {
+	start := c.now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return []models.Metrics{gauge("ProbeSuccess", "http", target, 0)}, fmt.Errorf("probe %s: %w", target, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// unreachable target is result of probe, not error of collector
+		return []models.Metrics{
+			gauge("ProbeSuccess", "http", target, 0),
+			gauge("ProbeDuration", "http", target, c.now().Sub(start).Seconds()),
+		}, nil
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBody))
+	_ = resp.Body.Close()
+	duration := c.now().Sub(start)
+
+	res := []models.Metrics{
+		gauge("ProbeSuccess", "http", target, success(resp.StatusCode < http.StatusBadRequest)),
+		gauge("ProbeDuration", "http", target, duration.Seconds()),
+		gauge("ProbeHTTPStatus", "http", target, float64(resp.StatusCode)),
+	}
+	if expiry, ok := certExpiry(resp.TLS); ok {
+		res = append(res, gauge("ProbeCertExpiry", "http", target, expiry.Sub(c.now()).Seconds()))
+	}
+	return res, nil
+}
+
+// certExpiry - earliest NotAfter of peer certificates
+func certExpiry(state *tls.ConnectionState) (time.Time, bool) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return time.Time{}, false
+	}
+	earliest := state.PeerCertificates[0].NotAfter
+	for _, cert := range state.PeerCertificates[1:] {
+		if cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest, true
+}
+
+// probeTCP - connect to host:port
+func (c *Probe) probeTCP(ctx context.Context, target string) ([]models.Metrics, error) {
+	d := net.Dialer{Timeout: c.timeout}
+	start := c.now()
+	conn, err := d.DialContext(ctx, "tcp", target)
+	duration := c.now().Sub(start)
+	res := []models.Metrics{
+		gauge("ProbeSuccess", "tcp", target, success(err == nil)),
+		gauge("ProbeDuration", "tcp", target, duration.Seconds()),
+	}
+	if err == nil {
+		_ = conn.Close()
+	}
+	return res, nil
+}
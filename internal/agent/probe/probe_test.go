@@ -0,0 +1,100 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func values(t *testing.T, ms []models.Metrics) map[string]float64 {
+	res := make(map[string]float64, len(ms))
+	for _, m := range ms {
+		key, err := m.SeriesKey()
+		require.NoError(t, err)
+		res[key] = *m.Value
+	}
+	return res
+}
+
+func key(name, kind, target string) string {
+	return name + `{probe="` + kind + `",target="` + target + `"}`
+}
+
+func Test_ProbeHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("OK")) })
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) })
+	plain := httptest.NewServer(mux)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(mux)
+	defer secure.Close()
+
+	down := httptest.NewServer(mux)
+	downURL := down.URL + "/ok"
+	down.Close()
+
+	c := NewProbe(&config.Config{ProbeTimeout: 1, ProbeHTTP: []string{plain.URL + "/ok", plain.URL + "/fail", secure.URL + "/ok", downURL}})
+	c.client = secure.Client()
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	got := values(t, res)
+
+	assert.Equal(t, 1.0, got[key("ProbeSuccess", "http", plain.URL+"/ok")])
+	assert.Equal(t, 200.0, got[key("ProbeHTTPStatus", "http", plain.URL+"/ok")])
+	assert.Contains(t, got, key("ProbeDuration", "http", plain.URL+"/ok"))
+	assert.NotContains(t, got, key("ProbeCertExpiry", "http", plain.URL+"/ok"))
+
+	assert.Equal(t, 0.0, got[key("ProbeSuccess", "http", plain.URL+"/fail")])
+	assert.Equal(t, 503.0, got[key("ProbeHTTPStatus", "http", plain.URL+"/fail")])
+
+	assert.Equal(t, 1.0, got[key("ProbeSuccess", "http", secure.URL+"/ok")])
+	want := time.Until(secure.Certificate().NotAfter).Seconds()
+	assert.InDelta(t, want, got[key("ProbeCertExpiry", "http", secure.URL+"/ok")], 60)
+
+	assert.Equal(t, 0.0, got[key("ProbeSuccess", "http", downURL)])
+	assert.NotContains(t, got, key("ProbeHTTPStatus", "http", downURL))
+}
+
+func Test_ProbeTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	up := ln.Addr().String()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := closed.Addr().String()
+	closed.Close()
+	defer ln.Close()
+
+	c := NewProbe(&config.Config{ProbeTimeout: 1, ProbeTCP: []string{up, down}})
+	res, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	got := values(t, res)
+	assert.Equal(t, 1.0, got[key("ProbeSuccess", "tcp", up)])
+	assert.Equal(t, 0.0, got[key("ProbeSuccess", "tcp", down)])
+	assert.Contains(t, got, key("ProbeDuration", "tcp", down))
+}
+
+func Test_ProbeBadURL(t *testing.T) {
+	c := NewProbe(&config.Config{ProbeHTTP: []string{"://bad"}})
+	res, err := c.Collect(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 0.0, values(t, res)[key("ProbeSuccess", "http", "://bad")])
+}
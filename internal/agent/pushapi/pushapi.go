@@ -0,0 +1,148 @@
+// Package pushapi - local listener for metrics of co-located applications
+package pushapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/agent/service"
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	unixPrefix             string = "unix:"
+	applicationJSONContent string = "application/json"
+)
+
+type PushAPI struct {
+	serv *service.HandlerStore
+	cfg  *config.Config
+	l    *logger.Logger
+	srv  *http.Server
+	wg   sync.WaitGroup
+}
+
+func NewPushAPI(serv *service.HandlerStore, l *logger.Logger, cfg *config.Config) *PushAPI {
+	return &PushAPI{
+		serv: serv,
+		cfg:  cfg,
+		l:    l,
+	}
+}
+
+// listen - tcp host:port or unix:/path, stale socket file is removed
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Start - listen PushAddress, nothing to do if it is empty
+func (p *PushAPI) Start(ctx context.Context) error {
+	if p.cfg.PushAddress == "" {
+		return nil
+	}
+	ln, err := listen(p.cfg.PushAddress)
+	if err != nil {
+		return err
+	}
+	p.srv = &http.Server{
+		Handler:           p.newRouter(),
+		ReadHeaderTimeout: 2 * time.Second,
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.l.L.Info("Start push API.", zap.String("address", p.cfg.PushAddress))
+		if err := p.srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			p.l.L.Error("Push API error:", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (p *PushAPI) Stop(ctx context.Context) error {
+	if p.srv == nil {
+		return nil
+	}
+	err := p.srv.Shutdown(ctx)
+	p.wg.Wait()
+	return err
+}
+
+func (p *PushAPI) newRouter() http.Handler {
+	mux := chi.NewRouter()
+	mux.Post("/update/", p.updateJSON)
+	mux.Post("/updates/", p.updatesJSON)
+	mux.Post("/update/{type}/{name}/{value}", p.updatePlain)
+	return mux
+}
+
+// updateJSON - POST /update/ with one models.Metrics
+func (p *PushAPI) updateJSON(res http.ResponseWriter, req *http.Request) {
+	var val models.Metrics
+	if err := val.JSONDecode(req.Body); err != nil {
+		http.Error(res, "Bad request!", http.StatusBadRequest)
+		return
+	}
+	p.store(res, req, []models.Metrics{val}, false)
+}
+
+// updatesJSON - POST /updates/ with array of models.Metrics
+func (p *PushAPI) updatesJSON(res http.ResponseWriter, req *http.Request) {
+	vals, err := models.JSONSDecode(req.Body)
+	if err != nil {
+		http.Error(res, "Bad request!", http.StatusBadRequest)
+		return
+	}
+	p.store(res, req, vals, true)
+}
+
+func (p *PushAPI) store(res http.ResponseWriter, req *http.Request, vals []models.Metrics, multi bool) {
+	resp, err := p.serv.SetValueSModel(req.Context(), vals)
+	if err != nil {
+		p.l.L.Debug("Push API bad metrics:", zap.Error(err))
+		http.Error(res, "Invalid request!", http.StatusBadRequest)
+		return
+	}
+	res.Header().Add("Content-Type", applicationJSONContent)
+	res.WriteHeader(http.StatusOK)
+	if multi {
+		err = models.JSONSEncodeBytes(res, resp)
+	} else {
+		err = resp[0].JSONEncodeBytes(res)
+	}
+	if err != nil {
+		p.l.L.Debug("error writing response", zap.Error(err))
+	}
+}
+
+// updatePlain - POST /update/{type}/{name}/{value}
+func (p *PushAPI) updatePlain(res http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	if name == "" || strings.ContainsAny(name, "{}") {
+		http.Error(res, "Bad name!", http.StatusNotFound)
+		return
+	}
+	_, err := p.serv.RecievePlainValue(req.Context(), chi.URLParam(req, "type"), name, chi.URLParam(req, "value"))
+	if err != nil {
+		http.Error(res, "Bad value!", http.StatusBadRequest)
+		return
+	}
+	res.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+}
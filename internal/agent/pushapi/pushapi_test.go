@@ -0,0 +1,99 @@
+package pushapi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/agent/service"
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/memstoragemux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPushAPI(addr string) (*PushAPI, *service.HandlerStore) {
+	cfg := &config.Config{PushAddress: addr}
+	l := logger.NewLogger(logger.Config{Level: "debug"})
+	serv := service.NewHandlerStore(memstoragemux.NewStoreMux(), nil, nil, cfg, l)
+	return NewPushAPI(serv, l, cfg), serv
+}
+
+func collect(t *testing.T, serv *service.HandlerStore) map[string]string {
+	got := make(map[string]string)
+	require.NoError(t, serv.RangeMetricsJSON(context.Background(), func(ctx context.Context, m *models.Metrics) error {
+		key, _ := m.SeriesKey()
+		got[key] = m.ConvertMetricToValue()
+		return nil
+	}))
+	return got
+}
+
+func Test_PushAPIRoutes(t *testing.T) {
+	p, serv := newTestPushAPI("")
+	ts := httptest.NewServer(p.newRouter())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		statusCode int
+	}{
+		{name: "JSON", path: "/update/", body: `{"id":"jobs","type":"counter","delta":2}`, statusCode: http.StatusOK},
+		{name: "JSON labels", path: "/update/", body: `{"id":"queue","type":"gauge","value":7,"labels":{"name":"mail"}}`, statusCode: http.StatusOK},
+		{name: "JSON array", path: "/updates/", body: `[{"id":"jobs","type":"counter","delta":3},{"id":"load","type":"gauge","value":0.5}]`, statusCode: http.StatusOK},
+		{name: "Plain", path: "/update/counter/jobs/5", statusCode: http.StatusOK},
+		{name: "Bad JSON", path: "/update/", body: `{"id":`, statusCode: http.StatusBadRequest},
+		{name: "Bad type", path: "/update/", body: `{"id":"x","type":"set","value":1}`, statusCode: http.StatusBadRequest},
+		{name: "No name", path: "/update/", body: `{"type":"gauge","value":1}`, statusCode: http.StatusBadRequest},
+		{name: "Plain bad value", path: "/update/gauge/load/abc", statusCode: http.StatusBadRequest},
+		{name: "Plain bad type", path: "/update/set/load/1", statusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := ts.Client().Post(ts.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.statusCode, resp.StatusCode)
+		})
+	}
+
+	assert.Equal(t, map[string]string{
+		"jobs":               "10",
+		`queue{name="mail"}`: "7",
+		"load":               "0.5",
+	}, collect(t, serv))
+}
+
+func Test_PushAPIUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "push.sock")
+	p, serv := newTestPushAPI("unix:" + sock)
+	require.NoError(t, p.Start(context.Background()))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Post("http://agent/update/gauge/temp/21.5", "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, p.Stop(context.Background()))
+	assert.Equal(t, map[string]string{"temp": "21.5"}, collect(t, serv))
+}
+
+func Test_PushAPIDisabled(t *testing.T) {
+	p, _ := newTestPushAPI("")
+	require.NoError(t, p.Start(context.Background()))
+	require.NoError(t, p.Stop(context.Background()))
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/agent/poolclients"
+	"github.com/4aleksei/metricscum/internal/agent/spool"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"go.uber.org/zap"
+)
+
+// maxCooldown - longest time failed destination is skipped
+const maxCooldown = 5 * time.Minute
+
+var (
+	ErrNoDestination   = errors.New("no destination")
+	ErrDestinationDown = errors.New("destination is down")
+)
+
+// destination - server with health state. Fanout destination keeps unsent metrics by itself,
+// in own spool or as backlog of counters and histograms
+type destination struct {
+	pool      *poolclients.PoolClient
+	spool     *spool.Spool
+	backlog   []models.Metrics
+	failures  int
+	downUntil time.Time
+}
+
+func (d *destination) healthy(now time.Time) bool {
+	return !now.Before(d.downUntil)
+}
+
+// fanout - every destination gets all metrics, single destination works as failover
+func (h *HandlerStore) fanout() bool {
+	return len(h.dests) > 1 && h.cfg.DestinationMode != config.DestinationFailover
+}
+
+// mark - consecutive failures put destination off for cooldown doubled from report interval
+func (h *HandlerStore) mark(ctx context.Context, d *destination, err error) {
+	if err == nil {
+		if d.failures > 0 {
+			h.l.L.Info("Destination is up:", zap.String("address", d.pool.Name))
+		}
+		d.failures = 0
+		d.downUntil = time.Time{}
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	d.failures++
+	cooldown := time.Duration(h.cfg.ReportInterval) * time.Second << min(d.failures-1, 16)
+	if cooldown <= 0 || cooldown > maxCooldown {
+		cooldown = maxCooldown
+	}
+	d.downUntil = h.now().Add(cooldown)
+	h.l.L.Warn("Destination is down:", zap.String("address", d.pool.Name),
+		zap.Int("failures", d.failures), zap.Duration("cooldown", cooldown), zap.Error(err))
+}
+
+// ordered - healthy destinations in priority order, then the others as last resort
+func (h *HandlerStore) ordered() []*destination {
+	now := h.now()
+	var res, down []*destination
+	for _, d := range h.dests {
+		if d.healthy(now) {
+			res = append(res, d)
+		} else {
+			down = append(down, d)
+		}
+	}
+	return append(res, down...)
+}
+
+// sendFailover - not sent part goes to next destination, returns metrics not sent to anyone
+func (h *HandlerStore) sendFailover(ctx context.Context, resmodelsTX []models.Metrics) ([]models.Metrics, error) {
+	if len(h.dests) == 0 {
+		return resmodelsTX, ErrNoDestination
+	}
+	rest := resmodelsTX
+	var errRes error
+	for i, d := range h.ordered() {
+		if i > 0 {
+			h.l.L.Debug("Failover to next destination:", zap.String("address", d.pool.Name), zap.Int("len", len(rest)))
+		}
+		failed, err := h.sendBatch(ctx, d, rest)
+		h.mark(ctx, d, err)
+		if err == nil {
+			return nil, nil
+		}
+		errRes = err
+		rest = failed
+		if len(rest) == 0 || ctx.Err() != nil {
+			break
+		}
+	}
+	return rest, errRes
+}
+
+// sendFanout - metrics to all destinations concurrently
+func (h *HandlerStore) sendFanout(ctx context.Context, resmodelsTX []models.Metrics) error {
+	if h.spool != nil {
+		// segments of shared spool were written before fanout, they are new data for every destination
+		_ = h.spool.Replay(ctx, func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error) {
+			_ = h.fanoutAll(ctx, batch)
+			return nil, nil
+		})
+	}
+	return h.fanoutAll(ctx, resmodelsTX)
+}
+
+func (h *HandlerStore) fanoutAll(ctx context.Context, resmodelsTX []models.Metrics) error {
+	errs := make([]error, len(h.dests))
+	var wg sync.WaitGroup
+	for i, d := range h.dests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = h.deliver(ctx, d, resmodelsTX)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// deliver - unsent metrics of destination first, then new ones
+func (h *HandlerStore) deliver(ctx context.Context, d *destination, resmodelsTX []models.Metrics) error {
+	if !d.healthy(h.now()) {
+		h.keep(ctx, d, resmodelsTX)
+		return fmt.Errorf("%s: %w", d.pool.Name, ErrDestinationDown)
+	}
+	if d.spool != nil {
+		errR := d.spool.Replay(ctx, func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error) {
+			return h.sendBatch(ctx, d, batch)
+		})
+		if errR != nil {
+			h.mark(ctx, d, errR)
+			h.keep(ctx, d, resmodelsTX)
+			return errR
+		}
+	} else if len(d.backlog) > 0 {
+		resmodelsTX = append(d.backlog, resmodelsTX...)
+		d.backlog = nil
+	}
+	failed, err := h.sendBatch(ctx, d, resmodelsTX)
+	h.mark(ctx, d, err)
+	if err != nil {
+		h.keep(ctx, d, failed)
+	}
+	return err
+}
+
+// keep - unsent metrics of fanout destination to its spool, or counters and histograms to its backlog
+func (h *HandlerStore) keep(ctx context.Context, d *destination, resmodelsTX []models.Metrics) {
+	if len(resmodelsTX) == 0 {
+		return
+	}
+	if d.spool != nil {
+		err := d.spool.Push(resmodelsTX)
+		if err == nil {
+			return
+		}
+		h.l.L.Error("Destination spool write failed:", zap.String("address", d.pool.Name), zap.Error(err))
+	}
+	// backlog is merged, so it is not longer than count of series
+	merged := memstorage.NewStore()
+	_, _ = merged.AddMulti(ctx, d.backlog)
+	_, _ = merged.AddMulti(ctx, accumulated(resmodelsTX))
+	d.backlog = nil
+	_ = merged.ReadAll(ctx, func(key string, val valuemetric.ValueMetric) error {
+		var m models.Metrics
+		m.ConvertMetricToModel(key, val)
+		d.backlog = append(d.backlog, m)
+		return nil
+	})
+}
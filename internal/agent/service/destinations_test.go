@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/agent/poolclients"
+	"github.com/4aleksei/metricscum/internal/agent/spool"
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestDestinations(t *testing.T, mode string, sp *spool.Spool) (*HandlerStore, *fakePool, *fakePool, *fakeClock) {
+	cfg := &config.Config{ReportInterval: 10, DestinationMode: mode}
+	lo := logger.NewLogger(logger.Config{Level: "debug"})
+	a, b := &fakePool{}, &fakePool{}
+	pa, pb := poolclients.New(a, 1), poolclients.New(b, 1)
+	pa.Name, pb.Name = "new:8080", "old:8080"
+	h := NewHandlerStore(memstorage.NewStore(), []*poolclients.PoolClient{pa, pb}, sp, cfg, lo)
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	h.now = clock.now
+	return h, a, b, clock
+}
+
+func Test_SendMetricsFanout(t *testing.T) {
+	h, a, b, clock := newTestDestinations(t, config.DestinationFanout, nil)
+	ctx := context.Background()
+
+	b.down = true
+	_, _ = h.SetCounter(ctx, "PollCount", 1)
+	assert.ErrorIs(t, h.SendMetrics(ctx), errDown)
+
+	// b is in cooldown and is not tried, its counters are merged in backlog
+	b.down = false
+	_, _ = h.SetCounter(ctx, "PollCount", 2)
+	assert.ErrorIs(t, h.SendMetrics(ctx), ErrDestinationDown)
+	assert.Empty(t, b.sent)
+
+	clock.t = clock.t.Add(11 * time.Second)
+	_, _ = h.SetCounter(ctx, "PollCount", 4)
+	assert.NoError(t, h.SendMetrics(ctx))
+
+	assert.Equal(t, []string{"PollCount=1", "PollCount=2", "PollCount=4"}, a.sent)
+	assert.Equal(t, []string{"PollCount=3", "PollCount=4"}, b.sent)
+}
+
+func Test_SendMetricsFanoutSpool(t *testing.T) {
+	lo := logger.NewLogger(logger.Config{Level: "debug"})
+	sp, err := spool.New(t.TempDir(), 10, lo)
+	require.NoError(t, err)
+	// written before destinations were configured
+	old := int64(7)
+	require.NoError(t, sp.Push([]models.Metrics{{ID: "PollCount", MType: "counter", Delta: &old}}))
+
+	h, a, b, clock := newTestDestinations(t, config.DestinationFanout, sp)
+	require.NotNil(t, h.dests[1].spool)
+	ctx := context.Background()
+
+	// b fails on replay of shared spool and new data is kept without trying
+	b.down = true
+	_, _ = h.SetCounter(ctx, "PollCount", 1)
+	assert.ErrorIs(t, h.SendMetrics(ctx), ErrDestinationDown)
+	assert.Equal(t, 0, sp.Len())
+	assert.Equal(t, 0, h.dests[0].spool.Len())
+	assert.Equal(t, 2, h.dests[1].spool.Len())
+
+	b.down = false
+	clock.t = clock.t.Add(11 * time.Second)
+	_, _ = h.SetCounter(ctx, "PollCount", 2)
+	assert.NoError(t, h.SendMetrics(ctx))
+	assert.Equal(t, 0, h.dests[1].spool.Len())
+	assert.Equal(t, []string{"PollCount=7", "PollCount=1", "PollCount=2"}, a.sent)
+	assert.Equal(t, []string{"PollCount=7", "PollCount=1", "PollCount=2"}, b.sent)
+}
+
+func Test_SendMetricsFailover(t *testing.T) {
+	h, a, b, clock := newTestDestinations(t, config.DestinationFailover, nil)
+	ctx := context.Background()
+
+	a.down = true
+	_, _ = h.SetCounter(ctx, "PollCount", 1)
+	assert.NoError(t, h.SendMetrics(ctx))
+	assert.Equal(t, []string{"PollCount=1"}, b.sent)
+
+	// primary is skipped during cooldown
+	a.down = false
+	_, _ = h.SetCounter(ctx, "PollCount", 2)
+	assert.NoError(t, h.SendMetrics(ctx))
+	assert.Empty(t, a.sent)
+	assert.Equal(t, []string{"PollCount=1", "PollCount=2"}, b.sent)
+
+	clock.t = clock.t.Add(11 * time.Second)
+	_, _ = h.SetCounter(ctx, "PollCount", 3)
+	assert.NoError(t, h.SendMetrics(ctx))
+	assert.Equal(t, []string{"PollCount=3"}, a.sent)
+
+	// all destinations down, counters are rolled back to store
+	a.down, b.down = true, true
+	_, _ = h.SetCounter(ctx, "PollCount", 4)
+	assert.ErrorIs(t, h.SendMetrics(ctx), errDown)
+	a.down, b.down = false, false
+	clock.t = clock.t.Add(time.Hour)
+	_, _ = h.SetCounter(ctx, "PollCount", 5)
+	assert.NoError(t, h.SendMetrics(ctx))
+	assert.Equal(t, []string{"PollCount=3", "PollCount=9"}, a.sent)
+}
@@ -3,10 +3,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"time"
 
 	"github.com/4aleksei/metricscum/internal/agent/config"
 	"github.com/4aleksei/metricscum/internal/agent/poolclients"
+	"github.com/4aleksei/metricscum/internal/agent/spool"
 	"github.com/4aleksei/metricscum/internal/common/job"
 	"github.com/4aleksei/metricscum/internal/common/logger"
 	"github.com/4aleksei/metricscum/internal/common/models"
@@ -23,19 +26,40 @@ type AgentMetricsStorage interface {
 
 type HandlerStore struct {
 	store AgentMetricsStorage
-	pool  *poolclients.PoolClient //*httpclientpool.PoolHandler
+	dests []*destination // in priority order
+	spool *spool.Spool   // nil without spool
 	cfg   *config.Config
 	l     *logger.Logger
+	now   func() time.Time
+	jmux  sync.Mutex
 	jid   job.JobID
 }
 
-func NewHandlerStore(store AgentMetricsStorage, pool *poolclients.PoolClient, cfg *config.Config, l *logger.Logger) *HandlerStore {
-	return &HandlerStore{
+// NewHandlerStore - pools are destinations in priority order
+func NewHandlerStore(store AgentMetricsStorage, pools []*poolclients.PoolClient, sp *spool.Spool,
+	cfg *config.Config, l *logger.Logger) *HandlerStore {
+	h := &HandlerStore{
 		store: store,
-		pool:  pool,
+		spool: sp,
 		cfg:   cfg,
 		l:     l,
+		now:   time.Now,
 	}
+	for _, p := range pools {
+		h.dests = append(h.dests, &destination{pool: p})
+	}
+	if h.fanout() && sp != nil {
+		for _, d := range h.dests {
+			ds, err := sp.Sub(d.pool.Name)
+			if err != nil {
+				l.L.Error("Destination spool failed, unsent counters are kept in memory:",
+					zap.String("address", d.pool.Name), zap.Error(err))
+				continue
+			}
+			d.spool = ds
+		}
+	}
+	return h
 }
 
 func (h *HandlerStore) SetGauge(ctx context.Context, name string, val float64) (valuemetric.ValueMetric, error) {
@@ -58,6 +82,29 @@ func (h *HandlerStore) SetCounter(ctx context.Context, name string, val int64) (
 	return h.store.Add(ctx, name, *valMetric)
 }
 
+func (h *HandlerStore) SetHistogram(ctx context.Context, name string, hist *valuemetric.Histogram) (valuemetric.ValueMetric, error) {
+	valMetric := valuemetric.ConvertToHistValueMetric(hist)
+	return h.store.Add(ctx, name, *valMetric)
+}
+
+// SetValueSModel - metrics pushed by local applications, labels are kept
+func (h *HandlerStore) SetValueSModel(ctx context.Context, valModel []models.Metrics) ([]models.Metrics, error) {
+	return h.store.AddMulti(ctx, valModel)
+}
+
+// RecievePlainValue - counter or gauge value in text form
+func (h *HandlerStore) RecievePlainValue(ctx context.Context, typeVal, name, valstr string) (valuemetric.ValueMetric, error) {
+	kind, err := valuemetric.GetKind(typeVal)
+	if err != nil {
+		return valuemetric.ValueMetric{}, err
+	}
+	val, err := valuemetric.ConvertToValueMetric(kind, valstr)
+	if err != nil {
+		return valuemetric.ValueMetric{}, err
+	}
+	return h.store.Add(ctx, name, *val)
+}
+
 func (h *HandlerStore) RangeMetrics(ctx context.Context, prog func(context.Context, string) error) error {
 	err := h.store.ReadAllClearCounters(ctx, func(key string, val valuemetric.ValueMetric) error {
 		typename, valstr := valuemetric.ConvertValueMetricToPlain(val)
@@ -90,13 +137,19 @@ func (h *HandlerStore) RangeMetricsJSONS(ctx context.Context, prog func(context.
 	return prog(ctx, resmodels)
 }
 
-func (h *HandlerStore) rollBackMetrics(ctx context.Context, resmodelsTX []models.Metrics) {
-	var rollBack []models.Metrics
+// accumulated - counters and histograms, values which are lost if not sent again
+func accumulated(resmodelsTX []models.Metrics) []models.Metrics {
+	var res []models.Metrics
 	for _, v := range resmodelsTX {
-		if v.MType == "counter" {
-			rollBack = append(rollBack, v)
+		if v.MType == "counter" || v.MType == "histogram" {
+			res = append(res, v)
 		}
 	}
+	return res
+}
+
+func (h *HandlerStore) rollBackMetrics(ctx context.Context, resmodelsTX []models.Metrics) {
+	rollBack := accumulated(resmodelsTX)
 	if len(rollBack) > 0 {
 		_, _ = h.store.AddMulti(ctx, rollBack)
 		h.l.L.Debug("Rollback :", zap.Int("len", len(rollBack)))
@@ -104,68 +157,82 @@ func (h *HandlerStore) rollBackMetrics(ctx context.Context, resmodelsTX []models
 }
 
 func (h *HandlerStore) newJid() job.JobID {
+	h.jmux.Lock()
+	defer h.jmux.Unlock()
 	h.jid++
 	return h.jid
 }
 
-func (h *HandlerStore) sendMetricsRun(ctx context.Context, jobs chan job.Job, resmodelsTX []models.Metrics) {
-	defer close(jobs)
+// makeJobs - split metrics to jobs by batch size
+func (h *HandlerStore) makeJobs(batch int64, resmodelsTX []models.Metrics) []job.Job {
 	var b = 1
-	if h.cfg.ContentBatch > 0 {
-		b = int(h.cfg.ContentBatch)
+	if batch > 0 {
+		b = int(batch)
 	}
 	if b > len(resmodelsTX) {
 		b = len(resmodelsTX)
 	}
+	var list []job.Job
 	for x := 0; x < len(resmodelsTX); x += b {
+		if (x + b) > len(resmodelsTX) {
+			b = len(resmodelsTX) - x
+		}
+		list = append(list, job.Job{ID: h.newJid(), Value: resmodelsTX[x : x+b]})
+	}
+	return list
+}
+
+func (h *HandlerStore) sendMetricsRun(ctx context.Context, jobs chan job.Job, list []job.Job) {
+	defer close(jobs)
+	for _, j := range list {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			if (x + b) > len(resmodelsTX) {
-				b = len(resmodelsTX) - x
-				if b == 0 {
-					return
-				}
-			}
-			id := h.newJid()
-			jobs <- job.Job{ID: id, Value: resmodelsTX[x : x+b]}
-			h.l.L.Debug("SendedJob:", zap.Int("batch_len", b), zap.Int64("id", int64(id)))
+			jobs <- j
+			h.l.L.Debug("SendedJob:", zap.Int("batch_len", len(j.Value)), zap.Int64("id", int64(j.ID)))
 		}
 	}
 }
 
 func (h *HandlerStore) GracefulStop() {
-
-	h.pool.GracefulStop()
+	for _, d := range h.dests {
+		d.pool.GracefulStop()
+	}
 }
 
-func (h *HandlerStore) startSendMetricsRun(ctx context.Context, resmodelsTX []models.Metrics,
+func (h *HandlerStore) startSendMetricsRun(ctx context.Context, pool *poolclients.PoolClient, list []job.Job,
 	jobs chan job.Job, results chan job.Result, wg *sync.WaitGroup) {
-	go h.sendMetricsRun(ctx, jobs, resmodelsTX)
-	h.pool.StartPool(ctx, jobs, results, wg)
+	go h.sendMetricsRun(ctx, jobs, list)
+	pool.StartPool(ctx, jobs, results, wg)
 }
 
-func (h *HandlerStore) SendMetrics(ctx context.Context) error {
-	resmodelsTX := make([]models.Metrics, 0, 1)
-	err := h.store.ReadAllClearCounters(ctx, func(key string, val valuemetric.ValueMetric) error {
-		var valNewModel models.Metrics
-		valNewModel.ConvertMetricToModel(key, val)
-		resmodelsTX = append(resmodelsTX, valNewModel)
-		return nil
-	})
-	if err != nil {
-		return err
+// rejected - server answered with status which is not retryable for transport of pool,
+// like HTTP 400 or gRPC InvalidArgument
+func rejected(pool *poolclients.PoolClient, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return !pool.Probe(err)
+}
+
+// sendBatch - send metrics by pool of destination, returns metrics of failed and not finished jobs.
+// Rejected jobs are dropped
+func (h *HandlerStore) sendBatch(ctx context.Context, d *destination, resmodelsTX []models.Metrics) ([]models.Metrics, error) {
+	pool := d.pool
+	list := h.makeJobs(pool.ContentBatch, resmodelsTX)
+	pending := make(map[job.JobID][]models.Metrics, len(list))
+	for _, j := range list {
+		pending[j.ID] = j.Value
 	}
-	h.l.L.Debug("Sending:", zap.Int("store len", len(resmodelsTX)))
 
 	var errRes error
 
 	wg := &sync.WaitGroup{}
-	jobs := make(chan job.Job, h.pool.WorkerCount*2)
-	results := make(chan job.Result, h.pool.WorkerCount*2)
+	jobs := make(chan job.Job, pool.WorkerCount*2)
+	results := make(chan job.Result, pool.WorkerCount*2)
 
-	h.startSendMetricsRun(ctx, resmodelsTX, jobs, results, wg)
+	h.startSendMetricsRun(ctx, pool, list, jobs, results, wg)
 
 	go func() {
 		wg.Wait()
@@ -176,20 +243,76 @@ func (h *HandlerStore) SendMetrics(ctx context.Context) error {
 		select {
 		case <-ctx.Done():
 			h.l.L.Debug("Exit routin:")
-			return ctx.Err()
+			errRes = ctx.Err()
 		default:
 			h.l.L.Debug("GetJob:", zap.Int64("id", int64(res.ID)))
-			if res.Err != nil {
+			if res.Err != nil && rejected(pool, res.Err) {
+				// resending does not help, batch would block spool and data behind it
+				h.l.L.Error("Batch rejected, dropped:", zap.String("address", pool.Name),
+					zap.Int("len", len(pending[res.ID])), zap.Error(res.Err))
+			} else if res.Err != nil {
 				errRes = res.Err
 				h.l.L.Error("error result:", zap.Error(errRes))
+				continue
 			}
+			delete(pending, res.ID)
 		}
 	}
+	if errRes == nil && len(pending) > 0 {
+		errRes = context.Canceled
+	}
+
+	var failed []models.Metrics
+	for _, j := range list {
+		failed = append(failed, pending[j.ID]...)
+	}
+	return failed, errRes
+}
+
+func (h *HandlerStore) SendMetrics(ctx context.Context) error {
+	resmodelsTX := make([]models.Metrics, 0, 1)
+	err := h.store.ReadAllClearCounters(ctx, func(key string, val valuemetric.ValueMetric) error {
+		var valNewModel models.Metrics
+		valNewModel.ConvertMetricToModel(key, val)
+		resmodelsTX = append(resmodelsTX, valNewModel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.l.L.Debug("Sending:", zap.Int("store len", len(resmodelsTX)))
+
+	if h.fanout() {
+		return h.sendFanout(ctx, resmodelsTX)
+	}
+
+	if h.spool != nil {
+		if errR := h.spool.Replay(ctx, h.sendFailover); errR != nil {
+			// server is still unreachable, new data goes after old to keep order
+			h.l.L.Debug("Spool replay failed:", zap.Error(errR))
+			h.pushSpool(ctx, resmodelsTX)
+			return errR
+		}
+	}
+
+	failed, errRes := h.sendFailover(ctx, resmodelsTX)
 	if errRes != nil {
 		h.l.L.Debug("Error results:", zap.Error(errRes))
-		h.rollBackMetrics(ctx, resmodelsTX)
+		if h.spool != nil {
+			h.pushSpool(ctx, failed)
+		} else {
+			h.rollBackMetrics(ctx, failed)
+		}
 	} else {
 		h.l.L.Debug("Sending success", zap.Int("len", len(resmodelsTX)))
 	}
 	return errRes
 }
+
+// pushSpool - persist unsent metrics, counters are rolled back if spool fails
+func (h *HandlerStore) pushSpool(ctx context.Context, resmodelsTX []models.Metrics) {
+	if err := h.spool.Push(resmodelsTX); err != nil {
+		h.l.L.Error("Spool write failed:", zap.Error(err))
+		h.rollBackMetrics(ctx, resmodelsTX)
+	}
+}
@@ -7,20 +7,29 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/4aleksei/metricscum/internal/agent/config"
 	"github.com/4aleksei/metricscum/internal/agent/poolclients"
+	"github.com/4aleksei/metricscum/internal/agent/spool"
+	pb "github.com/4aleksei/metricscum/internal/common/grpcmetrics/proto"
+	"github.com/4aleksei/metricscum/internal/common/job"
 	"github.com/4aleksei/metricscum/internal/common/logger"
 	"github.com/4aleksei/metricscum/internal/common/models"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
 	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func Test_NewHandlerStore(t *testing.T) {
 	t.Run("Test NewHandlerStore", func(t *testing.T) {
-		store := NewHandlerStore(nil, nil, nil, nil)
+		store := NewHandlerStore(nil, nil, nil, nil, nil)
 		assert.NotNil(t, store)
 		num := store.jid
 		nnum := store.newJid()
@@ -30,7 +39,7 @@ func Test_NewHandlerStore(t *testing.T) {
 
 func Test_SetGauge(t *testing.T) {
 	stor := memstorage.NewStore()
-	serV := NewHandlerStore(stor, nil, nil, nil)
+	serV := NewHandlerStore(stor, nil, nil, nil, nil)
 
 	tests := []struct {
 		wantErr   error
@@ -61,7 +70,7 @@ func Test_SetGauge(t *testing.T) {
 
 func Test_SetCounter(t *testing.T) {
 	stor := memstorage.NewStore()
-	serV := NewHandlerStore(stor, nil, nil, nil)
+	serV := NewHandlerStore(stor, nil, nil, nil, nil)
 
 	tests := []struct {
 		wantErr   error
@@ -92,7 +101,7 @@ func Test_SetCounter(t *testing.T) {
 
 func Test_SetGaugeMulti(t *testing.T) {
 	stor := memstorage.NewStore()
-	serV := NewHandlerStore(stor, nil, nil, nil)
+	serV := NewHandlerStore(stor, nil, nil, nil, nil)
 
 	a := 100.5
 	b := 100.1
@@ -156,9 +165,10 @@ func Test_SendMetrics(t *testing.T) {
 
 	stor := memstorage.NewStore()
 
-	pool := poolclients.NewPoolClient(cfg)
+	pool, err := poolclients.NewPoolClient(cfg)
+	require.NoError(t, err)
 	lo := logger.NewLogger(logger.Config{Level: "debug"})
-	serV := NewHandlerStore(stor, pool, cfg, lo)
+	serV := NewHandlerStore(stor, []*poolclients.PoolClient{pool}, nil, cfg, lo)
 
 	vF := valuemetric.ConvertToFloatValueMetric(55.55)
 	vI := valuemetric.ConvertToIntValueMetric(55)
@@ -172,3 +182,143 @@ func Test_SendMetrics(t *testing.T) {
 		assert.Nil(t, err)
 	})
 }
+
+var errDown = errors.New("server down")
+
+// fakePool - fails all jobs while down, records sent metrics otherwise
+type fakePool struct {
+	sent []string
+	down bool
+}
+
+func (f *fakePool) StartPool(ctx context.Context, jobs chan job.Job, results chan job.Result, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := range jobs {
+			if f.down {
+				results <- job.Result{ID: j.ID, Err: errDown}
+				continue
+			}
+			for _, m := range j.Value {
+				f.sent = append(f.sent, m.ID+"="+m.ConvertMetricToValue())
+			}
+			results <- job.Result{ID: j.ID}
+		}
+	}()
+}
+
+func (f *fakePool) GracefulStop() {}
+
+// Probe - pool is down, errors are retryable
+func (f *fakePool) Probe(err error) bool { return true }
+
+func Test_SendMetricsSpool(t *testing.T) {
+	cfg := &config.Config{RateLimit: 1, ContentBatch: 10}
+	lo := logger.NewLogger(logger.Config{Level: "debug"})
+	sp, err := spool.New(t.TempDir(), 10, lo)
+	require.NoError(t, err)
+
+	stor := memstorage.NewStore()
+	fp := &fakePool{down: true}
+	serV := NewHandlerStore(stor, []*poolclients.PoolClient{poolclients.New(fp, 1)}, sp, cfg, lo)
+
+	ctx := context.Background()
+	_, _ = serV.SetCounter(ctx, "PollCount", 1)
+	assert.ErrorIs(t, serV.SendMetrics(ctx), errDown)
+	assert.Equal(t, 1, sp.Len())
+
+	_, _ = serV.SetCounter(ctx, "PollCount", 2)
+	assert.ErrorIs(t, serV.SendMetrics(ctx), errDown)
+	assert.Equal(t, 2, sp.Len())
+
+	fp.down = false
+	_, _ = serV.SetCounter(ctx, "PollCount", 3)
+	assert.NoError(t, serV.SendMetrics(ctx))
+	assert.Equal(t, 0, sp.Len())
+	assert.Equal(t, []string{"PollCount=1", "PollCount=2", "PollCount=3"}, fp.sent)
+}
+
+func Test_SendMetricsRejected(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	for _, withSpool := range []bool{true, false} {
+		requests.Store(0)
+		cfg := &config.Config{
+			Address:      server.Listener.Addr().String(),
+			RateLimit:    1,
+			ContentJSON:  true,
+			ContentBatch: 10,
+		}
+		lo := logger.NewLogger(logger.Config{Level: "debug"})
+		var sp *spool.Spool
+		if withSpool {
+			var err error
+			sp, err = spool.New(t.TempDir(), 10, lo)
+			require.NoError(t, err)
+		}
+		stor := memstorage.NewStore()
+		pool, err := poolclients.NewPoolClient(cfg)
+		require.NoError(t, err)
+		serV := NewHandlerStore(stor, []*poolclients.PoolClient{pool}, sp, cfg, lo)
+
+		ctx := context.Background()
+		_, _ = serV.SetCounter(ctx, "PollCount", 1)
+		assert.NoError(t, serV.SendMetrics(ctx), "rejected batch is dropped")
+		if sp != nil {
+			assert.Equal(t, 0, sp.Len(), "rejected batch is not spooled")
+		}
+		assert.Equal(t, int32(1), requests.Load())
+
+		_ = stor.ReadAllClearCounters(ctx, func(key string, val valuemetric.ValueMetric) error {
+			assert.Equal(t, int64(0), *val.ValueInt(), "rejected counter is not rolled back")
+			return nil
+		})
+	}
+}
+
+// rejectingService - gRPC server which rejects every metric as invalid
+type rejectingService struct {
+	pb.UnimplementedStreamMultiServiceServer
+	requests atomic.Int32
+}
+
+func (s *rejectingService) UpdateRequest(ctx context.Context, in *pb.Request) (*pb.Response, error) {
+	s.requests.Add(1)
+	return nil, status.Error(codes.InvalidArgument, "bad metric")
+}
+
+func Test_SendMetricsRejectedGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &rejectingService{}
+	grpcServer := grpc.NewServer()
+	pb.RegisterStreamMultiServiceServer(grpcServer, srv)
+	go func() { _ = grpcServer.Serve(lis) }()
+	defer grpcServer.Stop()
+
+	cfg := &config.Config{
+		Address:   lis.Addr().String(),
+		RateLimit: 1,
+		Grpc:      true,
+	}
+	lo := logger.NewLogger(logger.Config{Level: "debug"})
+	sp, err := spool.New(t.TempDir(), 10, lo)
+	require.NoError(t, err)
+	pool, err := poolclients.NewPoolClient(cfg)
+	require.NoError(t, err)
+	defer pool.GracefulStop()
+	stor := memstorage.NewStore()
+	serV := NewHandlerStore(stor, []*poolclients.PoolClient{pool}, sp, cfg, lo)
+
+	ctx := context.Background()
+	_, _ = serV.SetCounter(ctx, "PollCount", 1)
+	assert.NoError(t, serV.SendMetrics(ctx), "rejected batch is dropped")
+	assert.Equal(t, 0, sp.Len(), "rejected batch is not spooled")
+	assert.Equal(t, int32(1), srv.requests.Load())
+}
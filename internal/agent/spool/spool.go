@@ -0,0 +1,216 @@
+// Package spool - on-disk queue of unsent metrics batches
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/longtermfile"
+	"github.com/4aleksei/metricscum/internal/common/streams/compressors/zipdata"
+	"github.com/4aleksei/metricscum/internal/common/streams/encoders/jsonencdec"
+	"github.com/4aleksei/metricscum/internal/common/streams/sources/singlefile"
+	"go.uber.org/zap"
+)
+
+const (
+	segmentExt = ".seg"
+	tmpExt     = ".tmp"
+
+	dirMode os.FileMode = 0o750
+)
+
+// FuncSend - send batch, returns metrics which are not sent
+type FuncSend func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error)
+
+// Spool - every batch is one segment file, segments are replayed in order of writing.
+// Oldest segment is dropped when count of segments reaches limit
+type Spool struct {
+	l           *logger.Logger
+	mux         *sync.Mutex
+	dir         string
+	segments    []uint64
+	next        uint64
+	maxSegments int
+}
+
+// NewSpool - spool in cfg.SpoolDir, nil without spool dir
+func NewSpool(cfg *config.Config, l *logger.Logger) (*Spool, error) {
+	if cfg.SpoolDir == "" {
+		return nil, nil
+	}
+	return New(cfg.SpoolDir, int(cfg.SpoolSegments), l)
+}
+
+func New(dir string, maxSegments int, l *logger.Logger) (*Spool, error) {
+	if maxSegments <= 0 {
+		return nil, fmt.Errorf("spool: bad segments limit %d", maxSegments)
+	}
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return nil, err
+	}
+	s := &Spool{
+		l:           l,
+		mux:         new(sync.Mutex),
+		dir:         dir,
+		maxSegments: maxSegments,
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, tmpExt) {
+			_ = os.Remove(filepath.Join(dir, name)) // not finished write
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		s.segments = append(s.segments, seq)
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+	if n := len(s.segments); n > 0 {
+		s.next = s.segments[n-1] + 1
+	}
+	return s, nil
+}
+
+// Sub - spool with same limit in subdirectory, name is sanitized to one path element
+func (s *Spool) Sub(name string) (*Spool, error) {
+	clean := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(clean, ".") == "" {
+		return nil, fmt.Errorf("spool: bad name %q", name)
+	}
+	return New(filepath.Join(s.dir, clean), s.maxSegments, s.l)
+}
+
+func (s *Spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// writeSegment - write to temporary file and rename, segment is never seen half written
+func (s *Spool) writeSegment(name string, batch []models.Metrics) error {
+	tmp := name + tmpExt
+	file := longtermfile.NewLongTerm(singlefile.NewReader(tmp), jsonencdec.NewReader(),
+		singlefile.NewWriter(tmp), jsonencdec.NewWriter())
+	file.UseForWriter(zipdata.NewWriter())
+	if err := file.OpenWriter(); err != nil {
+		return err
+	}
+	for i := range batch {
+		if err := file.WriteData(&batch[i]); err != nil {
+			_ = file.CloseWrite()
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+	if err := file.CloseWrite(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (s *Spool) readSegment(name string) ([]models.Metrics, error) {
+	file := longtermfile.NewLongTerm(singlefile.NewReader(name), jsonencdec.NewReader(),
+		singlefile.NewWriter(name), jsonencdec.NewWriter())
+	file.UseForReader(zipdata.NewReader())
+	if err := file.OpenReader(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.CloseRead()
+	}()
+	var batch []models.Metrics
+	for {
+		var m models.Metrics
+		err := file.ReadData(&m)
+		if errors.Is(err, io.EOF) {
+			return batch, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, m)
+	}
+}
+
+// Push - persist batch as new segment
+func (s *Spool) Push(batch []models.Metrics) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for len(s.segments) >= s.maxSegments {
+		s.l.L.Warn("spool is full, oldest segment dropped", zap.Uint64("segment", s.segments[0]))
+		_ = os.Remove(s.path(s.segments[0]))
+		s.segments = s.segments[1:]
+	}
+	seq := s.next
+	if err := s.writeSegment(s.path(seq), batch); err != nil {
+		return err
+	}
+	s.next++
+	s.segments = append(s.segments, seq)
+	return nil
+}
+
+// Len - count of segments
+func (s *Spool) Len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.segments)
+}
+
+// Replay - send segments from oldest, stop on first error.
+// Not sent part of failed segment stays in spool
+func (s *Spool) Replay(ctx context.Context, send FuncSend) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for len(s.segments) > 0 {
+		name := s.path(s.segments[0])
+		batch, err := s.readSegment(name)
+		if err != nil {
+			s.l.L.Error("spool segment is broken, dropped", zap.String("file", name), zap.Error(err))
+			_ = os.Remove(name)
+			s.segments = s.segments[1:]
+			continue
+		}
+		rest, errS := send(ctx, batch)
+		if errS != nil {
+			if len(rest) == 0 {
+				_ = os.Remove(name)
+				s.segments = s.segments[1:]
+			} else if len(rest) < len(batch) {
+				if err := s.writeSegment(name, rest); err != nil {
+					return err
+				}
+			}
+			return errS
+		}
+		_ = os.Remove(name)
+		s.segments = s.segments[1:]
+		s.l.L.Debug("spool segment sent", zap.String("file", name), zap.Int("len", len(batch)))
+	}
+	return nil
+}
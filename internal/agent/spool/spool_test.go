@@ -0,0 +1,90 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errSend = errors.New("send failed")
+
+func gauge(id string, v float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &v}
+}
+
+func newTestSpool(t *testing.T, dir string, max int) *Spool {
+	s, err := New(dir, max, logger.NewLogger(logger.Config{Level: "debug"}))
+	require.NoError(t, err)
+	return s
+}
+
+func Test_SpoolReplayOrder(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestSpool(t, dir, 10)
+	require.NoError(t, s.Push([]models.Metrics{gauge("a", 1), gauge("b", 2)}))
+	require.NoError(t, s.Push([]models.Metrics{gauge("a", 3)}))
+	require.NoError(t, s.Push(nil))
+	assert.Equal(t, 2, s.Len())
+
+	// segments survive restart
+	s = newTestSpool(t, dir, 10)
+	assert.Equal(t, 2, s.Len())
+
+	var got []float64
+	err := s.Replay(context.Background(), func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error) {
+		for _, m := range batch {
+			got = append(got, *m.Value)
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, 3}, got)
+	assert.Equal(t, 0, s.Len())
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func Test_SpoolReplayPartial(t *testing.T) {
+	s := newTestSpool(t, t.TempDir(), 10)
+	require.NoError(t, s.Push([]models.Metrics{gauge("a", 1), gauge("b", 2)}))
+	require.NoError(t, s.Push([]models.Metrics{gauge("c", 3)}))
+
+	calls := 0
+	err := s.Replay(context.Background(), func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error) {
+		calls++
+		return batch[1:], errSend
+	})
+	assert.ErrorIs(t, err, errSend)
+	assert.Equal(t, 1, calls, "replay stops on first error")
+	assert.Equal(t, 2, s.Len())
+
+	var got []string
+	err = s.Replay(context.Background(), func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error) {
+		for _, m := range batch {
+			got = append(got, m.ID)
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, got, "only not sent part is replayed")
+}
+
+func Test_SpoolBounded(t *testing.T) {
+	s := newTestSpool(t, t.TempDir(), 2)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.Push([]models.Metrics{gauge("a", float64(i))}))
+	}
+	assert.Equal(t, 2, s.Len())
+	var got []float64
+	require.NoError(t, s.Replay(context.Background(), func(ctx context.Context, batch []models.Metrics) ([]models.Metrics, error) {
+		got = append(got, *batch[0].Value)
+		return nil, nil
+	}))
+	assert.Equal(t, []float64{2, 3}, got, "oldest segments are dropped")
+}
@@ -0,0 +1,166 @@
+// Package textfile - metrics from files dropped by cron jobs and scripts
+package textfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/agent/collector"
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/promtext"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+var (
+	ErrBadKind = errors.New("unsupported kind")
+	ErrBadName = errors.New("no name")
+)
+
+// Textfile - reads *.json (array of models.Metrics) and *.prom (text exposition format) files of directory.
+// Files are snapshots: gauges are current values, counters are totals and increase since previous read is reported.
+// File with broken metric is skipped as a whole, TextfileError{file} is 1 for it and 0 otherwise
+type Textfile struct {
+	dir  string
+	prev map[string]int64
+}
+
+func NewTextfile(cfg *config.Config) *Textfile {
+	return &Textfile{
+		dir:  cfg.TextfileDir,
+		prev: make(map[string]int64),
+	}
+}
+
+func (c *Textfile) Name() string {
+	return "textfile"
+}
+
+func (c *Textfile) Interval() time.Duration {
+	return 0
+}
+
+func (c *Textfile) files() ([]string, error) {
+	var res []string
+	for _, pattern := range []string{"*.json", "*.prom"} {
+		list, err := filepath.Glob(filepath.Join(c.dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, list...)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (c *Textfile) Collect(ctx context.Context) ([]models.Metrics, error) {
+	if c.dir == "" {
+		return nil, nil
+	}
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	var res []models.Metrics
+	var errs []error
+	next := make(map[string]int64, len(c.prev))
+	for _, name := range files {
+		base := filepath.Base(name)
+		vals, mtime, errR := readFile(name)
+		if errR == nil {
+			var merged []models.Metrics
+			merged, errR = c.merge(vals, next)
+			res = append(res, merged...)
+		}
+		failed := 0.0
+		if errR != nil {
+			failed = 1
+			errs = append(errs, fmt.Errorf("%s: %w", base, errR))
+		} else {
+			res = append(res, fileGauge("TextfileMtime", base, float64(mtime.Unix())))
+		}
+		res = append(res, fileGauge("TextfileError", base, failed))
+	}
+	c.prev = next
+	return res, errors.Join(errs...)
+}
+
+func fileGauge(name, file string, v float64) models.Metrics {
+	m := collector.Gauge(name, v)
+	m.Labels = map[string]string{"file": file}
+	return m
+}
+
+func readFile(name string) ([]models.Metrics, time.Time, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var vals []models.Metrics
+	if strings.HasSuffix(name, ".json") {
+		vals, err = models.JSONSDecode(io.NopCloser(f))
+	} else {
+		vals, err = promtext.Decode(f)
+	}
+	return vals, st.ModTime(), err
+}
+
+// merge - validate all values of file, counter totals to deltas
+func (c *Textfile) merge(vals []models.Metrics, next map[string]int64) ([]models.Metrics, error) {
+	for _, v := range vals {
+		if err := validate(v); err != nil {
+			return nil, fmt.Errorf("metric %q: %w", v.ID, err)
+		}
+	}
+	res := make([]models.Metrics, 0, len(vals))
+	for _, v := range vals {
+		if v.MType != "counter" {
+			res = append(res, v)
+			continue
+		}
+		key, _ := v.SeriesKey()
+		total := *v.Delta
+		next[key] = total
+		prev, ok := c.prev[key]
+		if !ok {
+			continue
+		}
+		delta := total - prev
+		if total < prev {
+			delta = total
+		}
+		v.Delta = &delta
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func validate(v models.Metrics) error {
+	kind, err := valuemetric.GetKind(v.MType)
+	if err != nil {
+		return err
+	}
+	if valuemetric.GetKindStr(kind) == "histogram" {
+		return ErrBadKind
+	}
+	if v.ID == "" {
+		return ErrBadName
+	}
+	if _, err = v.SeriesKey(); err != nil {
+		return err
+	}
+	_, err = v.ConvertModelToMetric()
+	return err
+}
@@ -0,0 +1,65 @@
+package textfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/agent/config"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func values(t *testing.T, ms []models.Metrics) map[string]string {
+	res := make(map[string]string, len(ms))
+	for _, m := range ms {
+		key, err := m.SeriesKey()
+		require.NoError(t, err)
+		res[key] = m.MType + ":" + m.ConvertMetricToValue()
+	}
+	return res
+}
+
+func write(t *testing.T, dir, name, data string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+}
+
+func Test_TextfileCollect(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "backup.prom", "# TYPE backup_runs counter\nbackup_runs 10\nbackup_size{db=\"main\"} 300\n")
+	write(t, dir, "jobs.json", `[{"id":"queue_len","type":"gauge","value":4},{"id":"jobs_done","type":"counter","delta":100}]`)
+	write(t, dir, "broken.prom", "backup_runs{ 1\n")
+	write(t, dir, "badkind.json", `[{"id":"x","type":"summary","value":1}]`)
+	write(t, dir, "ignored.txt", "x 1\n")
+
+	c := NewTextfile(&config.Config{TextfileDir: dir})
+	res, err := c.Collect(context.Background())
+	assert.Error(t, err)
+	got := values(t, res)
+	assert.Equal(t, "gauge:300", got[`backup_size{db="main"}`])
+	assert.Equal(t, "gauge:4", got["queue_len"])
+	assert.NotContains(t, got, "backup_runs", "first read of counter is baseline")
+	assert.NotContains(t, got, "jobs_done")
+	assert.Equal(t, "gauge:0", got[`TextfileError{file="backup.prom"}`])
+	assert.Equal(t, "gauge:1", got[`TextfileError{file="broken.prom"}`])
+	assert.Equal(t, "gauge:1", got[`TextfileError{file="badkind.json"}`])
+	assert.Contains(t, got, `TextfileMtime{file="jobs.json"}`)
+	assert.NotContains(t, got, `TextfileError{file="ignored.txt"}`)
+
+	write(t, dir, "backup.prom", "# TYPE backup_runs counter\nbackup_runs 12\n")
+	write(t, dir, "jobs.json", `[{"id":"jobs_done","type":"counter","delta":5}]`)
+	res, _ = c.Collect(context.Background())
+	got = values(t, res)
+	assert.Equal(t, "counter:2", got["backup_runs"])
+	assert.Equal(t, "counter:5", got["jobs_done"], "counter reset")
+	assert.NotContains(t, got, `backup_size{db="main"}`)
+}
+
+func Test_TextfileDisabled(t *testing.T) {
+	c := NewTextfile(&config.Config{})
+	res, err := c.Collect(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
@@ -0,0 +1,114 @@
+// Package grpcsign - HMAC-SHA256 signature of gRPC requests in metadata
+package grpcsign
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metadata keys, values are same as of hmacsha256 headers
+const (
+	MDHash      = "hashsha256"
+	MDTimestamp = "x-timestamp"
+	MDNonce     = "x-nonce"
+	MDKeyID     = "key-id"
+
+	// signedMethod - method of hmacsha256.SignedPrefix, target is full gRPC method
+	signedMethod = "GRPC"
+)
+
+var (
+	ErrUnsigned     = errors.New("request is not signed")
+	ErrBadSignature = errors.New("signature mismatch")
+	ErrNotProto     = errors.New("request is not proto message")
+)
+
+// Sign - hex signature of full gRPC method, timestamp, nonce and deterministic encoding of request
+func Sign(key []byte, method, timestamp, nonce string, req any) (string, error) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return "", ErrNotProto
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	hw := hmacsha256.NewWriter(io.Discard, key)
+	hw.Seed(hmacsha256.SignedPrefix(signedMethod, method, timestamp, nonce))
+	_, _ = hw.Write(data)
+	return hex.EncodeToString(hw.GetSig()), nil
+}
+
+// Verify - checks signature of incoming request with key of key id in metadata.
+// Timestamp and nonce are returned for replay check, ErrUnsigned if request has no signature
+func Verify(ctx context.Context, lookup func(keyID string) ([]byte, error), method string, req any) (timestamp, nonce string, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	sig, timestamp, nonce := first(md, MDHash), first(md, MDTimestamp), first(md, MDNonce)
+	if sig == "" {
+		return "", "", ErrUnsigned
+	}
+	key, err := lookup(first(md, MDKeyID))
+	if err != nil {
+		return "", "", err
+	}
+	want, err := Sign(key, method, timestamp, nonce, req)
+	if err != nil {
+		return "", "", err
+	}
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return "", "", ErrBadSignature
+	}
+	return timestamp, nonce, nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// KeyID - key id of incoming request
+func KeyID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return first(md, MDKeyID)
+}
+
+// UnaryClientInterceptor - signs every call with fresh timestamp and nonce, empty keyID is legacy shared key
+func UnaryClientInterceptor(keyID string, key []byte) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := SignContext(ctx, keyID, key, method, req)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// SignContext - outgoing context with signature of req, for server streaming calls
+// it must be used by caller, as metadata is sent before request message
+func SignContext(ctx context.Context, keyID string, key []byte, method string, req any) (context.Context, error) {
+	timestamp, nonce, err := hmacsha256.NewNonce(time.Now())
+	if err != nil {
+		return ctx, err
+	}
+	sig, err := Sign(key, method, timestamp, nonce, req)
+	if err != nil {
+		return ctx, err
+	}
+	kv := []string{MDHash, sig, MDTimestamp, timestamp, MDNonce, nonce}
+	if keyID != "" {
+		kv = append(kv, MDKeyID, keyID)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...), nil
+}
@@ -0,0 +1,81 @@
+package grpcsign
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/4aleksei/metricscum/internal/common/grpcmetrics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+const testMethod = "/grpcmetrics.StreamMultiService/UpdateRequest"
+
+// incoming - server side context of signed outgoing ctx
+func incoming(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// keys - lookup of single key
+func keys(id string, key []byte) func(string) ([]byte, error) {
+	return func(keyID string) ([]byte, error) {
+		if keyID != id {
+			return nil, errUnknown
+		}
+		return key, nil
+	}
+}
+
+var errUnknown = errors.New("unknown key")
+
+func Test_SignVerify(t *testing.T) {
+	key := []byte("secret")
+	lookup := keys("", key)
+	req := &pb.Request{Value: &pb.Metric{Name: "c", Type: pb.Metric_COUNTER}}
+	ctx, err := SignContext(context.Background(), "", key, testMethod, req)
+	require.NoError(t, err)
+
+	ts, nonce, err := Verify(incoming(ctx), lookup, testMethod, req)
+	require.NoError(t, err)
+	assert.NotEmpty(t, ts)
+	assert.NotEmpty(t, nonce)
+
+	_, _, err = Verify(incoming(ctx), keys("", []byte("other")), testMethod, req)
+	assert.ErrorIs(t, err, ErrBadSignature, "other key")
+
+	_, _, err = Verify(incoming(ctx), lookup, "/grpcmetrics.StreamMultiService/GetMetric", req)
+	assert.ErrorIs(t, err, ErrBadSignature, "other method")
+
+	changed := &pb.Request{Value: &pb.Metric{Name: "d", Type: pb.Metric_COUNTER}}
+	_, _, err = Verify(incoming(ctx), lookup, testMethod, changed)
+	assert.ErrorIs(t, err, ErrBadSignature, "changed request")
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(MDNonce, "00000000000000000000000000000000")
+	_, _, err = Verify(metadata.NewIncomingContext(context.Background(), md), lookup, testMethod, req)
+	assert.ErrorIs(t, err, ErrBadSignature, "changed nonce")
+
+	_, _, err = Verify(context.Background(), lookup, testMethod, req)
+	assert.ErrorIs(t, err, ErrUnsigned)
+
+	_, err = Sign(key, testMethod, ts, nonce, "not proto")
+	assert.ErrorIs(t, err, ErrNotProto)
+}
+
+func Test_SignKeyID(t *testing.T) {
+	key := []byte("agent secret")
+	req := &pb.Request{Value: &pb.Metric{Name: "c", Type: pb.Metric_COUNTER}}
+	ctx, err := SignContext(context.Background(), "agent-1", key, testMethod, req)
+	require.NoError(t, err)
+	assert.Equal(t, "agent-1", KeyID(incoming(ctx)))
+
+	_, _, err = Verify(incoming(ctx), keys("agent-1", key), testMethod, req)
+	assert.NoError(t, err)
+
+	_, _, err = Verify(incoming(ctx), keys("", key), testMethod, req)
+	assert.ErrorIs(t, err, errUnknown, "key id is not legacy key")
+}
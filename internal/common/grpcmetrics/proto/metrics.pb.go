@@ -27,6 +27,7 @@ const (
 	Metric_UNSPECIFIED Metric_Type = 0
 	Metric_COUNTER     Metric_Type = 1
 	Metric_GAUGE       Metric_Type = 2
+	Metric_HISTOGRAM   Metric_Type = 3
 )
 
 // Enum value maps for Metric_Type.
@@ -35,11 +36,13 @@ var (
 		0: "UNSPECIFIED",
 		1: "COUNTER",
 		2: "GAUGE",
+		3: "HISTOGRAM",
 	}
 	Metric_Type_value = map[string]int32{
 		"UNSPECIFIED": 0,
 		"COUNTER":     1,
 		"GAUGE":       2,
+		"HISTOGRAM":   3,
 	}
 )
 
@@ -76,6 +79,8 @@ type Metric struct {
 	Type          Metric_Type            `protobuf:"varint,2,opt,name=type,proto3,enum=grpcmetrics.Metric_Type" json:"type,omitempty"` // тип метрики
 	Counter       int64                  `protobuf:"varint,3,opt,name=counter,proto3" json:"counter,omitempty"`
 	Gauge         float64                `protobuf:"fixed64,4,opt,name=gauge,proto3" json:"gauge,omitempty"`
+	Histogram     *Histogram             `protobuf:"bytes,5,opt,name=histogram,proto3" json:"histogram,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // метки, входят в идентичность метрики
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -138,6 +143,88 @@ func (x *Metric) GetGauge() float64 {
 	return 0
 }
 
+func (x *Metric) GetHistogram() *Histogram {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+func (x *Metric) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type Histogram struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        []float64              `protobuf:"fixed64,1,rep,packed,name=bounds,proto3" json:"bounds,omitempty"` // верхние границы корзин, +Inf не передаётся
+	Counts        []uint64               `protobuf:"varint,2,rep,packed,name=counts,proto3" json:"counts,omitempty"`  // len(bounds)+1
+	Sum           float64                `protobuf:"fixed64,3,opt,name=sum,proto3" json:"sum,omitempty"`
+	Count         uint64                 `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_proto_metrics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Histogram) GetBounds() []float64 {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Histogram) GetCounts() []uint64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *Histogram) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+func (x *Histogram) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
 type Response struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Value         *Metric                `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
@@ -147,7 +234,7 @@ type Response struct {
 
 func (x *Response) Reset() {
 	*x = Response{}
-	mi := &file_proto_metrics_proto_msgTypes[1]
+	mi := &file_proto_metrics_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -159,7 +246,7 @@ func (x *Response) String() string {
 func (*Response) ProtoMessage() {}
 
 func (x *Response) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[1]
+	mi := &file_proto_metrics_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -172,7 +259,7 @@ func (x *Response) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Response.ProtoReflect.Descriptor instead.
 func (*Response) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{1}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Response) GetValue() *Metric {
@@ -191,7 +278,7 @@ type Request struct {
 
 func (x *Request) Reset() {
 	*x = Request{}
-	mi := &file_proto_metrics_proto_msgTypes[2]
+	mi := &file_proto_metrics_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -203,7 +290,7 @@ func (x *Request) String() string {
 func (*Request) ProtoMessage() {}
 
 func (x *Request) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[2]
+	mi := &file_proto_metrics_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -216,7 +303,7 @@ func (x *Request) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Request.ProtoReflect.Descriptor instead.
 func (*Request) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{2}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Request) GetValue() *Metric {
@@ -235,7 +322,7 @@ type MultiUpdate struct {
 
 func (x *MultiUpdate) Reset() {
 	*x = MultiUpdate{}
-	mi := &file_proto_metrics_proto_msgTypes[3]
+	mi := &file_proto_metrics_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -247,7 +334,7 @@ func (x *MultiUpdate) String() string {
 func (*MultiUpdate) ProtoMessage() {}
 
 func (x *MultiUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[3]
+	mi := &file_proto_metrics_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -260,7 +347,7 @@ func (x *MultiUpdate) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MultiUpdate.ProtoReflect.Descriptor instead.
 func (*MultiUpdate) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{3}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *MultiUpdate) GetValues() []*Metric {
@@ -278,7 +365,7 @@ type RequestMetrics struct {
 
 func (x *RequestMetrics) Reset() {
 	*x = RequestMetrics{}
-	mi := &file_proto_metrics_proto_msgTypes[4]
+	mi := &file_proto_metrics_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -290,7 +377,7 @@ func (x *RequestMetrics) String() string {
 func (*RequestMetrics) ProtoMessage() {}
 
 func (x *RequestMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[4]
+	mi := &file_proto_metrics_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -303,7 +390,7 @@ func (x *RequestMetrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RequestMetrics.ProtoReflect.Descriptor instead.
 func (*RequestMetrics) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{4}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{5}
 }
 
 type MultiResponse struct {
@@ -315,7 +402,7 @@ type MultiResponse struct {
 
 func (x *MultiResponse) Reset() {
 	*x = MultiResponse{}
-	mi := &file_proto_metrics_proto_msgTypes[5]
+	mi := &file_proto_metrics_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -327,7 +414,7 @@ func (x *MultiResponse) String() string {
 func (*MultiResponse) ProtoMessage() {}
 
 func (x *MultiResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[5]
+	mi := &file_proto_metrics_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -340,7 +427,7 @@ func (x *MultiResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MultiResponse.ProtoReflect.Descriptor instead.
 func (*MultiResponse) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{5}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *MultiResponse) GetValues() []*Metric {
@@ -350,6 +437,178 @@ func (x *MultiResponse) GetValues() []*Metric {
 	return nil
 }
 
+type HistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *Metric                `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"` // имя, тип и метки серии
+	From          int64                  `protobuf:"varint,2,opt,name=from,proto3" json:"from,omitempty"`  // unix время в миллисекундах, 0 - за час до to
+	To            int64                  `protobuf:"varint,3,opt,name=to,proto3" json:"to,omitempty"`      // unix время в миллисекундах, 0 - сейчас
+	Step          int64                  `protobuf:"varint,4,opt,name=step,proto3" json:"step,omitempty"`  // шаг прореживания в миллисекундах, 0 - все точки
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryRequest) Reset() {
+	*x = HistoryRequest{}
+	mi := &file_proto_metrics_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryRequest) ProtoMessage() {}
+
+func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
+func (*HistoryRequest) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HistoryRequest) GetValue() *Metric {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *HistoryRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetStep() int64 {
+	if x != nil {
+		return x.Step
+	}
+	return 0
+}
+
+type HistoryPoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          int64                  `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"` // unix время в миллисекундах
+	Counter       int64                  `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+	Gauge         float64                `protobuf:"fixed64,3,opt,name=gauge,proto3" json:"gauge,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryPoint) Reset() {
+	*x = HistoryPoint{}
+	mi := &file_proto_metrics_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryPoint) ProtoMessage() {}
+
+func (x *HistoryPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryPoint.ProtoReflect.Descriptor instead.
+func (*HistoryPoint) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *HistoryPoint) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *HistoryPoint) GetCounter() int64 {
+	if x != nil {
+		return x.Counter
+	}
+	return 0
+}
+
+func (x *HistoryPoint) GetGauge() float64 {
+	if x != nil {
+		return x.Gauge
+	}
+	return 0
+}
+
+type HistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Points        []*HistoryPoint        `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryResponse) Reset() {
+	*x = HistoryResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryResponse) ProtoMessage() {}
+
+func (x *HistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryResponse.ProtoReflect.Descriptor instead.
+func (*HistoryResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *HistoryResponse) GetPoints() []*HistoryPoint {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
 type RequestPing struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -358,7 +617,7 @@ type RequestPing struct {
 
 func (x *RequestPing) Reset() {
 	*x = RequestPing{}
-	mi := &file_proto_metrics_proto_msgTypes[6]
+	mi := &file_proto_metrics_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -370,7 +629,7 @@ func (x *RequestPing) String() string {
 func (*RequestPing) ProtoMessage() {}
 
 func (x *RequestPing) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[6]
+	mi := &file_proto_metrics_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -383,7 +642,7 @@ func (x *RequestPing) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RequestPing.ProtoReflect.Descriptor instead.
 func (*RequestPing) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{6}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{10}
 }
 
 type ResposePing struct {
@@ -394,7 +653,7 @@ type ResposePing struct {
 
 func (x *ResposePing) Reset() {
 	*x = ResposePing{}
-	mi := &file_proto_metrics_proto_msgTypes[7]
+	mi := &file_proto_metrics_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -406,7 +665,7 @@ func (x *ResposePing) String() string {
 func (*ResposePing) ProtoMessage() {}
 
 func (x *ResposePing) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[7]
+	mi := &file_proto_metrics_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -419,23 +678,34 @@ func (x *ResposePing) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResposePing.ProtoReflect.Descriptor instead.
 func (*ResposePing) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{7}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{11}
 }
 
 var File_proto_metrics_proto protoreflect.FileDescriptor
 
 const file_proto_metrics_proto_rawDesc = "" +
 	"\n" +
-	"\x13proto/metrics.proto\x12\vgrpcmetrics\"\xab\x01\n" +
+	"\x13proto/metrics.proto\x12\vgrpcmetrics\"\xe4\x02\n" +
 	"\x06Metric\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12,\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x18.grpcmetrics.Metric.TypeR\x04type\x12\x18\n" +
 	"\acounter\x18\x03 \x01(\x03R\acounter\x12\x14\n" +
-	"\x05gauge\x18\x04 \x01(\x01R\x05gauge\"/\n" +
+	"\x05gauge\x18\x04 \x01(\x01R\x05gauge\x124\n" +
+	"\thistogram\x18\x05 \x01(\v2\x16.grpcmetrics.HistogramR\thistogram\x127\n" +
+	"\x06labels\x18\x06 \x03(\v2\x1f.grpcmetrics.Metric.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\">\n" +
 	"\x04Type\x12\x0f\n" +
 	"\vUNSPECIFIED\x10\x00\x12\v\n" +
 	"\aCOUNTER\x10\x01\x12\t\n" +
-	"\x05GAUGE\x10\x02\"5\n" +
+	"\x05GAUGE\x10\x02\x12\r\n" +
+	"\tHISTOGRAM\x10\x03\"c\n" +
+	"\tHistogram\x12\x16\n" +
+	"\x06bounds\x18\x01 \x03(\x01R\x06bounds\x12\x16\n" +
+	"\x06counts\x18\x02 \x03(\x04R\x06counts\x12\x10\n" +
+	"\x03sum\x18\x03 \x01(\x01R\x03sum\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x04R\x05count\"5\n" +
 	"\bResponse\x12)\n" +
 	"\x05value\x18\x01 \x01(\v2\x13.grpcmetrics.MetricR\x05value\"4\n" +
 	"\aRequest\x12)\n" +
@@ -444,15 +714,28 @@ const file_proto_metrics_proto_rawDesc = "" +
 	"\x06values\x18\x01 \x03(\v2\x13.grpcmetrics.MetricR\x06values\"\x10\n" +
 	"\x0eRequestMetrics\"<\n" +
 	"\rMultiResponse\x12+\n" +
-	"\x06values\x18\x01 \x03(\v2\x13.grpcmetrics.MetricR\x06values\"\r\n" +
+	"\x06values\x18\x01 \x03(\v2\x13.grpcmetrics.MetricR\x06values\"s\n" +
+	"\x0eHistoryRequest\x12)\n" +
+	"\x05value\x18\x01 \x01(\v2\x13.grpcmetrics.MetricR\x05value\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\x03R\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\x03R\x02to\x12\x12\n" +
+	"\x04step\x18\x04 \x01(\x03R\x04step\"R\n" +
+	"\fHistoryPoint\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\x03R\x04time\x12\x18\n" +
+	"\acounter\x18\x02 \x01(\x03R\acounter\x12\x14\n" +
+	"\x05gauge\x18\x03 \x01(\x01R\x05gauge\"D\n" +
+	"\x0fHistoryResponse\x121\n" +
+	"\x06points\x18\x01 \x03(\v2\x19.grpcmetrics.HistoryPointR\x06points\"\r\n" +
 	"\vRequestPing\"\r\n" +
-	"\vResposePing2\x9a\x02\n" +
+	"\vResposePing2\xe3\x02\n" +
 	"\x12StreamMultiService\x12<\n" +
 	"\rUpdateRequest\x12\x14.grpcmetrics.Request\x1a\x15.grpcmetrics.Response\x12J\n" +
 	"\x12MultiUpdateRequest\x12\x18.grpcmetrics.MultiUpdate\x1a\x1a.grpcmetrics.MultiResponse\x128\n" +
 	"\tGetMetric\x12\x14.grpcmetrics.Request\x1a\x15.grpcmetrics.Response\x12@\n" +
 	"\n" +
-	"GetMetrics\x12\x1b.grpcmetrics.RequestMetrics\x1a\x13.grpcmetrics.Metric0\x01B<Z:github.com/4aleksei/metricscum/internal/common/grpcmetricsb\x06proto3"
+	"GetMetrics\x12\x1b.grpcmetrics.RequestMetrics\x1a\x13.grpcmetrics.Metric0\x01\x12G\n" +
+	"\n" +
+	"GetHistory\x12\x1b.grpcmetrics.HistoryRequest\x1a\x1c.grpcmetrics.HistoryResponseB<Z:github.com/4aleksei/metricscum/internal/common/grpcmetricsb\x06proto3"
 
 var (
 	file_proto_metrics_proto_rawDescOnce sync.Once
@@ -467,37 +750,48 @@ func file_proto_metrics_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_proto_metrics_proto_goTypes = []any{
-	(Metric_Type)(0),       // 0: grpcmetrics.Metric.Type
-	(*Metric)(nil),         // 1: grpcmetrics.Metric
-	(*Response)(nil),       // 2: grpcmetrics.Response
-	(*Request)(nil),        // 3: grpcmetrics.Request
-	(*MultiUpdate)(nil),    // 4: grpcmetrics.MultiUpdate
-	(*RequestMetrics)(nil), // 5: grpcmetrics.RequestMetrics
-	(*MultiResponse)(nil),  // 6: grpcmetrics.MultiResponse
-	(*RequestPing)(nil),    // 7: grpcmetrics.RequestPing
-	(*ResposePing)(nil),    // 8: grpcmetrics.ResposePing
+	(Metric_Type)(0),        // 0: grpcmetrics.Metric.Type
+	(*Metric)(nil),          // 1: grpcmetrics.Metric
+	(*Histogram)(nil),       // 2: grpcmetrics.Histogram
+	(*Response)(nil),        // 3: grpcmetrics.Response
+	(*Request)(nil),         // 4: grpcmetrics.Request
+	(*MultiUpdate)(nil),     // 5: grpcmetrics.MultiUpdate
+	(*RequestMetrics)(nil),  // 6: grpcmetrics.RequestMetrics
+	(*MultiResponse)(nil),   // 7: grpcmetrics.MultiResponse
+	(*HistoryRequest)(nil),  // 8: grpcmetrics.HistoryRequest
+	(*HistoryPoint)(nil),    // 9: grpcmetrics.HistoryPoint
+	(*HistoryResponse)(nil), // 10: grpcmetrics.HistoryResponse
+	(*RequestPing)(nil),     // 11: grpcmetrics.RequestPing
+	(*ResposePing)(nil),     // 12: grpcmetrics.ResposePing
+	nil,                     // 13: grpcmetrics.Metric.LabelsEntry
 }
 var file_proto_metrics_proto_depIdxs = []int32{
-	0, // 0: grpcmetrics.Metric.type:type_name -> grpcmetrics.Metric.Type
-	1, // 1: grpcmetrics.Response.value:type_name -> grpcmetrics.Metric
-	1, // 2: grpcmetrics.Request.value:type_name -> grpcmetrics.Metric
-	1, // 3: grpcmetrics.MultiUpdate.values:type_name -> grpcmetrics.Metric
-	1, // 4: grpcmetrics.MultiResponse.values:type_name -> grpcmetrics.Metric
-	3, // 5: grpcmetrics.StreamMultiService.UpdateRequest:input_type -> grpcmetrics.Request
-	4, // 6: grpcmetrics.StreamMultiService.MultiUpdateRequest:input_type -> grpcmetrics.MultiUpdate
-	3, // 7: grpcmetrics.StreamMultiService.GetMetric:input_type -> grpcmetrics.Request
-	5, // 8: grpcmetrics.StreamMultiService.GetMetrics:input_type -> grpcmetrics.RequestMetrics
-	2, // 9: grpcmetrics.StreamMultiService.UpdateRequest:output_type -> grpcmetrics.Response
-	6, // 10: grpcmetrics.StreamMultiService.MultiUpdateRequest:output_type -> grpcmetrics.MultiResponse
-	2, // 11: grpcmetrics.StreamMultiService.GetMetric:output_type -> grpcmetrics.Response
-	1, // 12: grpcmetrics.StreamMultiService.GetMetrics:output_type -> grpcmetrics.Metric
-	9, // [9:13] is the sub-list for method output_type
-	5, // [5:9] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	0,  // 0: grpcmetrics.Metric.type:type_name -> grpcmetrics.Metric.Type
+	2,  // 1: grpcmetrics.Metric.histogram:type_name -> grpcmetrics.Histogram
+	13, // 2: grpcmetrics.Metric.labels:type_name -> grpcmetrics.Metric.LabelsEntry
+	1,  // 3: grpcmetrics.Response.value:type_name -> grpcmetrics.Metric
+	1,  // 4: grpcmetrics.Request.value:type_name -> grpcmetrics.Metric
+	1,  // 5: grpcmetrics.MultiUpdate.values:type_name -> grpcmetrics.Metric
+	1,  // 6: grpcmetrics.MultiResponse.values:type_name -> grpcmetrics.Metric
+	1,  // 7: grpcmetrics.HistoryRequest.value:type_name -> grpcmetrics.Metric
+	9,  // 8: grpcmetrics.HistoryResponse.points:type_name -> grpcmetrics.HistoryPoint
+	4,  // 9: grpcmetrics.StreamMultiService.UpdateRequest:input_type -> grpcmetrics.Request
+	5,  // 10: grpcmetrics.StreamMultiService.MultiUpdateRequest:input_type -> grpcmetrics.MultiUpdate
+	4,  // 11: grpcmetrics.StreamMultiService.GetMetric:input_type -> grpcmetrics.Request
+	6,  // 12: grpcmetrics.StreamMultiService.GetMetrics:input_type -> grpcmetrics.RequestMetrics
+	8,  // 13: grpcmetrics.StreamMultiService.GetHistory:input_type -> grpcmetrics.HistoryRequest
+	3,  // 14: grpcmetrics.StreamMultiService.UpdateRequest:output_type -> grpcmetrics.Response
+	7,  // 15: grpcmetrics.StreamMultiService.MultiUpdateRequest:output_type -> grpcmetrics.MultiResponse
+	3,  // 16: grpcmetrics.StreamMultiService.GetMetric:output_type -> grpcmetrics.Response
+	1,  // 17: grpcmetrics.StreamMultiService.GetMetrics:output_type -> grpcmetrics.Metric
+	10, // 18: grpcmetrics.StreamMultiService.GetHistory:output_type -> grpcmetrics.HistoryResponse
+	14, // [14:19] is the sub-list for method output_type
+	9,  // [9:14] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_proto_metrics_proto_init() }
@@ -511,7 +805,7 @@ func file_proto_metrics_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_metrics_proto_rawDesc), len(file_proto_metrics_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   8,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
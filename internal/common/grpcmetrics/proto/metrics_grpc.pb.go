@@ -23,6 +23,7 @@ const (
 	StreamMultiService_MultiUpdateRequest_FullMethodName = "/grpcmetrics.StreamMultiService/MultiUpdateRequest"
 	StreamMultiService_GetMetric_FullMethodName          = "/grpcmetrics.StreamMultiService/GetMetric"
 	StreamMultiService_GetMetrics_FullMethodName         = "/grpcmetrics.StreamMultiService/GetMetrics"
+	StreamMultiService_GetHistory_FullMethodName         = "/grpcmetrics.StreamMultiService/GetHistory"
 )
 
 // StreamMultiServiceClient is the client API for StreamMultiService service.
@@ -33,6 +34,7 @@ type StreamMultiServiceClient interface {
 	MultiUpdateRequest(ctx context.Context, in *MultiUpdate, opts ...grpc.CallOption) (*MultiResponse, error)
 	GetMetric(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
 	GetMetrics(ctx context.Context, in *RequestMetrics, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Metric], error)
+	GetHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
 }
 
 type streamMultiServiceClient struct {
@@ -92,6 +94,16 @@ func (c *streamMultiServiceClient) GetMetrics(ctx context.Context, in *RequestMe
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type StreamMultiService_GetMetricsClient = grpc.ServerStreamingClient[Metric]
 
+func (c *streamMultiServiceClient) GetHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, StreamMultiService_GetHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StreamMultiServiceServer is the server API for StreamMultiService service.
 // All implementations must embed UnimplementedStreamMultiServiceServer
 // for forward compatibility.
@@ -100,6 +112,7 @@ type StreamMultiServiceServer interface {
 	MultiUpdateRequest(context.Context, *MultiUpdate) (*MultiResponse, error)
 	GetMetric(context.Context, *Request) (*Response, error)
 	GetMetrics(*RequestMetrics, grpc.ServerStreamingServer[Metric]) error
+	GetHistory(context.Context, *HistoryRequest) (*HistoryResponse, error)
 	mustEmbedUnimplementedStreamMultiServiceServer()
 }
 
@@ -122,6 +135,9 @@ func (UnimplementedStreamMultiServiceServer) GetMetric(context.Context, *Request
 func (UnimplementedStreamMultiServiceServer) GetMetrics(*RequestMetrics, grpc.ServerStreamingServer[Metric]) error {
 	return status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
 }
+func (UnimplementedStreamMultiServiceServer) GetHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
 func (UnimplementedStreamMultiServiceServer) mustEmbedUnimplementedStreamMultiServiceServer() {}
 func (UnimplementedStreamMultiServiceServer) testEmbeddedByValue()                            {}
 
@@ -208,6 +224,24 @@ func _StreamMultiService_GetMetrics_Handler(srv interface{}, stream grpc.ServerS
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type StreamMultiService_GetMetricsServer = grpc.ServerStreamingServer[Metric]
 
+func _StreamMultiService_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamMultiServiceServer).GetHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StreamMultiService_GetHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamMultiServiceServer).GetHistory(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StreamMultiService_ServiceDesc is the grpc.ServiceDesc for StreamMultiService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -227,6 +261,10 @@ var StreamMultiService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetMetric",
 			Handler:    _StreamMultiService_GetMetric_Handler,
 		},
+		{
+			MethodName: "GetHistory",
+			Handler:    _StreamMultiService_GetHistory_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
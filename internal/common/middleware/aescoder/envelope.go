@@ -0,0 +1,251 @@
+package aescoder
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Envelope v1 layout:
+//
+//	header: magic "MCE", version byte, 7 bytes random nonce prefix
+//	frame:  uint32 big endian - final flag in high bit, sealed chunk length; sealed chunk
+//
+// Chunk nonce is prefix, uint32 chunk counter and final flag byte, header is additional data of
+// every chunk, so reordered, truncated or extended streams are not opened.
+// Key is 32 bytes AES-256, wrapped with RSA-OAEP by caller
+const (
+	HeaderEnvelope = "AES-256-Envelope" // hex RSA-OAEP wrapped key of envelope
+	HeaderLegacy   = "AES-256"          // hex RSA PKCS#1 v1.5 wrapped key of legacy stream
+
+	EnvelopeVersion byte = 1
+	ChunkSize            = 64 << 10
+	KeySize              = 32
+
+	prefixSize = 7
+	headerSize = len(envelopeMagic) + 1 + prefixSize
+	finalFlag  = uint32(1) << 31
+)
+
+const envelopeMagic = "MCE"
+
+var (
+	ErrBadEnvelope = errors.New("invalid envelope")
+	ErrVersion     = errors.New("unsupported envelope version")
+	ErrTruncated   = errors.New("envelope truncated")
+	ErrClosed      = errors.New("envelope closed")
+)
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("%w: key size %d", ErrBadEnvelope, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, prefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if final {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// EnvelopeWriter - seals data in chunks of ChunkSize, Close writes final chunk
+type EnvelopeWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	out     []byte
+	counter uint32
+	started bool
+	closed  bool
+}
+
+func NewEnvelopeWriter(w io.Writer, key []byte) (*EnvelopeWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, headerSize)
+	header = append(header, envelopeMagic...)
+	header = append(header, EnvelopeVersion)
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	header = append(header, prefix...)
+	return &EnvelopeWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+func (e *EnvelopeWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, ErrClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(e.buf) == ChunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):ChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close - seals rest of data as final chunk, underlying writer is not closed
+func (e *EnvelopeWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *EnvelopeWriter) seal(final bool) error {
+	if !e.started {
+		if _, err := e.w.Write(e.header); err != nil {
+			return err
+		}
+		e.started = true
+	}
+	if e.counter == math.MaxUint32 {
+		return fmt.Errorf("%w: too many chunks", ErrBadEnvelope)
+	}
+	e.out = binary.BigEndian.AppendUint32(e.out[:0], 0)
+	e.out = e.aead.Seal(e.out, chunkNonce(e.header[headerSize-prefixSize:], e.counter, final), e.buf, e.header)
+	length := uint32(len(e.out) - 4)
+	if final {
+		length |= finalFlag
+	}
+	binary.BigEndian.PutUint32(e.out, length)
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(e.out)
+	return err
+}
+
+// EnvelopeReader - opens chunks of envelope, stream ended before final chunk is ErrTruncated
+type EnvelopeReader struct {
+	r       io.ReadCloser
+	aead    cipher.AEAD
+	header  []byte
+	sealed  []byte
+	plain   []byte
+	pos     int
+	counter uint32
+	final   bool
+	err     error
+}
+
+func NewEnvelopeReader(r io.ReadCloser, key []byte) (*EnvelopeReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EnvelopeReader{
+		r:    r,
+		aead: aead,
+	}, nil
+}
+
+func (e *EnvelopeReader) Read(p []byte) (int, error) {
+	for e.pos == len(e.plain) {
+		if e.err != nil {
+			return 0, e.err
+		}
+		e.err = e.next()
+	}
+	n := copy(p, e.plain[e.pos:])
+	e.pos += n
+	return n, nil
+}
+
+func (e *EnvelopeReader) readHeader() error {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(e.r, header); err != nil {
+		return truncated(err)
+	}
+	if !bytes.Equal(header[:len(envelopeMagic)], []byte(envelopeMagic)) {
+		return ErrBadEnvelope
+	}
+	if header[len(envelopeMagic)] != EnvelopeVersion {
+		return fmt.Errorf("%w: %d", ErrVersion, header[len(envelopeMagic)])
+	}
+	e.header = header
+	return nil
+}
+
+// next - opens next chunk, after final chunk stream must end
+func (e *EnvelopeReader) next() error {
+	if e.header == nil {
+		if err := e.readHeader(); err != nil {
+			return err
+		}
+	}
+	if e.final {
+		var extra [1]byte
+		if n, _ := io.ReadFull(e.r, extra[:]); n > 0 {
+			return fmt.Errorf("%w: data after final chunk", ErrBadEnvelope)
+		}
+		return io.EOF
+	}
+	var frame [4]byte
+	if _, err := io.ReadFull(e.r, frame[:]); err != nil {
+		return truncated(err)
+	}
+	length := binary.BigEndian.Uint32(frame[:])
+	final := length&finalFlag != 0
+	length &^= finalFlag
+	if length < uint32(e.aead.Overhead()) || length > uint32(ChunkSize+e.aead.Overhead()) {
+		return fmt.Errorf("%w: chunk length %d", ErrBadEnvelope, length)
+	}
+	if cap(e.sealed) < int(length) {
+		e.sealed = make([]byte, length)
+	}
+	e.sealed = e.sealed[:length]
+	if _, err := io.ReadFull(e.r, e.sealed); err != nil {
+		return truncated(err)
+	}
+	plain, err := e.aead.Open(e.plain[:0], chunkNonce(e.header[headerSize-prefixSize:], e.counter, final), e.sealed, e.header)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d: %w", ErrBadEnvelope, e.counter, err)
+	}
+	e.plain, e.pos = plain, 0
+	e.counter++
+	e.final = final
+	return nil
+}
+
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTruncated
+	}
+	return err
+}
+
+func (e *EnvelopeReader) Close() error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package aescoder
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(t *testing.T) []byte {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+// seal - envelope of data written by writes of given size
+func seal(t *testing.T, key, data []byte, write int) []byte {
+	var buf bytes.Buffer
+	w, err := NewEnvelopeWriter(&buf, key)
+	require.NoError(t, err)
+	for len(data) > 0 {
+		n := min(write, len(data))
+		m, err := w.Write(data[:n])
+		require.NoError(t, err)
+		require.Equal(t, n, m)
+		data = data[n:]
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func open(key, sealed []byte, wrap func(io.Reader) io.Reader) ([]byte, error) {
+	r, err := NewEnvelopeReader(io.NopCloser(wrap(bytes.NewReader(sealed))), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func plain(r io.Reader) io.Reader { return r }
+
+func Test_EnvelopeRoundTrip(t *testing.T) {
+	key := testKey(t)
+	sizes := []int{0, 1, 1000, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17}
+	for _, size := range sizes {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		for _, write := range []int{1 << 30, 4096, 777} {
+			sealed := seal(t, key, data, write)
+			got, err := open(key, sealed, plain)
+			require.NoError(t, err, "size %d write %d", size, write)
+			assert.Equal(t, data, got)
+
+			got, err = open(key, sealed, iotest.OneByteReader)
+			require.NoError(t, err)
+			assert.Equal(t, data, got)
+
+			got, err = open(key, sealed, iotest.DataErrReader)
+			require.NoError(t, err)
+			assert.Equal(t, data, got)
+		}
+	}
+}
+
+func Test_EnvelopeUniqueNonce(t *testing.T) {
+	key := testKey(t)
+	data := []byte("same data")
+	assert.NotEqual(t, seal(t, key, data, 100), seal(t, key, data, 100))
+}
+
+func Test_EnvelopeBroken(t *testing.T) {
+	key := testKey(t)
+	data := make([]byte, 2*ChunkSize+100)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	sealed := seal(t, key, data, len(data))
+	chunk := 4 + ChunkSize + 16
+	first := headerSize
+
+	tests := []struct {
+		name   string
+		mutate func(b []byte) []byte
+		want   error
+	}{
+		{name: "wrong magic", mutate: func(b []byte) []byte { b[0] = 'X'; return b }, want: ErrBadEnvelope},
+		{name: "wrong version", mutate: func(b []byte) []byte { b[3] = 9; return b }, want: ErrVersion},
+		{name: "changed prefix", mutate: func(b []byte) []byte { b[5] ^= 1; return b }, want: ErrBadEnvelope},
+		{name: "changed data", mutate: func(b []byte) []byte { b[first+100] ^= 1; return b }, want: ErrBadEnvelope},
+		{name: "without final chunk", mutate: func(b []byte) []byte { return b[:first+2*chunk] }, want: ErrTruncated},
+		{name: "cut chunk", mutate: func(b []byte) []byte { return b[:first+chunk+10] }, want: ErrTruncated},
+		{name: "short header", mutate: func(b []byte) []byte { return b[:5] }, want: ErrTruncated},
+		{name: "extra data", mutate: func(b []byte) []byte { return append(b, 0) }, want: ErrBadEnvelope},
+		{name: "reordered chunks", mutate: func(b []byte) []byte {
+			res := append([]byte{}, b[:first]...)
+			res = append(res, b[first+chunk:first+2*chunk]...)
+			res = append(res, b[first:first+chunk]...)
+			return append(res, b[first+2*chunk:]...)
+		}, want: ErrBadEnvelope},
+		{name: "middle chunk marked final", mutate: func(b []byte) []byte {
+			l := binary.BigEndian.Uint32(b[first:])
+			binary.BigEndian.PutUint32(b[first:], l|finalFlag)
+			return b
+		}, want: ErrBadEnvelope},
+		{name: "huge chunk length", mutate: func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[first:], 1<<30)
+			return b
+		}, want: ErrBadEnvelope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.mutate(append([]byte{}, sealed...))
+			_, err := open(key, b, plain)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	t.Run("other key", func(t *testing.T) {
+		_, err := open(testKey(t), sealed, plain)
+		assert.ErrorIs(t, err, ErrBadEnvelope)
+	})
+}
+
+func Test_EnvelopeKeySize(t *testing.T) {
+	_, err := NewEnvelopeWriter(io.Discard, make([]byte, 16))
+	assert.ErrorIs(t, err, ErrBadEnvelope)
+	_, err = NewEnvelopeReader(io.NopCloser(bytes.NewReader(nil)), make([]byte, 16))
+	assert.ErrorIs(t, err, ErrBadEnvelope)
+}
+
+func Test_EnvelopeWriteAfterClose(t *testing.T) {
+	w, err := NewEnvelopeWriter(io.Discard, testKey(t))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = w.Write([]byte("x"))
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -3,12 +3,58 @@ package hmacsha256
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"hash"
 	"io"
+	"strconv"
+	"time"
 )
 
+// Signed request headers. Method, target, timestamp and nonce are signed before body,
+// so captured request is not accepted again, later than clock skew window or for other target
+const (
+	HeaderHash      = "HashSHA256"
+	HeaderTimestamp = "X-Timestamp" // unix seconds
+	HeaderNonce     = "X-Nonce"     // hex of NonceSize random bytes
+	HeaderKeyID     = "Key-Id"      // id of signature key in server keyring, empty is legacy shared key
+
+	NonceSize = 16
+)
+
+var (
+	ErrBadNonce     = errors.New("invalid nonce")
+	ErrBadTimestamp = errors.New("invalid timestamp")
+)
+
+// NewNonce - timestamp and nonce headers values of request sent at now
+func NewNonce(now time.Time) (timestamp, nonce string, err error) {
+	b := make([]byte, NonceSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(b), nil
+}
+
+// ParseNonce - checks headers values, returns request time
+func ParseNonce(timestamp, nonce string) (time.Time, error) {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrBadTimestamp
+	}
+	if b, err := hex.DecodeString(nonce); err != nil || len(b) != NonceSize {
+		return time.Time{}, ErrBadNonce
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// SignedPrefix - data signed before body, target is request URI or full gRPC method
+func SignedPrefix(method, target, timestamp, nonce string) []byte {
+	return []byte(method + " " + target + "\n" + timestamp + "\n" + nonce + "\n")
+}
+
 type HmacWriter struct {
 	w io.Writer
 	h hash.Hash
@@ -27,6 +73,11 @@ func (h *HmacWriter) Write(p []byte) (int, error) {
 	return h.w.Write(p)
 }
 
+// Seed - adds p to signature only
+func (h *HmacWriter) Seed(p []byte) {
+	h.h.Write(p)
+}
+
 func (h *HmacWriter) GetSig() []byte {
 	return h.h.Sum(nil)
 }
@@ -57,6 +108,11 @@ func (h *HmacReader) Close() error {
 	return h.r.Close()
 }
 
+// Seed - adds p to signature only, must be called before first Read
+func (h *HmacReader) Seed(p []byte) {
+	h.hr.Write(p)
+}
+
 func (h *HmacReader) GetSig() []byte {
 	return h.hr.Sum(nil)
 }
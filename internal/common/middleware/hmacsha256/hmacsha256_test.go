@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,3 +47,40 @@ func Test_NewReader(t *testing.T) {
 		assert.Nil(t, errCC)
 	})
 }
+
+func Test_Nonce(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp, nonce, err := NewNonce(now)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000", timestamp)
+
+	sent, err := ParseNonce(timestamp, nonce)
+	assert.NoError(t, err)
+	assert.Equal(t, now, sent)
+
+	_, nonce2, _ := NewNonce(now)
+	assert.NotEqual(t, nonce, nonce2)
+
+	_, err = ParseNonce("soon", nonce)
+	assert.ErrorIs(t, err, ErrBadTimestamp)
+	_, err = ParseNonce(timestamp, "abcd")
+	assert.ErrorIs(t, err, ErrBadNonce)
+	_, err = ParseNonce(timestamp, "")
+	assert.ErrorIs(t, err, ErrBadNonce)
+}
+
+func Test_Seed(t *testing.T) {
+	prefix := SignedPrefix("POST", "/update/", "1", "2")
+	w := NewWriter(io.Discard, []byte("key"))
+	w.Seed(prefix)
+	_, _ = w.Write([]byte("body"))
+
+	r := NewReader(io.NopCloser(strings.NewReader("body")), []byte("key"))
+	r.Seed(prefix)
+	_, _ = io.ReadAll(r)
+	assert.Equal(t, w.GetSig(), r.GetSig())
+
+	plain := NewWriter(io.Discard, []byte("key"))
+	_, _ = plain.Write([]byte("body"))
+	assert.NotEqual(t, plain.GetSig(), w.GetSig())
+}
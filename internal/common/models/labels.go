@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrBadLabels = errors.New("invalid labels")
+	ErrBadID     = errors.New("braces in metric id")
+)
+
+func validLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// FormatLabels - canonical form k1="v1",k2="v2" sorted by label name
+func FormatLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		if !validLabelName(k) {
+			return "", ErrBadLabels
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, k := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[k]))
+		b.WriteByte('"')
+	}
+	return b.String(), nil
+}
+
+// ParseLabels - parse k1="v1",k2="v2" form
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return nil, ErrBadLabels
+		}
+		name := strings.TrimSpace(s[:eq])
+		if !validLabelName(name) {
+			return nil, ErrBadLabels
+		}
+		var val strings.Builder
+		i := eq + 2
+		closed := false
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '"' {
+				closed = true
+				break
+			}
+			if c == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					val.WriteByte('\n')
+				default:
+					val.WriteByte(s[i])
+				}
+				continue
+			}
+			val.WriteByte(c)
+		}
+		if !closed {
+			return nil, ErrBadLabels
+		}
+		labels[name] = val.String()
+		s = strings.TrimLeft(s[i+1:], " ")
+		if s != "" {
+			if s[0] != ',' {
+				return nil, ErrBadLabels
+			}
+			s = strings.TrimLeft(s[1:], " ")
+		}
+	}
+	return labels, nil
+}
+
+// SeriesKey - metric identity in storage: id or id{k1="v1",k2="v2"}.
+// Braces are not allowed in id, so id is never read back as labels
+func SeriesKey(id string, labels map[string]string) (string, error) {
+	if strings.ContainsAny(id, "{}") {
+		return "", ErrBadID
+	}
+	if len(labels) == 0 {
+		return id, nil
+	}
+	ls, err := FormatLabels(labels)
+	if err != nil {
+		return "", err
+	}
+	return id + "{" + ls + "}", nil
+}
+
+// SplitSeriesKey - id and labels part of series key, labels without braces
+func SplitSeriesKey(key string) (id, labels string) {
+	i := strings.IndexByte(key, '{')
+	if i <= 0 || !strings.HasSuffix(key, "}") {
+		return key, ""
+	}
+	return key[:i], key[i+1 : len(key)-1]
+}
+
+// JoinSeriesKey - series key from id and labels part in canonical form
+func JoinSeriesKey(id, labels string) string {
+	if labels == "" {
+		return id
+	}
+	return id + "{" + labels + "}"
+}
+
+// ParseSeriesKey - id and labels of series key, key with broken labels is id as is
+func ParseSeriesKey(key string) (string, map[string]string) {
+	id, ls := SplitSeriesKey(key)
+	if ls == "" {
+		return key, nil
+	}
+	labels, err := ParseLabels(ls)
+	if err != nil {
+		return key, nil
+	}
+	return id, labels
+}
+
+// SeriesKey - identity of metric in storage
+func (valModels *Metrics) SeriesKey() (string, error) {
+	return SeriesKey(valModels.ID, valModels.Labels)
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SeriesKey(t *testing.T) {
+	tests := []struct {
+		labels  map[string]string
+		wantErr error
+		name    string
+		id      string
+		want    string
+	}{
+		{name: "no labels", id: "CPUutilization", want: "CPUutilization"},
+		{name: "sorted labels", id: "CPUutilization", labels: map[string]string{"host": "a", "cpu": "3"}, want: `CPUutilization{cpu="3",host="a"}`},
+		{name: "escaped value", id: "m", labels: map[string]string{"path": "a\"b\\c\nd"}, want: `m{path="a\"b\\c\nd"}`},
+		{name: "bad label name", id: "m", labels: map[string]string{"1cpu": "3"}, wantErr: ErrBadLabels},
+		{name: "brace in id", id: "m{", labels: map[string]string{"cpu": "3"}, wantErr: ErrBadID},
+		{name: "labels in id", id: `m{cpu="3"}`, wantErr: ErrBadID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SeriesKey(tt.id, tt.labels)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			id, labels := ParseSeriesKey(got)
+			assert.Equal(t, tt.id, id)
+			assert.Equal(t, tt.labels, labels)
+		})
+	}
+}
+
+func Test_ParseSeriesKey(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		name   string
+		key    string
+		id     string
+	}{
+		{name: "plain name", key: "Alloc", id: "Alloc"},
+		{name: "labels", key: `Alloc{host="a", cpu="1"}`, id: "Alloc", labels: map[string]string{"host": "a", "cpu": "1"}},
+		{name: "broken labels", key: "Alloc{host}", id: "Alloc{host}"},
+		{name: "unclosed value", key: `Alloc{host="a}`, id: `Alloc{host="a}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, labels := ParseSeriesKey(tt.key)
+			assert.Equal(t, tt.id, id)
+			assert.Equal(t, tt.labels, labels)
+		})
+	}
+}
@@ -6,35 +6,105 @@ import (
 	"io"
 	"strconv"
 
+	"github.com/4aleksei/metricscum/internal/common/utils"
+
 	pb "github.com/4aleksei/metricscum/internal/common/grpcmetrics/proto"
 	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
 )
 
 // Metrics - Import/Export to json
 type Metrics struct {
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
+	Delta     *int64                 `json:"delta,omitempty"`
+	Value     *float64               `json:"value,omitempty"`
+	Histogram *valuemetric.Histogram `json:"histogram,omitempty"`
+	Labels    map[string]string      `json:"labels,omitempty"`
+	ID        string                 `json:"id"`
+	MType     string                 `json:"type"`
+}
+
+func histFromProto(h *pb.Histogram) *valuemetric.Histogram {
+	if h == nil {
+		return nil
+	}
+	return &valuemetric.Histogram{
+		Bounds: h.GetBounds(),
+		Counts: h.GetCounts(),
+		Sum:    h.GetSum(),
+		Count:  h.GetCount(),
+	}
+}
+
+func histToProto(h *valuemetric.Histogram) *pb.Histogram {
+	if h == nil {
+		return nil
+	}
+	return &pb.Histogram{
+		Bounds: h.Bounds,
+		Counts: h.Counts,
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
 }
 
 func (valModels *Metrics) ConvertToModel(val *pb.Metric) error {
 	k, _ := valuemetric.GetKindInt(int(val.GetType()))
 	delta := val.GetCounter()
 	value := val.GetGauge()
-	resp, err := valuemetric.ConvertToValueMetricInt(k, &delta, &value)
+	resp, err := valuemetric.ConvertToValueMetricHist(k, &delta, &value, histFromProto(val.GetHistogram()))
 	if err != nil {
 		return err
 	}
 	valModels.ConvertMetricToModel(val.GetName(), *resp)
+	valModels.ID = val.GetName()
+	valModels.Labels = nil
+	if len(val.GetLabels()) > 0 {
+		valModels.Labels = val.GetLabels()
+	}
 	return nil
 }
 
-func (valModels *Metrics) ConvertMetricToModel(name string, valMetrics valuemetric.ValueMetric) {
-	valModels.ID = name
+// ConvertModelToProto - models.Metrics to gRPC message
+func (valModels *Metrics) ConvertModelToProto() *pb.Metric {
+	k, _ := valuemetric.GetKind(valModels.MType)
+	return &pb.Metric{
+		Name:      valModels.ID,
+		Counter:   utils.Setint64(valModels.Delta),
+		Gauge:     utils.Setfloat64(valModels.Value),
+		Histogram: histToProto(valModels.Histogram),
+		Labels:    valModels.Labels,
+		Type:      pb.Metric_Type(k),
+	}
+}
+
+// ConvertMetricToProto - stored value with series key to gRPC message
+func ConvertMetricToProto(key string, valMetrics valuemetric.ValueMetric) *pb.Metric {
+	name, labels := ParseSeriesKey(key)
+	return &pb.Metric{
+		Name:      name,
+		Counter:   utils.Setint64(valMetrics.ValueInt()),
+		Gauge:     utils.Setfloat64(valMetrics.ValueFloat()),
+		Histogram: histToProto(valMetrics.ValueHist()),
+		Labels:    labels,
+		Type:      pb.Metric_Type(valMetrics.GetKind()),
+	}
+}
+
+// ConvertMetricToModel - stored value with series key to model, labels are split from key
+func (valModels *Metrics) ConvertMetricToModel(key string, valMetrics valuemetric.ValueMetric) {
+	valModels.ID, valModels.Labels = ParseSeriesKey(key)
 	valModels.MType = valMetrics.GetTypeStr()
 	valModels.Delta = valMetrics.ValueInt()
 	valModels.Value = valMetrics.ValueFloat()
+	valModels.Histogram = valMetrics.ValueHist()
+}
+
+// ConvertModelToMetric - validate model and convert to stored value
+func (valModels *Metrics) ConvertModelToMetric() (*valuemetric.ValueMetric, error) {
+	kind, errKind := valuemetric.GetKind(valModels.MType)
+	if errKind != nil {
+		return nil, errKind
+	}
+	return valuemetric.ConvertToValueMetricHist(kind, valModels.Delta, valModels.Value, valModels.Histogram)
 }
 
 func (valModels *Metrics) ConvertMetricToValue() string {
@@ -42,6 +112,8 @@ func (valModels *Metrics) ConvertMetricToValue() string {
 		return strconv.FormatInt(*valModels.Delta, 10)
 	} else if valModels.Value != nil {
 		return strconv.FormatFloat(*valModels.Value, 'f', -1, 64)
+	} else if valModels.Histogram != nil {
+		return valModels.Histogram.String()
 	}
 	return ""
 }
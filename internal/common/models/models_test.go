@@ -209,3 +209,57 @@ func Test_JSONEncodeBytesSlice(t *testing.T) {
 		})
 	}
 }
+
+func Test_HistogramRoundTrip(t *testing.T) {
+	h := valuemetric.NewHistogram([]float64{0.5, 1})
+	h.Observe(0.7)
+	h.Observe(5)
+	var model Metrics
+	model.ConvertMetricToModel("latency", *valuemetric.ConvertToHistValueMetric(h))
+	assert.Equal(t, "histogram", model.MType)
+
+	t.Run("Test Histogram JSON", func(t *testing.T) {
+		var body bytes.Buffer
+		assert.NoError(t, model.JSONEncodeBytes(&body))
+		assert.JSONEq(t, `{"id":"latency","type":"histogram","histogram":{"bounds":[0.5,1],"counts":[0,1,1],"sum":5.7,"count":2}}`, body.String())
+		var got Metrics
+		assert.NoError(t, got.JSONDecode(io.NopCloser(&body)))
+		val, err := got.ConvertModelToMetric()
+		assert.NoError(t, err)
+		assert.Equal(t, h, val.ValueHist())
+	})
+
+	t.Run("Test Histogram proto", func(t *testing.T) {
+		var got Metrics
+		assert.NoError(t, got.ConvertToModel(model.ConvertModelToProto()))
+		assert.Equal(t, model, got)
+	})
+}
+
+func Test_LabelsRoundTrip(t *testing.T) {
+	var a int64 = 5
+	model := Metrics{ID: "CPUutilization", MType: "counter", Delta: &a, Labels: map[string]string{"cpu": "3"}}
+
+	t.Run("Test Labels JSON", func(t *testing.T) {
+		var body bytes.Buffer
+		assert.NoError(t, model.JSONEncodeBytes(&body))
+		assert.JSONEq(t, `{"id":"CPUutilization","type":"counter","delta":5,"labels":{"cpu":"3"}}`, body.String())
+	})
+
+	t.Run("Test Labels proto", func(t *testing.T) {
+		var got Metrics
+		assert.NoError(t, got.ConvertToModel(model.ConvertModelToProto()))
+		assert.Equal(t, model, got)
+	})
+
+	t.Run("Test Labels series key", func(t *testing.T) {
+		key, err := model.SeriesKey()
+		assert.NoError(t, err)
+		val, err := model.ConvertModelToMetric()
+		assert.NoError(t, err)
+		var got Metrics
+		got.ConvertMetricToModel(key, *val)
+		assert.Equal(t, model, got)
+		assert.Equal(t, model.ConvertModelToProto().GetLabels(), ConvertMetricToProto(key, *val).GetLabels())
+	})
+}
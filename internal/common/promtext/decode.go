@@ -0,0 +1,129 @@
+package promtext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+var (
+	ErrBadSample = errors.New("invalid sample")
+)
+
+// validName - [a-zA-Z_:][a-zA-Z0-9_:]*
+func validName(name string) bool {
+	return name != "" && SanitizeName(name) == name
+}
+
+// Decode - parse text exposition format. Samples of counter family are counters with
+// value in Delta, gauge and untyped samples are gauges. Histogram and summary families are not supported
+func Decode(r io.Reader) ([]models.Metrics, error) {
+	types := make(map[string]string)
+	var res []models.Metrics
+	sc := bufio.NewScanner(r)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			if err := decodeComment(line, types); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+		m, err := decodeSample(line, types)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		res = append(res, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// decodeComment - # TYPE name kind, other comments are skipped
+func decodeComment(line string, types map[string]string) error {
+	f := strings.Fields(line)
+	if len(f) < 2 || f[1] != "TYPE" {
+		return nil
+	}
+	if len(f) != 4 || !validName(f[2]) {
+		return fmt.Errorf("%w: %s", ErrBadSample, line)
+	}
+	kind := f[3]
+	if kind == typeUntyped {
+		kind = typeGauge
+	}
+	if _, err := valuemetric.GetKind(kind); err != nil || kind == typeHist {
+		return fmt.Errorf("%w: type %s", ErrBadSample, f[3])
+	}
+	types[f[2]] = kind
+	return nil
+}
+
+// decodeSample - name{labels} value [timestamp]
+func decodeSample(line string, types map[string]string) (models.Metrics, error) {
+	var m models.Metrics
+	rest := line
+	if i := strings.IndexByte(line, '{'); i >= 0 {
+		j := strings.LastIndexByte(line, '}')
+		if j < i {
+			return m, ErrBadSample
+		}
+		labels, err := models.ParseLabels(line[i+1 : j])
+		if err != nil {
+			return m, err
+		}
+		if len(labels) > 0 {
+			m.Labels = labels
+		}
+		m.ID = line[:i]
+		rest = line[j+1:]
+	} else {
+		sp := strings.IndexAny(line, " \t")
+		if sp < 0 {
+			return m, ErrBadSample
+		}
+		m.ID = line[:sp]
+		rest = line[sp:]
+	}
+	if !validName(m.ID) {
+		return m, fmt.Errorf("%w: name %q", ErrBadSample, m.ID)
+	}
+
+	f := strings.Fields(rest)
+	if len(f) < 1 || len(f) > 2 {
+		return m, ErrBadSample
+	}
+	v, err := strconv.ParseFloat(f[0], 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return m, fmt.Errorf("%w: value %q", ErrBadSample, f[0])
+	}
+
+	m.MType = typeGauge
+	if t, ok := types[m.ID]; ok {
+		m.MType = t
+	}
+	if m.MType == typeCounter {
+		if v < 0 || v != math.Trunc(v) || v > math.MaxInt64 {
+			return m, fmt.Errorf("%w: counter value %q", ErrBadSample, f[0])
+		}
+		d := int64(v)
+		m.Delta = &d
+		return m, nil
+	}
+	m.Value = &v
+	return m, nil
+}
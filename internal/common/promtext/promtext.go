@@ -0,0 +1,169 @@
+// Package promtext - Prometheus text exposition format
+package promtext
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+const (
+	// ContentType - content type of text exposition format version 0.0.4
+	ContentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+	typeCounter string = "counter"
+	typeGauge   string = "gauge"
+	typeHist    string = "histogram"
+	typeUntyped string = "untyped"
+)
+
+// Sample - stored value, Name is series key name{k="v",...}
+type Sample struct {
+	Name  string
+	Value valuemetric.ValueMetric
+}
+
+type series struct {
+	name   string
+	id     string
+	labels string
+	value  valuemetric.ValueMetric
+}
+
+func splitSample(s Sample) series {
+	id, labels := models.ParseSeriesKey(s.Name)
+	ls, _ := models.FormatLabels(labels) // parsed labels are always valid
+	return series{name: SanitizeName(id), id: id, labels: ls, value: s.Value}
+}
+
+// Option - optional settings of Encode
+type Option func(*encoder)
+
+type encoder struct {
+	onCollision func(id, family string)
+}
+
+// OnCollision - fn is called for every series skipped because its sanitized name
+// is family of other metric id or type
+func OnCollision(fn func(id, family string)) Option {
+	return func(e *encoder) {
+		e.onCollision = fn
+	}
+}
+
+// labelSet - {labels,extra} or empty string
+func labelSet(labels, extra string) string {
+	switch {
+	case labels == "" && extra == "":
+		return ""
+	case labels == "":
+		return "{" + extra + "}"
+	case extra == "":
+		return "{" + labels + "}"
+	default:
+		return "{" + labels + "," + extra + "}"
+	}
+}
+
+// SanitizeName - replace symbols not allowed by [a-zA-Z_:][a-zA-Z0-9_:]* with '_'
+func SanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var b strings.Builder
+	b.Grow(len(name))
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func typeOf(val valuemetric.ValueMetric) string {
+	switch val.GetTypeStr() {
+	case "counter":
+		return typeCounter
+	case "gauge":
+		return typeGauge
+	case "histogram":
+		return typeHist
+	default:
+		return typeUntyped
+	}
+}
+
+// Encode - write samples sorted by name and labels, one # TYPE line per metric family.
+// Family belongs to metric id equal to family name (cpu_x, not renamed cpu.x), otherwise to first
+// id and type in sorted order. Series of other ids or types with same sanitized name are skipped,
+// scrapers reject duplicated families
+func Encode(w io.Writer, samples []Sample, opts ...Option) error {
+	var e encoder
+	for _, o := range opts {
+		o(&e)
+	}
+	list := make([]series, 0, len(samples))
+	for _, s := range samples {
+		list = append(list, splitSample(s))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].name != list[j].name {
+			return list[i].name < list[j].name
+		}
+		if ei, ej := list[i].id == list[i].name, list[j].id == list[j].name; ei != ej {
+			return ei
+		}
+		if list[i].id != list[j].id {
+			return list[i].id < list[j].id
+		}
+		if ti, tj := typeOf(list[i].value), typeOf(list[j].value); ti != tj {
+			return ti < tj
+		}
+		return list[i].labels < list[j].labels
+	})
+
+	bw := bufio.NewWriter(w)
+	var last, lastID, lastType string
+	for _, s := range list {
+		if s.name != last {
+			last, lastID, lastType = s.name, s.id, typeOf(s.value)
+			bw.WriteString("# TYPE " + s.name + " " + lastType + "\n")
+		} else if s.id != lastID || typeOf(s.value) != lastType {
+			if e.onCollision != nil {
+				e.onCollision(s.id, s.name)
+			}
+			continue
+		}
+		if h := s.value.ValueHist(); h != nil {
+			writeHist(bw, s.name, s.labels, h)
+			continue
+		}
+		bw.WriteString(s.name + labelSet(s.labels, "") + " " + valuemetric.ConvertValueMetricToPlainOpt(s.value) + "\n")
+	}
+	return bw.Flush()
+}
+
+// writeHist - cumulative _bucket series, _sum and _count
+func writeHist(bw *bufio.Writer, name, labels string, h *valuemetric.Histogram) {
+	var cum uint64
+	for i, c := range h.Counts {
+		cum += c
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i], 'f', -1, 64)
+		}
+		bw.WriteString(name + "_bucket" + labelSet(labels, "le=\""+le+"\"") + " " + strconv.FormatUint(cum, 10) + "\n")
+	}
+	bw.WriteString(name + "_sum" + labelSet(labels, "") + " " + strconv.FormatFloat(h.Sum, 'f', -1, 64) + "\n")
+	bw.WriteString(name + "_count" + labelSet(labels, "") + " " + strconv.FormatUint(h.Count, 10) + "\n")
+}
@@ -0,0 +1,129 @@
+package promtext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		val  string
+		want string
+	}{
+		{name: "valid", val: "HeapAlloc", want: "HeapAlloc"},
+		{name: "digit first", val: "1abc", want: "_abc"},
+		{name: "bad symbols", val: "cpu.util-3", want: "cpu_util_3"},
+		{name: "empty", val: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SanitizeName(tt.val))
+		})
+	}
+}
+
+func Test_Encode(t *testing.T) {
+	samples := []Sample{
+		{Name: "b", Value: *valuemetric.ConvertToFloatValueMetric(1.25)},
+		{Name: "a", Value: *valuemetric.ConvertToIntValueMetric(7)},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, samples))
+	assert.Equal(t, "# TYPE a counter\na 7\n# TYPE b gauge\nb 1.25\n", buf.String())
+}
+
+func Test_EncodeHistogram(t *testing.T) {
+	h := valuemetric.NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+	samples := []Sample{{Name: "latency", Value: *valuemetric.ConvertToHistValueMetric(h)}}
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, samples))
+	assert.Equal(t, "# TYPE latency histogram\n"+
+		"latency_bucket{le=\"0.1\"} 1\n"+
+		"latency_bucket{le=\"1\"} 2\n"+
+		"latency_bucket{le=\"+Inf\"} 3\n"+
+		"latency_sum 3.55\n"+
+		"latency_count 3\n", buf.String())
+}
+
+func Test_EncodeLabels(t *testing.T) {
+	h := valuemetric.NewHistogram([]float64{1})
+	h.Observe(0.5)
+	samples := []Sample{
+		{Name: `cpu{cpu="1"}`, Value: *valuemetric.ConvertToFloatValueMetric(20)},
+		{Name: `cpu{cpu="0"}`, Value: *valuemetric.ConvertToFloatValueMetric(10)},
+		{Name: `latency{path="/a"}`, Value: *valuemetric.ConvertToHistValueMetric(h)},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, samples))
+	assert.Equal(t, "# TYPE cpu gauge\n"+
+		"cpu{cpu=\"0\"} 10\n"+
+		"cpu{cpu=\"1\"} 20\n"+
+		"# TYPE latency histogram\n"+
+		"latency_bucket{path=\"/a\",le=\"1\"} 1\n"+
+		"latency_bucket{path=\"/a\",le=\"+Inf\"} 1\n"+
+		"latency_sum{path=\"/a\"} 0.5\n"+
+		"latency_count{path=\"/a\"} 1\n", buf.String())
+}
+
+func Test_EncodeCollision(t *testing.T) {
+	samples := []Sample{
+		{Name: "cpu_x", Value: *valuemetric.ConvertToFloatValueMetric(2)},
+		{Name: "cpu.x", Value: *valuemetric.ConvertToFloatValueMetric(1)},
+		{Name: "req", Value: *valuemetric.ConvertToIntValueMetric(5)},
+		{Name: `req{path="/a"}`, Value: *valuemetric.ConvertToFloatValueMetric(0.5)},
+	}
+	var skipped []string
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, samples, OnCollision(func(id, family string) {
+		skipped = append(skipped, id+" "+family)
+	})))
+	assert.Equal(t, "# TYPE cpu_x gauge\ncpu_x 2\n# TYPE req counter\nreq 5\n", buf.String(),
+		"series with id equal to family name wins over renamed one")
+	assert.Equal(t, []string{"cpu.x cpu_x", "req req"}, skipped)
+}
+
+func Test_Decode(t *testing.T) {
+	in := `# HELP backup_runs_total Runs of backup
+# TYPE backup_runs_total counter
+backup_runs_total 12
+# TYPE backup_size gauge
+backup_size{db="main",host="a"} 1.5e+06
+last_run_seconds 1700000000 1700000000000
+`
+	got, err := Decode(strings.NewReader(in))
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, "counter", got[0].MType)
+	assert.Equal(t, int64(12), *got[0].Delta)
+	assert.Equal(t, "backup_size", got[1].ID)
+	assert.Equal(t, map[string]string{"db": "main", "host": "a"}, got[1].Labels)
+	assert.Equal(t, 1.5e6, *got[1].Value)
+	assert.Equal(t, "gauge", got[2].MType, "untyped is gauge")
+
+	bad := []string{
+		"1bad 1",
+		"name",
+		"name abc",
+		"name NaN",
+		"name{a=\"b\" 1",
+		"name{a-b=\"1\"} 1",
+		"# TYPE name summary",
+		"# TYPE name histogram",
+		"# TYPE c counter\nc 1.5",
+		"# TYPE c counter\nc -1",
+		"name 1 2 3",
+	}
+	for _, b := range bad {
+		_, err := Decode(strings.NewReader(b))
+		assert.Error(t, err, b)
+	}
+}
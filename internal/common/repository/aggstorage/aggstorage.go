@@ -0,0 +1,137 @@
+// Package aggstorage - agent storage keeping min, max and average of gauges between reports
+package aggstorage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+// Suffixes of aggregated gauges
+const (
+	SuffixMin = "_min"
+	SuffixMax = "_max"
+	SuffixAvg = "_avg"
+)
+
+type gaugeStat struct {
+	min   float64
+	max   float64
+	sum   float64
+	count int64
+}
+
+func (s *gaugeStat) observe(v float64) {
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.sum += v
+	s.count++
+}
+
+// MemStorageAgg - every gauge update is accounted, on read gauge is reported as last value
+// and name_min, name_max, name_avg of updates since previous read
+type MemStorageAgg struct {
+	store *memstorage.MemStorage
+	stats map[string]*gaugeStat
+	mux   *sync.Mutex
+}
+
+func (storage *MemStorageAgg) PingContext(ctx context.Context) error {
+	return nil
+}
+
+func (storage *MemStorageAgg) observe(key string, val valuemetric.ValueMetric) {
+	v := val.ValueFloat()
+	if v == nil {
+		return
+	}
+	s, ok := storage.stats[key]
+	if !ok {
+		s = new(gaugeStat)
+		storage.stats[key] = s
+	}
+	s.observe(*v)
+}
+
+func (storage *MemStorageAgg) AddMulti(ctx context.Context, modval []models.Metrics) ([]models.Metrics, error) {
+	storage.mux.Lock()
+	defer storage.mux.Unlock()
+	resmodels, err := storage.store.AddMulti(ctx, modval)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range resmodels {
+		if m.Value == nil {
+			continue
+		}
+		key, _ := m.SeriesKey()
+		storage.observe(key, *valuemetric.ConvertToFloatValueMetric(*m.Value))
+	}
+	return resmodels, nil
+}
+
+func (storage *MemStorageAgg) Add(ctx context.Context, name string, val valuemetric.ValueMetric) (valuemetric.ValueMetric, error) {
+	storage.mux.Lock()
+	defer storage.mux.Unlock()
+	res, err := storage.store.Add(ctx, name, val)
+	if err != nil {
+		return res, err
+	}
+	storage.observe(name, res)
+	return res, nil
+}
+
+func (storage *MemStorageAgg) Get(ctx context.Context, name string) (valuemetric.ValueMetric, error) {
+	storage.mux.Lock()
+	defer storage.mux.Unlock()
+	return storage.store.Get(ctx, name)
+}
+
+func (storage *MemStorageAgg) ReadAll(ctx context.Context, prog memstorage.FuncReadAllMetric) error {
+	storage.mux.Lock()
+	defer storage.mux.Unlock()
+	return storage.store.ReadAll(ctx, prog)
+}
+
+// ReadAllClearCounters - stored values and aggregates of gauges, aggregation window starts again
+func (storage *MemStorageAgg) ReadAllClearCounters(ctx context.Context, prog memstorage.FuncReadAllMetric) error {
+	storage.mux.Lock()
+	defer storage.mux.Unlock()
+	if err := storage.store.ReadAllClearCounters(ctx, prog); err != nil {
+		return err
+	}
+	for key, s := range storage.stats {
+		id, labels := models.SplitSeriesKey(key)
+		aggs := []struct {
+			suffix string
+			v      float64
+		}{
+			{SuffixMin, s.min},
+			{SuffixMax, s.max},
+			{SuffixAvg, s.sum / float64(s.count)},
+		}
+		for _, a := range aggs {
+			err := prog(models.JoinSeriesKey(id+a.suffix, labels), *valuemetric.ConvertToFloatValueMetric(a.v))
+			if err != nil {
+				return err
+			}
+		}
+		delete(storage.stats, key)
+	}
+	return nil
+}
+
+func NewStoreAgg() *MemStorageAgg {
+	p := new(MemStorageAgg)
+	p.store = memstorage.NewStore()
+	p.stats = make(map[string]*gaugeStat)
+	p.mux = new(sync.Mutex)
+	return p
+}
@@ -0,0 +1,65 @@
+package aggstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, s *MemStorageAgg) map[string]string {
+	res := make(map[string]string)
+	err := s.ReadAllClearCounters(context.Background(), func(key string, val valuemetric.ValueMetric) error {
+		res[key] = valuemetric.ConvertValueMetricToPlainOpt(val)
+		return nil
+	})
+	require.NoError(t, err)
+	return res
+}
+
+func Test_ReadAllClearCountersAgg(t *testing.T) {
+	s := NewStoreAgg()
+	ctx := context.Background()
+	for _, v := range []float64{0.5, 0.9, 0.1, 0.3} {
+		_, err := s.Add(ctx, "GCCPUFraction", *valuemetric.ConvertToFloatValueMetric(v))
+		require.NoError(t, err)
+	}
+	_, err := s.Add(ctx, "PollCount", *valuemetric.ConvertToIntValueMetric(4))
+	require.NoError(t, err)
+
+	v1, v2 := 10.0, 30.0
+	_, err = s.AddMulti(ctx, []models.Metrics{
+		{ID: "CPUutilization", MType: "gauge", Value: &v1, Labels: map[string]string{"cpu": "0"}},
+		{ID: "CPUutilization", MType: "gauge", Value: &v2, Labels: map[string]string{"cpu": "0"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"GCCPUFraction":               "0.3",
+		"GCCPUFraction_min":           "0.1",
+		"GCCPUFraction_max":           "0.9",
+		"GCCPUFraction_avg":           "0.45",
+		"PollCount":                   "4",
+		`CPUutilization{cpu="0"}`:     "30",
+		`CPUutilization_min{cpu="0"}`: "10",
+		`CPUutilization_max{cpu="0"}`: "30",
+		`CPUutilization_avg{cpu="0"}`: "20",
+	}, readAll(t, s))
+
+	// window without updates reports last values only
+	assert.Equal(t, map[string]string{
+		"GCCPUFraction":           "0.3",
+		"PollCount":               "0",
+		`CPUutilization{cpu="0"}`: "30",
+	}, readAll(t, s))
+
+	_, err = s.Add(ctx, "GCCPUFraction", *valuemetric.ConvertToFloatValueMetric(0.7))
+	require.NoError(t, err)
+	got := readAll(t, s)
+	assert.Equal(t, "0.7", got["GCCPUFraction_min"])
+	assert.Equal(t, "0.7", got["GCCPUFraction_max"])
+	assert.Equal(t, "0.7", got["GCCPUFraction_avg"])
+}
@@ -3,6 +3,7 @@ package dbstorage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -19,8 +20,9 @@ import (
 )
 
 type DBStorage struct {
-	db store.Store
-	l  *zap.Logger
+	db    store.Store
+	l     *zap.Logger
+	retry utils.RetryPolicy
 }
 
 var (
@@ -31,13 +33,14 @@ var (
 
 func NewStoreDB(db *pg.DB, l *zap.Logger) *DBStorage {
 	return &DBStorage{db: db,
-		l: l}
+		l:     l,
+		retry: db.Retry()}
 }
 
 const defaultTimeoutPing int = 500
 
 func (storage *DBStorage) PingContext(ctx context.Context) error {
-	err := utils.RetryAction(ctx, utils.RetryTimes(), func(ctx context.Context) error {
+	err := storage.retry.Do(ctx, func(ctx context.Context) error {
 		ctxP, cancel := context.WithTimeout(ctx, time.Duration(defaultTimeoutPing)*time.Millisecond)
 		defer cancel()
 		return storage.db.Ping(ctxP)
@@ -45,6 +48,28 @@ func (storage *DBStorage) PingContext(ctx context.Context) error {
 	return err
 }
 
+func encodeHist(h *valuemetric.Histogram) ([]byte, error) {
+	if h == nil {
+		return nil, nil
+	}
+	return json.Marshal(h)
+}
+
+func convertStoreToValue(m *store.Metrics) (*valuemetric.ValueMetric, error) {
+	kind, errK := valuemetric.GetKindInt(m.Kind)
+	if errK != nil {
+		return nil, errK
+	}
+	var hist *valuemetric.Histogram
+	if m.Hist != nil {
+		hist = new(valuemetric.Histogram)
+		if err := json.Unmarshal(m.Hist, hist); err != nil {
+			return nil, err
+		}
+	}
+	return valuemetric.ConvertToValueMetricHist(kind, &m.Delta.Int64, &m.Value.Float64, hist)
+}
+
 const limitbatch int = 5
 
 func (storage *DBStorage) AddMulti(ctx context.Context, modval []models.Metrics) ([]models.Metrics, error) {
@@ -59,27 +84,34 @@ func (storage *DBStorage) AddMulti(ctx context.Context, modval []models.Metrics)
 		if valModel.ID == "" {
 			return nil, fmt.Errorf("failed %w", ErrBadName)
 		}
+		labels, errL := models.FormatLabels(valModel.Labels)
+		if errL != nil {
+			return nil, fmt.Errorf("failed %w", errL)
+		}
+		hist, errH := encodeHist(valModel.Histogram)
+		if errH != nil {
+			return nil, fmt.Errorf("failed %w", errH)
+		}
 
 		sm[i].Kind = int(kind)
 		sm[i].Name = valModel.ID
+		sm[i].Labels = labels
 		sm[i].Delta = sql.NullInt64{Valid: valModel.Delta != nil, Int64: utils.Setint64(valModel.Delta)}
 		sm[i].Value = sql.NullFloat64{Valid: valModel.Value != nil, Float64: utils.Setfloat64(valModel.Value)}
+		sm[i].Hist = hist
 
 		i++
 	}
 
-	var valret *valuemetric.ValueMetric
-
-	err := storage.db.Upserts(ctx, sm, limitbatch, func(n string, k int, d int64, v float64) error {
-		kind, errK := valuemetric.GetKindInt(k)
+	err := storage.db.Upserts(ctx, sm, limitbatch, func(m *store.Metrics) error {
+		valret, errK := convertStoreToValue(m)
 		if errK != nil {
 			return errK
 		}
-		valret, errK = valuemetric.ConvertToValueMetricInt(kind, &d, &v)
 		var valNewModel models.Metrics
-		valNewModel.ConvertMetricToModel(n, *valret)
+		valNewModel.ConvertMetricToModel(models.JoinSeriesKey(m.Name, m.Labels), *valret)
 		resmodels = append(resmodels, valNewModel)
-		return errK
+		return nil
 	})
 
 	if err != nil {
@@ -93,17 +125,19 @@ func (storage *DBStorage) AddMulti(ctx context.Context, modval []models.Metrics)
 func (storage *DBStorage) Add(ctx context.Context, name string, val valuemetric.ValueMetric) (valuemetric.ValueMetric, error) {
 	var modval store.Metrics
 	modval.Kind = val.GetKind()
-	modval.Name = name
+	modval.Name, modval.Labels = models.SplitSeriesKey(name)
 	modval.Delta = sql.NullInt64{Valid: val.ValueInt() != nil, Int64: utils.Setint64(val.ValueInt())}
 	modval.Value = sql.NullFloat64{Valid: val.ValueFloat() != nil, Float64: utils.Setfloat64(val.ValueFloat())}
+	hist, errH := encodeHist(val.ValueHist())
+	if errH != nil {
+		return valuemetric.ValueMetric{}, errH
+	}
+	modval.Hist = hist
 
 	var valret *valuemetric.ValueMetric
-	err := storage.db.Upsert(ctx, modval, func(n string, k int, d int64, v float64) error {
-		kind, errK := valuemetric.GetKindInt(k)
-		if errK != nil {
-			return errK
-		}
-		valret, errK = valuemetric.ConvertToValueMetricInt(kind, &d, &v)
+	err := storage.db.Upsert(ctx, modval, func(m *store.Metrics) error {
+		var errK error
+		valret, errK = convertStoreToValue(m)
 		return errK
 	})
 	if err != nil {
@@ -117,12 +151,10 @@ func (storage *DBStorage) Add(ctx context.Context, name string, val valuemetric.
 func (storage *DBStorage) Get(ctx context.Context, name string) (valuemetric.ValueMetric, error) {
 	var valret *valuemetric.ValueMetric
 
-	err := storage.db.SelectValue(ctx, name, func(n string, k int, d int64, v float64) error {
-		kind, errK := valuemetric.GetKindInt(k)
-		if errK != nil {
-			return errK
-		}
-		valret, errK = valuemetric.ConvertToValueMetricInt(kind, &d, &v)
+	id, labels := models.SplitSeriesKey(name)
+	err := storage.db.SelectValue(ctx, id, labels, func(m *store.Metrics) error {
+		var errK error
+		valret, errK = convertStoreToValue(m)
 		return errK
 	})
 
@@ -133,17 +165,13 @@ func (storage *DBStorage) Get(ctx context.Context, name string) (valuemetric.Val
 }
 
 func (storage *DBStorage) ReadAll(ctx context.Context, prog memstorage.FuncReadAllMetric) error {
-	errR := utils.RetryAction(ctx, utils.RetryTimes(), func(ctx context.Context) error {
-		err := storage.db.SelectValueAll(ctx, func(n string, k int, d int64, v float64) error {
-			kind, errK := valuemetric.GetKindInt(k)
-			if errK != nil {
-				return errK
-			}
-			val, err := valuemetric.ConvertToValueMetricInt(kind, &d, &v)
+	errR := storage.retry.Do(ctx, func(ctx context.Context) error {
+		err := storage.db.SelectValueAll(ctx, func(m *store.Metrics) error {
+			val, err := convertStoreToValue(m)
 			if err != nil {
 				return err
 			}
-			return prog(n, *val)
+			return prog(models.JoinSeriesKey(m.Name, m.Labels), *val)
 		})
 		return err
 	}, pg.ProbePG)
@@ -7,6 +7,7 @@ import (
 	"github.com/4aleksei/metricscum/internal/common/logger"
 	"github.com/4aleksei/metricscum/internal/common/models"
 	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/4aleksei/metricscum/internal/common/store"
 	"github.com/4aleksei/metricscum/internal/common/store/mock"
 	"github.com/golang/mock/gomock"
 )
@@ -123,7 +124,7 @@ func Test_Get(t *testing.T) {
 	v := valuemetric.ConvertToFloatValueMetric(55.55)
 
 	stor.EXPECT().
-		SelectValue(gomock.Any(), gomock.Any(), gomock.Any()).
+		SelectValue(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(valuemetric.ErrBadTypeValue)
 
 	l, _ := logger.NewLog("debug")
@@ -220,3 +221,35 @@ func Test_ReadAllClearCounters(t *testing.T) {
 		})
 	}
 }
+
+func Test_AddHistogram(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stor := mock.NewMockStore(ctrl)
+
+	h := valuemetric.NewHistogram([]float64{1})
+	h.Observe(0.5)
+	v := valuemetric.ConvertToHistValueMetric(h)
+
+	stor.EXPECT().
+		Upsert(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, m store.Metrics, prog store.FuncScanMetric) error {
+			return prog(&m)
+		})
+
+	l, _ := logger.NewLog("debug")
+	db := &DBStorage{db: stor,
+		l: l}
+
+	t.Run("Test Add histogram", func(t *testing.T) {
+		got, gotErr := db.Add(context.Background(), "Hist", *v)
+		if gotErr != nil {
+			t.Errorf("Error Add Ret Error = %v", gotErr)
+			return
+		}
+		if got.GetTypeStr() != "histogram" || got.ValueHist().Count != 1 {
+			t.Errorf("Error Add = %v  , want = %v", got, *v)
+		}
+	})
+}
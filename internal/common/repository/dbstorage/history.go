@@ -0,0 +1,79 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/history"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/4aleksei/metricscum/internal/common/store"
+	"github.com/4aleksei/metricscum/internal/common/utils"
+	"go.uber.org/zap"
+)
+
+// DBHistory - history of gauges and counters in metrics_history table,
+// at most size last points per series are kept
+type DBHistory struct {
+	db   store.HistoryStore
+	l    *zap.Logger
+	size int
+}
+
+func NewHistoryDB(db store.HistoryStore, l *zap.Logger, size int) *DBHistory {
+	return &DBHistory{db: db,
+		l:    l,
+		size: size}
+}
+
+// Append - store values after update, histograms are skipped
+func (storage *DBHistory) Append(ctx context.Context, ts time.Time, vals []models.Metrics) error {
+	hm := make([]store.HistoryMetrics, 0, len(vals))
+	for i := range vals {
+		kind, errKind := valuemetric.GetKind(vals[i].MType)
+		if errKind != nil {
+			return errKind
+		}
+		if vals[i].Delta == nil && vals[i].Value == nil {
+			continue
+		}
+		labels, errL := models.FormatLabels(vals[i].Labels)
+		if errL != nil {
+			return errL
+		}
+		hm = append(hm, store.HistoryMetrics{
+			Name:   vals[i].ID,
+			Labels: labels,
+			Kind:   int(kind),
+			Delta:  sql.NullInt64{Valid: vals[i].Delta != nil, Int64: utils.Setint64(vals[i].Delta)},
+			Value:  sql.NullFloat64{Valid: vals[i].Value != nil, Float64: utils.Setfloat64(vals[i].Value)},
+			TS:     ts,
+		})
+	}
+	err := storage.db.InsertHistory(ctx, hm)
+	if err != nil {
+		storage.l.Error("failed to insert history", zap.Error(err))
+		return err
+	}
+	err = storage.db.TrimHistory(ctx, hm, storage.size)
+	if err != nil {
+		storage.l.Error("failed to trim history", zap.Error(err))
+	}
+	return err
+}
+
+// Range - points of series with from <= time <= to
+func (storage *DBHistory) Range(ctx context.Context, key string, kind int, from, to time.Time, prog history.FuncReadPoint) error {
+	name, labels := models.SplitSeriesKey(key)
+	return storage.db.SelectHistory(ctx, name, labels, kind, from, to, func(m *store.HistoryMetrics) error {
+		p := history.Point{Time: m.TS}
+		if m.Delta.Valid {
+			p.Delta = &m.Delta.Int64
+		}
+		if m.Value.Valid {
+			p.Value = &m.Value.Float64
+		}
+		return prog(p)
+	})
+}
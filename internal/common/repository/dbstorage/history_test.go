@@ -0,0 +1,80 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/history"
+	"github.com/4aleksei/metricscum/internal/common/store"
+	"github.com/4aleksei/metricscum/internal/common/store/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HistoryAppend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stor := mock.NewMockHistoryStore(ctrl)
+	ts := time.Unix(100, 0)
+	var d int64 = 5
+	want := []store.HistoryMetrics{{Name: "PollCount", Labels: `host="a"`, Kind: 1,
+		Delta: sql.NullInt64{Valid: true, Int64: 5}, TS: ts}}
+	gomock.InOrder(
+		stor.EXPECT().InsertHistory(gomock.Any(), want).Return(nil),
+		stor.EXPECT().TrimHistory(gomock.Any(), want, 10).Return(nil),
+	)
+
+	l, _ := logger.NewLog("debug")
+	h := NewHistoryDB(stor, l, 10)
+	err := h.Append(context.Background(), ts, []models.Metrics{
+		{ID: "PollCount", MType: "counter", Delta: &d, Labels: map[string]string{"host": "a"}}})
+	assert.NoError(t, err)
+}
+
+func Test_HistoryAppendInsertFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stor := mock.NewMockHistoryStore(ctrl)
+	stor.EXPECT().InsertHistory(gomock.Any(), gomock.Any()).Return(errors.New("db down"))
+
+	l, _ := logger.NewLog("debug")
+	h := NewHistoryDB(stor, l, 10)
+	var v = 1.5
+	err := h.Append(context.Background(), time.Unix(100, 0), []models.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: &v}})
+	assert.Error(t, err, "no trim after failed insert")
+}
+
+func Test_HistoryRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stor := mock.NewMockHistoryStore(ctrl)
+	ts := time.Unix(100, 0)
+	stor.EXPECT().
+		SelectHistory(gomock.Any(), "Alloc", `host="a"`, 2, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, _ int, _, _ time.Time, prog store.FuncScanHistory) error {
+			return prog(&store.HistoryMetrics{Name: "Alloc", Kind: 2, Value: sql.NullFloat64{Valid: true, Float64: 1.5}, TS: ts})
+		})
+
+	l, _ := logger.NewLog("debug")
+	h := NewHistoryDB(stor, l, 10)
+	var got []history.Point
+	err := h.Range(context.Background(), `Alloc{host="a"}`, 2, ts, ts, func(p history.Point) error {
+		got = append(got, p)
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, ts, got[0].Time)
+		assert.Nil(t, got[0].Delta)
+		assert.Equal(t, 1.5, *got[0].Value)
+	}
+}
@@ -0,0 +1,53 @@
+// Package history - append-only history of gauges and counters
+package history
+
+import (
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+// Point - value of series after update
+type Point struct {
+	Time  time.Time `json:"time"`
+	Delta *int64    `json:"delta,omitempty"`
+	Value *float64  `json:"value,omitempty"`
+}
+
+// FuncReadPoint - callback for every point of range in time order
+type FuncReadPoint func(p Point) error
+
+// NewPoint - point of gauge or counter, ok is false for other kinds
+func NewPoint(ts time.Time, val valuemetric.ValueMetric) (Point, bool) {
+	p := Point{Time: ts}
+	if v := val.ValueInt(); v != nil {
+		d := *v
+		p.Delta = &d
+		return p, true
+	}
+	if v := val.ValueFloat(); v != nil {
+		f := *v
+		p.Value = &f
+		return p, true
+	}
+	return p, false
+}
+
+// Downsample - last value of every step interval starting at from, point time is interval start.
+// Points must be sorted by time, step <= 0 returns points as is
+func Downsample(points []Point, from time.Time, step time.Duration) []Point {
+	if step <= 0 || len(points) == 0 {
+		return points
+	}
+	res := make([]Point, 0, len(points))
+	for _, p := range points {
+		bucket := from.Add(p.Time.Sub(from) / step * step)
+		p.Time = bucket
+		if n := len(res); n > 0 && res[n-1].Time.Equal(bucket) {
+			res[n-1] = p
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
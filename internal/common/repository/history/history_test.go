@@ -0,0 +1,74 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gaugeModel(id string, v float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &v}
+}
+
+func Test_RingRange(t *testing.T) {
+	r := NewRing(3)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, r.Append(context.Background(), start.Add(time.Duration(i)*time.Second),
+			[]models.Metrics{gaugeModel("Alloc", float64(i))}))
+	}
+
+	tests := []struct {
+		from time.Time
+		to   time.Time
+		name string
+		kind int
+		want []float64
+	}{
+		{name: "last points after wrap", from: start, to: start.Add(time.Hour), kind: 2, want: []float64{2, 3, 4}},
+		{name: "time range", from: start.Add(3 * time.Second), to: start.Add(3 * time.Second), kind: 2, want: []float64{3}},
+		{name: "other kind", from: start, to: start.Add(time.Hour), kind: 1, want: []float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]float64, 0)
+			err := r.Range(context.Background(), "Alloc", tt.kind, tt.from, tt.to, func(p Point) error {
+				got = append(got, *p.Value)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_RingSkipHistogram(t *testing.T) {
+	r := NewRing(3)
+	m := models.Metrics{ID: "h", MType: "histogram", Histogram: valuemetric.NewHistogram([]float64{1})}
+	assert.NoError(t, r.Append(context.Background(), time.Now(), []models.Metrics{m}))
+	assert.Empty(t, r.series)
+}
+
+func Test_Downsample(t *testing.T) {
+	from := time.Unix(0, 0)
+	v := func(f float64) *float64 { return &f }
+	points := []Point{
+		{Time: from.Add(1 * time.Second), Value: v(1)},
+		{Time: from.Add(5 * time.Second), Value: v(2)},
+		{Time: from.Add(12 * time.Second), Value: v(3)},
+		{Time: from.Add(31 * time.Second), Value: v(4)},
+	}
+	got := Downsample(points, from, 10*time.Second)
+	want := []Point{
+		{Time: from, Value: v(2)},
+		{Time: from.Add(10 * time.Second), Value: v(3)},
+		{Time: from.Add(30 * time.Second), Value: v(4)},
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, points, Downsample(points, from, 0))
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+)
+
+// DefaultRingSize - points kept per series by in-memory history
+const DefaultRingSize int = 1000
+
+type ring struct {
+	points []Point
+	next   int
+	full   bool
+}
+
+func (r *ring) add(p Point) {
+	r.points[r.next] = p
+	r.next++
+	if r.next == len(r.points) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// each - points from oldest to newest
+func (r *ring) each(prog func(p Point) error) error {
+	if r.full {
+		for _, p := range r.points[r.next:] {
+			if err := prog(p); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range r.points[:r.next] {
+		if err := prog(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RingStorage - in-memory history, last size points of every series
+type RingStorage struct {
+	series map[string]*ring
+	mux    *sync.RWMutex
+	size   int
+}
+
+func NewRing(size int) *RingStorage {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &RingStorage{
+		series: make(map[string]*ring),
+		mux:    new(sync.RWMutex),
+		size:   size,
+	}
+}
+
+func ringKey(key string, kind int) string {
+	return strconv.Itoa(kind) + "/" + key
+}
+
+// Append - store values after update, histograms are skipped
+func (storage *RingStorage) Append(ctx context.Context, ts time.Time, vals []models.Metrics) error {
+	storage.mux.Lock()
+	defer storage.mux.Unlock()
+	for i := range vals {
+		key, err := vals[i].SeriesKey()
+		if err != nil {
+			return err
+		}
+		val, err := vals[i].ConvertModelToMetric()
+		if err != nil {
+			return err
+		}
+		p, ok := NewPoint(ts, *val)
+		if !ok {
+			continue
+		}
+		rk := ringKey(key, val.GetKind())
+		r, ok := storage.series[rk]
+		if !ok {
+			r = &ring{points: make([]Point, storage.size)}
+			storage.series[rk] = r
+		}
+		r.add(p)
+	}
+	return nil
+}
+
+// Range - points of series with from <= time <= to
+func (storage *RingStorage) Range(ctx context.Context, key string, kind int, from, to time.Time, prog FuncReadPoint) error {
+	if _, err := valuemetric.GetKindInt(kind); err != nil {
+		return err
+	}
+	storage.mux.RLock()
+	defer storage.mux.RUnlock()
+	r, ok := storage.series[ringKey(key, kind)]
+	if !ok {
+		return nil
+	}
+	return r.each(func(p Point) error {
+		if p.Time.Before(from) || p.Time.After(to) {
+			return nil
+		}
+		return prog(p)
+	})
+}
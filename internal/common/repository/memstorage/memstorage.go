@@ -40,16 +40,20 @@ func (storage *MemStorage) AddMulti(ctx context.Context, modval []models.Metrics
 		if valModel.ID == "" {
 			return nil, fmt.Errorf("failed %w", ErrBadName)
 		}
-		val, err := valuemetric.ConvertToValueMetricInt(kind, valModel.Delta, valModel.Value)
+		key, errKey := valModel.SeriesKey()
+		if errKey != nil {
+			return nil, fmt.Errorf("failed %w", errKey)
+		}
+		val, err := valuemetric.ConvertToValueMetricHist(kind, valModel.Delta, valModel.Value, valModel.Histogram)
 		if err != nil {
 			return nil, fmt.Errorf("failed %w", err)
 		}
-		resval, errA := storage.Add(ctx, valModel.ID, *val)
+		resval, errA := storage.Add(ctx, key, *val)
 		if errA != nil {
 			return nil, errA
 		}
 		var valNewModel models.Metrics
-		valNewModel.ConvertMetricToModel(valModel.ID, resval)
+		valNewModel.ConvertMetricToModel(key, resval)
 		resmodels = append(resmodels, valNewModel)
 	}
 	return resmodels, nil
@@ -209,3 +209,27 @@ func Test_ReadAll(t *testing.T) {
 		})
 	}
 }
+
+func Test_AddMultiLabels(t *testing.T) {
+	n := NewStore()
+	var a int64 = 1
+	modval := []models.Metrics{
+		{ID: "CPUutilization", MType: "counter", Delta: &a, Labels: map[string]string{"cpu": "0"}},
+		{ID: "CPUutilization", MType: "counter", Delta: &a, Labels: map[string]string{"cpu": "1"}},
+		{ID: "CPUutilization", MType: "counter", Delta: &a, Labels: map[string]string{"cpu": "1"}},
+	}
+	_, err := n.AddMulti(context.Background(), modval)
+	assert.NoError(t, err)
+
+	v0, err := n.Get(context.Background(), `CPUutilization{cpu="0"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *v0.ValueInt())
+	v1, err := n.Get(context.Background(), `CPUutilization{cpu="1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), *v1.ValueInt())
+	_, err = n.Get(context.Background(), "CPUutilization")
+	assert.ErrorIs(t, err, ErrNotFoundName)
+
+	_, err = n.AddMulti(context.Background(), []models.Metrics{{ID: "m", MType: "counter", Delta: &a, Labels: map[string]string{"bad-name": "1"}}})
+	assert.ErrorIs(t, err, models.ErrBadLabels)
+}
@@ -131,6 +131,7 @@ func (storage *MemStorageMuxLongTerm) LoadData(ctx context.Context) error {
 
 	valNewModel := new(models.Metrics)
 	for {
+		*valNewModel = models.Metrics{}
 		if errson := storage.filestorage.ReadData(valNewModel); errson != nil {
 			return errson
 		}
@@ -141,11 +142,15 @@ func (storage *MemStorageMuxLongTerm) LoadData(ctx context.Context) error {
 		if valNewModel.ID == "" {
 			return errors.New("no name")
 		}
-		val, err := valuemetric.ConvertToValueMetricInt(kind, valNewModel.Delta, valNewModel.Value)
+		key, errKey := valNewModel.SeriesKey()
+		if errKey != nil {
+			return errKey
+		}
+		val, err := valuemetric.ConvertToValueMetricHist(kind, valNewModel.Delta, valNewModel.Value, valNewModel.Histogram)
 		if err != nil {
 			return err
 		}
-		_, _ = storage.store.Add(ctx, valNewModel.ID, *val)
+		_, _ = storage.store.Add(ctx, key, *val)
 	}
 }
 
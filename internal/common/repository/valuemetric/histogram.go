@@ -0,0 +1,119 @@
+package valuemetric
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Histogram - bucketed distribution of observations.
+// Counts[i] - observations in (Bounds[i-1], Bounds[i]], last element of Counts - observations above last bound (+Inf)
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+var (
+	ErrBadHistogram = errors.New("invalid histogram")
+)
+
+// NewHistogram - empty histogram with bucket upper bounds
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe - add one observation
+func (h *Histogram) Observe(v float64) {
+	h.ObserveN(v, 1)
+}
+
+// ObserveN - add n observations of same value
+func (h *Histogram) ObserveN(v float64, n uint64) {
+	i, _ := slices.BinarySearch(h.Bounds, v)
+	h.Counts[i] += n
+	h.Sum += v * float64(n)
+	h.Count += n
+}
+
+// Validate - bounds are finite and strictly increasing, counts agree with bounds and total count
+func (h *Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return ErrBadHistogram
+	}
+	for i, b := range h.Bounds {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return ErrBadHistogram
+		}
+		if i > 0 && b <= h.Bounds[i-1] {
+			return ErrBadHistogram
+		}
+	}
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total != h.Count || math.IsNaN(h.Sum) || math.IsInf(h.Sum, 0) {
+		return ErrBadHistogram
+	}
+	return nil
+}
+
+// SameBounds - histograms have equal bucket layout
+func (h *Histogram) SameBounds(o *Histogram) bool {
+	return slices.Equal(h.Bounds, o.Bounds)
+}
+
+// Clone - deep copy
+func (h *Histogram) Clone() *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
+}
+
+// merge - new histogram with summed buckets, layouts must be equal
+func (h *Histogram) merge(o *Histogram) *Histogram {
+	res := h.Clone()
+	for i := range res.Counts {
+		res.Counts[i] += o.Counts[i]
+	}
+	res.Sum += o.Sum
+	res.Count += o.Count
+	return res
+}
+
+func (h *Histogram) reset() *Histogram {
+	return NewHistogram(h.Bounds)
+}
+
+// String - plain form: count=N sum=S buckets=b1:c1,b2:c2,+Inf:cN
+func (h *Histogram) String() string {
+	var b strings.Builder
+	b.WriteString("count=")
+	b.WriteString(strconv.FormatUint(h.Count, 10))
+	b.WriteString(" sum=")
+	b.WriteString(strconv.FormatFloat(h.Sum, 'f', -1, 64))
+	b.WriteString(" buckets=")
+	for i, c := range h.Counts {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if i < len(h.Bounds) {
+			b.WriteString(strconv.FormatFloat(h.Bounds[i], 'f', -1, 64))
+		} else {
+			b.WriteString("+Inf")
+		}
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatUint(c, 10))
+	}
+	return b.String()
+}
@@ -13,6 +13,7 @@ const (
 	kindBadEmpty valueKind = iota
 	kindInt64
 	kindFloat64
+	kindHistogram
 
 	defaultNAN = "nan"
 )
@@ -21,6 +22,7 @@ type ValueMetric struct {
 	kind       valueKind
 	valueInt   int64
 	valueFloat float64
+	valueHist  *Histogram
 }
 
 func (v *ValueMetric) GetTypeStr() string {
@@ -33,6 +35,8 @@ func (v *ValueMetric) GetKind() int {
 		return int(kindFloat64)
 	case kindInt64:
 		return int(kindInt64)
+	case kindHistogram:
+		return int(kindHistogram)
 	default:
 		return int(kindBadEmpty)
 	}
@@ -52,6 +56,14 @@ func (v *ValueMetric) ValueFloat() *float64 {
 	return nil
 }
 
+// ValueHist - histogram data, must not be modified by caller
+func (v *ValueMetric) ValueHist() *Histogram {
+	if v.kind == kindHistogram {
+		return v.valueHist
+	}
+	return nil
+}
+
 var (
 	ErrBadTypeValue = errors.New("invalid typeValue")
 	ErrBadValue     = errors.New("error value conversion")
@@ -64,6 +76,8 @@ func GetKindInt(k int) (valueKind, error) {
 		return kindFloat64, nil
 	case int(kindInt64):
 		return kindInt64, nil
+	case int(kindHistogram):
+		return kindHistogram, nil
 	default:
 		return kindBadEmpty, ErrBadTypeValue
 	}
@@ -75,6 +89,8 @@ func GetKind(typeValue string) (valueKind, error) {
 		return kindFloat64, nil
 	case "counter":
 		return kindInt64, nil
+	case "histogram":
+		return kindHistogram, nil
 	default:
 		return kindBadEmpty, ErrBadTypeValue
 	}
@@ -86,6 +102,8 @@ func GetKindStr(typeValue valueKind) string {
 		return "gauge"
 	case kindInt64:
 		return "counter"
+	case kindHistogram:
+		return "histogram"
 	default:
 		return ""
 	}
@@ -97,6 +115,14 @@ func (v *ValueMetric) DoUpdate(val ValueMetric) {
 		v.valueFloat = val.valueFloat
 	case kindInt64:
 		v.valueInt += val.valueInt
+	case kindHistogram:
+		// histogram is shared between copies of ValueMetric, so always replace it.
+		// Changed bucket layout starts the series again
+		if v.valueHist != nil && val.valueHist != nil && v.valueHist.SameBounds(val.valueHist) {
+			v.valueHist = v.valueHist.merge(val.valueHist)
+		} else if val.valueHist != nil {
+			v.valueHist = val.valueHist.Clone()
+		}
 	default:
 	}
 }
@@ -105,6 +131,10 @@ func (v *ValueMetric) DoRead() ValueMetric {
 	switch v.kind {
 	case kindInt64:
 		v.valueInt = 0
+	case kindHistogram:
+		if v.valueHist != nil {
+			v.valueHist = v.valueHist.reset()
+		}
 	default:
 	}
 	return *v
@@ -128,7 +158,18 @@ func ConvertToIntValueMetric(valI int64) *ValueMetric {
 	return val
 }
 
+func ConvertToHistValueMetric(h *Histogram) *ValueMetric {
+	val := new(ValueMetric)
+	val.kind = kindHistogram
+	val.valueHist = h.Clone()
+	return val
+}
+
 func ConvertToValueMetricInt(kind valueKind, delta *int64, value *float64) (*ValueMetric, error) {
+	return ConvertToValueMetricHist(kind, delta, value, nil)
+}
+
+func ConvertToValueMetricHist(kind valueKind, delta *int64, value *float64, hist *Histogram) (*ValueMetric, error) {
 	val := new(ValueMetric)
 	val.kind = kind
 	var err error
@@ -145,6 +186,15 @@ func ConvertToValueMetricInt(kind valueKind, delta *int64, value *float64) (*Val
 		}
 		val.valueInt = *delta
 
+	case kindHistogram:
+		if hist == nil {
+			return nil, fmt.Errorf("%w: missing histogram", ErrBadValue)
+		}
+		if err = hist.Validate(); err != nil {
+			return nil, fmt.Errorf("failed %w : %w", ErrBadValue, err)
+		}
+		val.valueHist = hist.Clone()
+
 	default:
 		return nil, fmt.Errorf("failed %w : %w", ErrBadValue, ErrBadKindType)
 	}
@@ -182,6 +232,9 @@ func ConvertValueMetricToPlain(val ValueMetric) (a, b string) {
 	case kindInt64:
 		a = GetKindStr(val.kind)
 		b = strconv.FormatInt(val.valueInt, 10)
+	case kindHistogram:
+		a = GetKindStr(val.kind)
+		b = histPlain(val.valueHist)
 	default:
 		a = defaultNAN
 		b = defaultNAN
@@ -197,8 +250,17 @@ func ConvertValueMetricToPlainOpt(val ValueMetric) (b string) {
 	case kindInt64:
 
 		b = strconv.FormatInt(val.valueInt, 10)
+	case kindHistogram:
+		b = histPlain(val.valueHist)
 	default:
 		b = defaultNAN
 	}
 	return b
 }
+
+func histPlain(h *Histogram) string {
+	if h == nil {
+		return defaultNAN
+	}
+	return h.String()
+}
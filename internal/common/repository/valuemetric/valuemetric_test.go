@@ -57,10 +57,10 @@ func Test_ConvertToValueMetricInt(t *testing.T) {
 		wantVal ValueMetric
 		kind    valueKind
 	}{
-		{name: "Test kindInt64", kind: kindInt64, delta: &testInt, value: nil, wantVal: ValueMetric{kindInt64, 44, 0}, wantErr: nil},
-		{name: "Test kindFloat64", kind: kindFloat64, delta: nil, value: &testFloat, wantVal: ValueMetric{kindFloat64, 0, 66.33}, wantErr: nil},
-		{name: "Test Bad kindInt64", kind: kindInt64, delta: nil, value: nil, wantVal: ValueMetric{kindInt64, 44, 0}, wantErr: ErrBadValue},
-		{name: "Test Bad kindFloat64", kind: kindFloat64, delta: nil, value: nil, wantVal: ValueMetric{kindFloat64, 44, 0}, wantErr: ErrBadValue},
+		{name: "Test kindInt64", kind: kindInt64, delta: &testInt, value: nil, wantVal: ValueMetric{kindInt64, 44, 0, nil}, wantErr: nil},
+		{name: "Test kindFloat64", kind: kindFloat64, delta: nil, value: &testFloat, wantVal: ValueMetric{kindFloat64, 0, 66.33, nil}, wantErr: nil},
+		{name: "Test Bad kindInt64", kind: kindInt64, delta: nil, value: nil, wantVal: ValueMetric{kindInt64, 44, 0, nil}, wantErr: ErrBadValue},
+		{name: "Test Bad kindFloat64", kind: kindFloat64, delta: nil, value: nil, wantVal: ValueMetric{kindFloat64, 44, 0, nil}, wantErr: ErrBadValue},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -86,10 +86,10 @@ func Test_ConvertToValueMetric(t *testing.T) {
 		wantVal ValueMetric
 		kind    valueKind
 	}{
-		{name: "Test kindInt64", kind: kindInt64, value: "44", wantVal: ValueMetric{kindInt64, 44, 0}, wantErr: nil},
-		{name: "Test kindFloat64", kind: kindFloat64, value: "66.33", wantVal: ValueMetric{kindFloat64, 0, 66.33}, wantErr: nil},
-		{name: "Test Bad kindInt64", kind: kindInt64, value: "TestErre44", wantVal: ValueMetric{kindInt64, 44, 0}, wantErr: ErrBadValue},
-		{name: "Test Bad kindFloat64", kind: kindFloat64, value: "TestErre44.33", wantVal: ValueMetric{kindFloat64, 44, 0}, wantErr: ErrBadValue},
+		{name: "Test kindInt64", kind: kindInt64, value: "44", wantVal: ValueMetric{kindInt64, 44, 0, nil}, wantErr: nil},
+		{name: "Test kindFloat64", kind: kindFloat64, value: "66.33", wantVal: ValueMetric{kindFloat64, 0, 66.33, nil}, wantErr: nil},
+		{name: "Test Bad kindInt64", kind: kindInt64, value: "TestErre44", wantVal: ValueMetric{kindInt64, 44, 0, nil}, wantErr: ErrBadValue},
+		{name: "Test Bad kindFloat64", kind: kindFloat64, value: "TestErre44.33", wantVal: ValueMetric{kindFloat64, 44, 0, nil}, wantErr: ErrBadValue},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -421,3 +421,82 @@ func Test_DoRead(t *testing.T) {
 		})
 	}
 }
+
+func Test_HistogramObserve(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.Observe(v)
+	}
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.InDelta(t, 2.65, h.Sum, 1e-9)
+	assert.NoError(t, h.Validate())
+	assert.Equal(t, "count=4 sum=2.65 buckets=0.1:2,1:1,+Inf:1", h.String())
+
+	h.ObserveN(0.5, 10)
+	assert.Equal(t, []uint64{2, 11, 1}, h.Counts)
+	assert.Equal(t, uint64(14), h.Count)
+	assert.InDelta(t, 7.65, h.Sum, 1e-9)
+	assert.NoError(t, h.Validate())
+}
+
+func Test_HistogramValidate(t *testing.T) {
+	tests := []struct {
+		hist    Histogram
+		wantErr error
+		name    string
+	}{
+		{name: "valid", hist: Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 1}, Count: 2, Sum: 3}},
+		{name: "bad len", hist: Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 1}, Count: 2}, wantErr: ErrBadHistogram},
+		{name: "not sorted", hist: Histogram{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}}, wantErr: ErrBadHistogram},
+		{name: "bad count", hist: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 1}, wantErr: ErrBadHistogram},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.hist.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func Test_HistogramDoUpdateDoRead(t *testing.T) {
+	h1 := NewHistogram([]float64{1})
+	h1.Observe(0.5)
+	h2 := NewHistogram([]float64{1})
+	h2.Observe(3)
+
+	stored := *ConvertToHistValueMetric(h1)
+	before := stored
+	stored.DoUpdate(*ConvertToHistValueMetric(h2))
+
+	assert.Equal(t, []uint64{1, 1}, stored.ValueHist().Counts)
+	assert.Equal(t, uint64(2), stored.ValueHist().Count)
+	assert.Equal(t, uint64(1), before.ValueHist().Count, "copies must not share merged data")
+
+	other := NewHistogram([]float64{5, 10})
+	other.Observe(7)
+	stored.DoUpdate(*ConvertToHistValueMetric(other))
+	assert.Equal(t, []float64{5, 10}, stored.ValueHist().Bounds)
+	assert.Equal(t, uint64(1), stored.ValueHist().Count)
+
+	read := stored
+	reset := stored.DoRead()
+	assert.Equal(t, uint64(1), read.ValueHist().Count)
+	assert.Equal(t, uint64(0), reset.ValueHist().Count)
+	assert.Equal(t, []uint64{0, 0, 0}, reset.ValueHist().Counts)
+	assert.Equal(t, "histogram", reset.GetTypeStr())
+}
+
+func Test_ConvertToValueMetricHist(t *testing.T) {
+	h := NewHistogram([]float64{1})
+	h.Observe(2)
+	got, err := ConvertToValueMetricHist(kindHistogram, nil, nil, h)
+	assert.NoError(t, err)
+	assert.Equal(t, int(kindHistogram), got.GetKind())
+
+	_, err = ConvertToValueMetricHist(kindHistogram, nil, nil, nil)
+	assert.ErrorIs(t, err, ErrBadValue)
+	assert.NotContains(t, err.Error(), "%!w")
+
+	_, err = ConvertToValueMetric(kindHistogram, "1")
+	assert.ErrorIs(t, err, ErrBadKindType)
+}
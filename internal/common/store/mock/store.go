@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	store "github.com/4aleksei/metricscum/internal/common/store"
 	gomock "github.com/golang/mock/gomock"
@@ -62,21 +63,21 @@ func (mr *MockStoreMockRecorder) Ping(ctx interface{}) *gomock.Call {
 }
 
 // SelectValue mocks base method.
-func (m *MockStore) SelectValue(ctx context.Context, name string, prog func(string, int, int64, float64) error) error {
+func (m *MockStore) SelectValue(ctx context.Context, name, labels string, prog store.FuncScanMetric) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SelectValue", ctx, name, prog)
+	ret := m.ctrl.Call(m, "SelectValue", ctx, name, labels, prog)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SelectValue indicates an expected call of SelectValue.
-func (mr *MockStoreMockRecorder) SelectValue(ctx, name, prog interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) SelectValue(ctx, name, labels, prog interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectValue", reflect.TypeOf((*MockStore)(nil).SelectValue), ctx, name, prog)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectValue", reflect.TypeOf((*MockStore)(nil).SelectValue), ctx, name, labels, prog)
 }
 
 // SelectValueAll mocks base method.
-func (m *MockStore) SelectValueAll(ctx context.Context, prog func(string, int, int64, float64) error) error {
+func (m *MockStore) SelectValueAll(ctx context.Context, prog store.FuncScanMetric) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SelectValueAll", ctx, prog)
 	ret0, _ := ret[0].(error)
@@ -90,7 +91,7 @@ func (mr *MockStoreMockRecorder) SelectValueAll(ctx, prog interface{}) *gomock.C
 }
 
 // Upsert mocks base method.
-func (m *MockStore) Upsert(ctx context.Context, val store.Metrics, prog func(string, int, int64, float64) error) error {
+func (m *MockStore) Upsert(ctx context.Context, val store.Metrics, prog store.FuncScanMetric) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Upsert", ctx, val, prog)
 	ret0, _ := ret[0].(error)
@@ -104,7 +105,7 @@ func (mr *MockStoreMockRecorder) Upsert(ctx, val, prog interface{}) *gomock.Call
 }
 
 // Upserts mocks base method.
-func (m *MockStore) Upserts(ctx context.Context, vals []store.Metrics, lim int, prog func(string, int, int64, float64) error) error {
+func (m *MockStore) Upserts(ctx context.Context, vals []store.Metrics, lim int, prog store.FuncScanMetric) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Upserts", ctx, vals, lim, prog)
 	ret0, _ := ret[0].(error)
@@ -116,3 +117,68 @@ func (mr *MockStoreMockRecorder) Upserts(ctx, vals, lim, prog interface{}) *gomo
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upserts", reflect.TypeOf((*MockStore)(nil).Upserts), ctx, vals, lim, prog)
 }
+
+// MockHistoryStore is a mock of HistoryStore interface.
+type MockHistoryStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryStoreMockRecorder
+}
+
+// MockHistoryStoreMockRecorder is the mock recorder for MockHistoryStore.
+type MockHistoryStoreMockRecorder struct {
+	mock *MockHistoryStore
+}
+
+// NewMockHistoryStore creates a new mock instance.
+func NewMockHistoryStore(ctrl *gomock.Controller) *MockHistoryStore {
+	mock := &MockHistoryStore{ctrl: ctrl}
+	mock.recorder = &MockHistoryStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryStore) EXPECT() *MockHistoryStoreMockRecorder {
+	return m.recorder
+}
+
+// InsertHistory mocks base method.
+func (m *MockHistoryStore) InsertHistory(ctx context.Context, vals []store.HistoryMetrics) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertHistory", ctx, vals)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertHistory indicates an expected call of InsertHistory.
+func (mr *MockHistoryStoreMockRecorder) InsertHistory(ctx, vals interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertHistory", reflect.TypeOf((*MockHistoryStore)(nil).InsertHistory), ctx, vals)
+}
+
+// SelectHistory mocks base method.
+func (m *MockHistoryStore) SelectHistory(ctx context.Context, name, labels string, kind int, from, to time.Time, prog store.FuncScanHistory) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SelectHistory", ctx, name, labels, kind, from, to, prog)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SelectHistory indicates an expected call of SelectHistory.
+func (mr *MockHistoryStoreMockRecorder) SelectHistory(ctx, name, labels, kind, from, to, prog interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SelectHistory", reflect.TypeOf((*MockHistoryStore)(nil).SelectHistory), ctx, name, labels, kind, from, to, prog)
+}
+
+// TrimHistory mocks base method.
+func (m *MockHistoryStore) TrimHistory(ctx context.Context, vals []store.HistoryMetrics, keep int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrimHistory", ctx, vals, keep)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TrimHistory indicates an expected call of TrimHistory.
+func (mr *MockHistoryStoreMockRecorder) TrimHistory(ctx, vals, keep interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrimHistory", reflect.TypeOf((*MockHistoryStore)(nil).TrimHistory), ctx, vals, keep)
+}
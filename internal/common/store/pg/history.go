@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/store"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	queryInsertHistory = `INSERT INTO metrics_history (name, labels, kind, delta, value, ts) VALUES ($1,$2,$3,$4,$5,$6)`
+	querySelectHistory = `SELECT name, labels, kind, delta, value, ts FROM metrics_history
+		WHERE name=$1 AND labels=$2 AND kind=$3 AND ts >= $4 AND ts <= $5 ORDER BY ts`
+	queryTrimHistory = `DELETE FROM metrics_history WHERE name=$1 AND labels=$2 AND kind=$3 AND ts <=
+		(SELECT ts FROM metrics_history WHERE name=$1 AND labels=$2 AND kind=$3 ORDER BY ts DESC OFFSET $4 LIMIT 1)`
+)
+
+func (d *DB) InsertHistory(ctx context.Context, vals []store.HistoryMetrics) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for i := range vals {
+		batch.Queue(queryInsertHistory, vals[i].Name, vals[i].Labels, vals[i].Kind, vals[i].Delta, vals[i].Value, vals[i].TS)
+	}
+	br := d.dbpool.SendBatch(ctx, batch)
+	for range vals {
+		if _, err := br.Exec(); err != nil {
+			_ = br.Close()
+			return fmt.Errorf("insert history: %w", err)
+		}
+	}
+	return br.Close()
+}
+
+// TrimHistory - keep only last keep points of every series in vals
+func (d *DB) TrimHistory(ctx context.Context, vals []store.HistoryMetrics, keep int) error {
+	if len(vals) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for i := range vals {
+		batch.Queue(queryTrimHistory, vals[i].Name, vals[i].Labels, vals[i].Kind, keep)
+	}
+	br := d.dbpool.SendBatch(ctx, batch)
+	for range vals {
+		if _, err := br.Exec(); err != nil {
+			_ = br.Close()
+			return fmt.Errorf("trim history: %w", err)
+		}
+	}
+	return br.Close()
+}
+
+func (d *DB) SelectHistory(ctx context.Context, name, labels string, kind int, from, to time.Time, prog store.FuncScanHistory) error {
+	rows, err := d.dbpool.Query(ctx, querySelectHistory, name, labels, kind, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m store.HistoryMetrics
+		errS := rows.Scan(&m.Name, &m.Labels, &m.Kind, &m.Delta, &m.Value, &m.TS)
+		if errS != nil {
+			return errS
+		}
+		if errP := prog(&m); errP != nil {
+			return errP
+		}
+	}
+	return rows.Err()
+}
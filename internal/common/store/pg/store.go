@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/4aleksei/metricscum/internal/common/store"
@@ -20,35 +21,52 @@ import (
 type (
 	DB struct {
 		dbpool *pgxpool.Pool
+		retry  utils.RetryPolicy
 	}
 
 	Config struct {
 		DatabaseDSN string
+		Retry       utils.RetryPolicy
 	}
 )
 
+// ProbePG - connection failures, serialization failures and deadlocks, server starting or
+// shutting down, too many connections and timeouts are retryable. Cancellation is final
 func ProbePG(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
-		return pgerrcode.IsConnectionException(pgErr.Code)
+		switch pgErr.Code {
+		case pgerrcode.AdminShutdown, pgerrcode.CrashShutdown, pgerrcode.CannotConnectNow,
+			pgerrcode.TooManyConnections:
+			return true
+		}
+		return pgerrcode.IsConnectionException(pgErr.Code) || pgerrcode.IsTransactionRollback(pgErr.Code)
+	}
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) || pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
 	}
-	return false
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 func NewDB(cfg Config) (*DB, error) {
 	if cfg.DatabaseDSN == "" {
-		return &DB{dbpool: nil}, nil
+		return &DB{dbpool: nil, retry: cfg.Retry}, nil
 	}
 
 	var db *pgxpool.Pool
 	ctx := context.Background()
 	ctxB, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
-	err := utils.RetryAction(ctxB, utils.RetryTimes(), func(ctx context.Context) error {
+	err := cfg.Retry.Do(ctxB, func(ctx context.Context) error {
 		var err error
 		db, err = pgxpool.New(ctx, cfg.DatabaseDSN)
 		return err
-	})
+	}, ProbePG)
 
 	if err != nil {
 		return nil, err
@@ -57,7 +75,7 @@ func NewDB(cfg Config) (*DB, error) {
 	ctxTimeOutPing, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	err = utils.RetryAction(ctxTimeOutPing, utils.RetryTimes(), func(ctx context.Context) error {
+	err = cfg.Retry.Do(ctxTimeOutPing, func(ctx context.Context) error {
 		ctxTime, cancel := context.WithTimeout(ctx, 3*time.Second)
 		defer cancel()
 		return db.Ping(ctxTime)
@@ -66,7 +84,12 @@ func NewDB(cfg Config) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DB{dbpool: db}, nil
+	return &DB{dbpool: db, retry: cfg.Retry}, nil
+}
+
+// Retry - policy of repeated queries
+func (d *DB) Retry() utils.RetryPolicy {
+	return d.retry
 }
 
 func (d *DB) Ping(ctx context.Context) error {
@@ -78,28 +101,47 @@ func (d *DB) Close(ctx context.Context) {
 }
 
 const (
-	queryDefault             = `INSERT INTO metrics (name, kind, delta, value , updated_at) VALUES ($1,$2,$3,$4,now())`
-	onConflictStatementDelta = ` ON CONFLICT (name, kind) 
-		DO UPDATE SET delta=metrics.delta+excluded.delta,  updated_at = now() RETURNING name, kind, delta, value`
-	onConflictStatementValue = ` ON CONFLICT (name, kind) 
-		DO UPDATE SET  value=excluded.value , updated_at = now() RETURNING name, kind, delta, value`
+	queryDefault             = `INSERT INTO metrics (name, kind, delta, value, hist, labels, updated_at) VALUES ($1,$2,$3,$4,$5,$6,now())`
+	onConflictStatementDelta = ` ON CONFLICT (name, labels, kind) 
+		DO UPDATE SET delta=metrics.delta+excluded.delta,  updated_at = now() RETURNING name, labels, kind, delta, value, hist`
+	onConflictStatementValue = ` ON CONFLICT (name, labels, kind) 
+		DO UPDATE SET  value=excluded.value , updated_at = now() RETURNING name, labels, kind, delta, value, hist`
+	// buckets are summed only for equal bounds, changed layout replaces histogram
+	onConflictStatementHist = ` ON CONFLICT (name, labels, kind)
+		DO UPDATE SET hist = CASE WHEN metrics.hist->'bounds' = excluded.hist->'bounds' THEN jsonb_build_object(
+			'bounds', excluded.hist->'bounds',
+			'counts', (SELECT jsonb_agg(a.c::numeric + b.c::numeric ORDER BY a.i)
+				FROM jsonb_array_elements_text(metrics.hist->'counts') WITH ORDINALITY a(c, i)
+				JOIN jsonb_array_elements_text(excluded.hist->'counts') WITH ORDINALITY b(c, i) ON a.i = b.i),
+			'sum', (metrics.hist->>'sum')::float8 + (excluded.hist->>'sum')::float8,
+			'count', (metrics.hist->>'count')::numeric + (excluded.hist->>'count')::numeric)
+		ELSE excluded.hist END, updated_at = now() RETURNING name, labels, kind, delta, value, hist`
+
+	querySelectValue    = `SELECT name, labels, kind, delta, value, hist FROM metrics WHERE name=$1 AND labels=$2 LIMIT 1`
+	querySelectValueAll = `SELECT name, labels, kind, delta, value, hist FROM metrics`
 )
 
-func (d *DB) Upsert(ctx context.Context, modval store.Metrics, prog func(n string, k int, d int64, v float64) error) error {
-	query := queryDefault
-	if modval.Delta.Valid {
-		query += onConflictStatementDelta
-	} else {
-		query += onConflictStatementValue
+func upsertQuery(modval *store.Metrics) string {
+	switch {
+	case modval.Hist != nil:
+		return queryDefault + onConflictStatementHist
+	case modval.Delta.Valid:
+		return queryDefault + onConflictStatementDelta
+	default:
+		return queryDefault + onConflictStatementValue
 	}
-	row := d.dbpool.QueryRow(ctx, query, modval.Name, modval.Kind, modval.Delta, modval.Value)
+}
+
+func (d *DB) Upsert(ctx context.Context, modval store.Metrics, prog store.FuncScanMetric) error {
+	query := upsertQuery(&modval)
+	row := d.dbpool.QueryRow(ctx, query, modval.Name, modval.Kind, modval.Delta, modval.Value, modval.Hist, modval.Labels)
 	if row != nil {
 		var m store.Metrics
-		err := row.Scan(&m.Name, &m.Kind, &m.Delta, &m.Value)
+		err := row.Scan(&m.Name, &m.Labels, &m.Kind, &m.Delta, &m.Value, &m.Hist)
 		if err != nil {
 			return err
 		}
-		errP := prog(m.Name, m.Kind, m.Delta.Int64, m.Value.Float64)
+		errP := prog(&m)
 		if errP != nil {
 			return errP
 		}
@@ -111,7 +153,7 @@ func (d *DB) Upsert(ctx context.Context, modval store.Metrics, prog func(n strin
 
 func (d *DB) Upserts(ctx context.Context,
 	modval []store.Metrics,
-	limitbatch int, prog func(n string, k int, d int64, v float64) error) error {
+	limitbatch int, prog store.FuncScanMetric) error {
 	conn, err := d.dbpool.Acquire(ctx)
 	if err != nil {
 		return fmt.Errorf("acquire connection: %w", err)
@@ -137,14 +179,10 @@ func (d *DB) Upserts(ctx context.Context,
 		}
 		batch := &pgx.Batch{}
 		for i := 0; i < indexLimit; i++ {
-			query := queryDefault
-			if modval[i+index].Delta.Valid {
-				query += onConflictStatementDelta
-			} else {
-				query += onConflictStatementValue
-			}
+			query := upsertQuery(&modval[i+index])
 
-			batch.Queue(query, modval[i+index].Name, modval[i+index].Kind, modval[i+index].Delta, modval[i+index].Value)
+			batch.Queue(query, modval[i+index].Name, modval[i+index].Kind, modval[i+index].Delta, modval[i+index].Value,
+				modval[i+index].Hist, modval[i+index].Labels)
 		}
 
 		br := tx.SendBatch(ctx, batch)
@@ -153,11 +191,11 @@ func (d *DB) Upserts(ctx context.Context,
 		for {
 			row := br.QueryRow()
 			var m store.Metrics
-			err := row.Scan(&m.Name, &m.Kind, &m.Delta, &m.Value)
+			err := row.Scan(&m.Name, &m.Labels, &m.Kind, &m.Delta, &m.Value, &m.Hist)
 			if err != nil {
 				break
 			}
-			errP := prog(m.Name, m.Kind, m.Delta.Int64, m.Value.Float64)
+			errP := prog(&m)
 			if errP != nil {
 				return errP
 			}
@@ -169,15 +207,15 @@ func (d *DB) Upserts(ctx context.Context,
 	return tx.Commit(ctx)
 }
 
-func (d *DB) SelectValue(ctx context.Context, name string, prog func(n string, k int, d int64, v float64) error) error {
-	row := d.dbpool.QueryRow(ctx, "SELECT name , kind , delta , value  FROM  metrics WHERE name=$1 LIMIT 1", name)
+func (d *DB) SelectValue(ctx context.Context, name, labels string, prog store.FuncScanMetric) error {
+	row := d.dbpool.QueryRow(ctx, querySelectValue, name, labels)
 	if row != nil {
 		var m store.Metrics
-		err := row.Scan(&m.Name, &m.Kind, &m.Delta, &m.Value)
+		err := row.Scan(&m.Name, &m.Labels, &m.Kind, &m.Delta, &m.Value, &m.Hist)
 		if err != nil {
 			return err
 		}
-		errP := prog(m.Name, m.Kind, m.Delta.Int64, m.Value.Float64)
+		errP := prog(&m)
 		if errP != nil {
 			return errP
 		}
@@ -187,8 +225,8 @@ func (d *DB) SelectValue(ctx context.Context, name string, prog func(n string, k
 	return nil
 }
 
-func (d *DB) SelectValueAll(ctx context.Context, prog func(n string, k int, d int64, v float64) error) error {
-	rows, err := d.dbpool.Query(ctx, "SELECT name , kind , delta , value  FROM metrics")
+func (d *DB) SelectValueAll(ctx context.Context, prog store.FuncScanMetric) error {
+	rows, err := d.dbpool.Query(ctx, querySelectValueAll)
 	if err != nil {
 		return err
 	}
@@ -196,12 +234,12 @@ func (d *DB) SelectValueAll(ctx context.Context, prog func(n string, k int, d in
 
 	for rows.Next() {
 		var m store.Metrics
-		errS := rows.Scan(&m.Name, &m.Kind, &m.Delta, &m.Value)
+		errS := rows.Scan(&m.Name, &m.Labels, &m.Kind, &m.Delta, &m.Value, &m.Hist)
 		if errS != nil {
 			return errS
 		}
 
-		errK := prog(m.Name, m.Kind, m.Delta.Int64, m.Value.Float64)
+		errK := prog(&m)
 		if errK != nil {
 			return errK
 		}
@@ -4,24 +4,48 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var ErrConflict = errors.New("data conflict")
 
+// FuncScanMetric - callback for every row returned by store
+type FuncScanMetric func(m *Metrics) error
+
 type Store interface {
-	Upsert(ctx context.Context, val Metrics, prog func(n string, k int, d int64, v float64) error) error
-	Upserts(ctx context.Context, vals []Metrics, lim int, prog func(n string, k int, d int64, v float64) error) error
-	SelectValue(ctx context.Context, name string, prog func(n string, k int, d int64, v float64) error) error
-	SelectValueAll(ctx context.Context, prog func(n string, k int, d int64, v float64) error) error
+	Upsert(ctx context.Context, val Metrics, prog FuncScanMetric) error
+	Upserts(ctx context.Context, vals []Metrics, lim int, prog FuncScanMetric) error
+	SelectValue(ctx context.Context, name, labels string, prog FuncScanMetric) error
+	SelectValueAll(ctx context.Context, prog FuncScanMetric) error
 	Close(ctx context.Context)
 	Ping(ctx context.Context) error
 }
 
+// FuncScanHistory - callback for every history row in time order
+type FuncScanHistory func(m *HistoryMetrics) error
+
+type HistoryStore interface {
+	InsertHistory(ctx context.Context, vals []HistoryMetrics) error
+	SelectHistory(ctx context.Context, name, labels string, kind int, from, to time.Time, prog FuncScanHistory) error
+	TrimHistory(ctx context.Context, vals []HistoryMetrics, keep int) error
+}
+
 type (
 	Metrics struct {
-		Name  string          `db:"name"`
-		Kind  int             `db:"kind"`
-		Delta sql.NullInt64   `db:"delta"`
-		Value sql.NullFloat64 `db:"value"`
+		Name   string          `db:"name"`
+		Labels string          `db:"labels"` // labels in canonical form k1="v1",k2="v2", empty without labels
+		Kind   int             `db:"kind"`
+		Delta  sql.NullInt64   `db:"delta"`
+		Value  sql.NullFloat64 `db:"value"`
+		Hist   []byte          `db:"hist"` // histogram in json, nil for other kinds
+	}
+
+	HistoryMetrics struct {
+		Name   string          `db:"name"`
+		Labels string          `db:"labels"`
+		Kind   int             `db:"kind"`
+		Delta  sql.NullInt64   `db:"delta"`
+		Value  sql.NullFloat64 `db:"value"`
+		TS     time.Time       `db:"ts"`
 	}
 )
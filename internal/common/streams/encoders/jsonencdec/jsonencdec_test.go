@@ -11,6 +11,7 @@ import (
 	"io"
 
 	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
 )
 
 func Test_NewReader(t *testing.T) {
@@ -57,3 +58,25 @@ func Test_OpenReader(t *testing.T) {
 		assert.Nil(t, reader.decoder)
 	})
 }
+
+func Test_WriteReadHistogram(t *testing.T) {
+	h := valuemetric.NewHistogram([]float64{1, 2})
+	h.Observe(1.5)
+	var a models.Metrics
+	a.ConvertMetricToModel("Hist", *valuemetric.ConvertToHistValueMetric(h))
+
+	t.Run("Test Json histogram snapshot", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := NewWriter()
+		writer.OpenWriter(&buf)
+		assert.Nil(t, writer.WriteData(&a))
+		writer.CloseWrite()
+
+		var m models.Metrics
+		reader := NewReader()
+		reader.OpenReader(&buf)
+		assert.Nil(t, reader.ReadData(&m))
+		reader.CloseRead()
+		assert.Equal(t, a, m)
+	})
+}
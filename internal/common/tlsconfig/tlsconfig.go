@@ -0,0 +1,90 @@
+// Package tlsconfig - TLS settings of servers and agents, mutual TLS with client certificates
+// verified against CA bundle
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrNoCerts    = errors.New("no certificates in CA bundle")
+	ErrServerCert = errors.New("client certificate verification requires server certificate")
+)
+
+// LoadCertPool - certificates of PEM bundle
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: %w", file, ErrNoCerts)
+	}
+	return pool, nil
+}
+
+// Server - nil without certificate. Client certificate is required and verified
+// against clientCAFile bundle if it is set
+func Server(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		if clientCAFile != "" {
+			return nil, ErrServerCert
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.NoClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// Client - server certificate is verified against caFile bundle, system roots if empty.
+// Client certificate is sent if certFile and keyFile are set. Nil if nothing is set
+func Client(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// PeerIdentity - common name of verified client certificate, agent identity in logs
+func PeerIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,110 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handshake - client and server sides over pipe, identity of client on server
+func handshake(t *testing.T, server, client *tls.Config) (string, error) {
+	sc, cc := net.Pipe()
+	defer sc.Close()
+	defer cc.Close()
+	type result struct {
+		id  string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		s := tls.Server(sc, server)
+		err := s.Handshake()
+		state := s.ConnectionState()
+		done <- result{id: PeerIdentity(&state), err: err}
+		// unblock client waiting for server answer
+		sc.Close()
+	}()
+	c := tls.Client(cc, client)
+	errC := c.Handshake()
+	if errC == nil {
+		// TLS 1.3 client learns about rejected certificate on first read
+		_, errC = c.Read(make([]byte, 1))
+	}
+	res := <-done
+	if res.err != nil {
+		return "", res.err
+	}
+	return res.id, nil
+}
+
+func Test_MutualTLS(t *testing.T) {
+	f := tlstest.Generate(t)
+	server, err := Server(f.ServerCert, f.ServerKey, f.CA)
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, server.ClientAuth)
+
+	client, err := Client(f.CA, f.ClientCert, f.ClientKey)
+	require.NoError(t, err)
+	client.ServerName = "localhost"
+	id, err := handshake(t, server, client)
+	require.NoError(t, err)
+	assert.Equal(t, tlstest.ClientName, id)
+
+	t.Run("without client certificate", func(t *testing.T) {
+		client, err := Client(f.CA, "", "")
+		require.NoError(t, err)
+		client.ServerName = "localhost"
+		_, err = handshake(t, server, client)
+		assert.Error(t, err)
+	})
+
+	t.Run("client certificate of other CA", func(t *testing.T) {
+		client, err := Client(f.CA, f.OtherCert, f.OtherKey)
+		require.NoError(t, err)
+		client.ServerName = "localhost"
+		_, err = handshake(t, server, client)
+		assert.Error(t, err)
+	})
+
+	t.Run("server TLS only", func(t *testing.T) {
+		server, err := Server(f.ServerCert, f.ServerKey, "")
+		require.NoError(t, err)
+		client, err := Client(f.CA, "", "")
+		require.NoError(t, err)
+		client.ServerName = "localhost"
+		id, err := handshake(t, server, client)
+		require.NoError(t, err)
+		assert.Empty(t, id)
+	})
+}
+
+func Test_Config(t *testing.T) {
+	f := tlstest.Generate(t)
+
+	c, err := Server("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+	_, err = Server("", "", f.CA)
+	assert.ErrorIs(t, err, ErrServerCert)
+
+	c, err = Client("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("no pem"), 0o600))
+	_, err = Server(f.ServerCert, f.ServerKey, empty)
+	assert.ErrorIs(t, err, ErrNoCerts)
+	_, err = Client(empty, "", "")
+	assert.ErrorIs(t, err, ErrNoCerts)
+	_, err = Client(f.CA, f.ClientCert, "")
+	assert.Error(t, err, "certificate without key")
+
+	assert.Empty(t, PeerIdentity(nil))
+}
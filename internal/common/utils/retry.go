@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRetryPolicy = errors.New("invalid retry policy")
+)
+
+// RetryPolicy - exponential backoff with full jitter. Delay before retry n is random
+// in [0, min(MaxInterval, InitialInterval*Multiplier^n)]. Retries stop after MaxRetries
+// or when next delay would exceed MaxElapsedTime, zero limit is not checked
+type RetryPolicy struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	MaxElapsedTime  time.Duration
+	MaxRetries      int
+}
+
+// DefaultRetryPolicy - three retries within 15 seconds, close to RetryTimes
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialInterval: time.Second,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      3,
+		MaxElapsedTime:  15 * time.Second,
+		MaxRetries:      3,
+	}
+}
+
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.InitialInterval < 0 || p.MaxInterval < 0 || p.MaxElapsedTime < 0:
+		return fmt.Errorf("%w: negative interval", ErrRetryPolicy)
+	case p.Multiplier < 1:
+		return fmt.Errorf("%w: multiplier %g is less than 1", ErrRetryPolicy, p.Multiplier)
+	case p.MaxRetries < 0:
+		return fmt.Errorf("%w: negative max retries", ErrRetryPolicy)
+	}
+	return nil
+}
+
+// Delay - random pause before retry attempt, attempt 0 is the first retry
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	limit := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt))
+	if p.MaxInterval > 0 && limit > float64(p.MaxInterval) {
+		limit = float64(p.MaxInterval)
+	}
+	if limit < 1 {
+		return 0
+	}
+	if limit >= math.MaxInt64 {
+		limit = math.MaxInt64 - 1
+	}
+	return time.Duration(rand.Int64N(int64(limit) + 1))
+}
+
+// Do - repeats callback while any prober reports error as retryable and policy limits allow,
+// without probers every error is retryable. Zero policy is DefaultRetryPolicy
+func (p RetryPolicy) Do(
+	ctx context.Context,
+	callback func(ctx context.Context) error,
+	probers ...func(err error) bool,
+) error {
+	if p == (RetryPolicy{}) {
+		p = DefaultRetryPolicy()
+	}
+	if len(probers) == 0 {
+		probers = append(probers, probeDefault)
+	}
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := callback(ctx)
+		if err == nil {
+			return nil
+		}
+		if !retryable(err, probers) || (p.MaxRetries > 0 && attempt >= p.MaxRetries) {
+			return err
+		}
+		delay := p.Delay(attempt)
+		if p.MaxElapsedTime > 0 && time.Since(start)+delay > p.MaxElapsedTime {
+			return err
+		}
+		SleepCancellable(ctx, delay)
+	}
+}
+
+func retryable(err error, probers []func(err error) bool) bool {
+	for _, prober := range probers {
+		if prober(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusError - unsuccessful HTTP response
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.StatusCode)
+}
+
+// RetryableStatus - timeouts, throttling and server errors except 501
+func RetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return code >= http.StatusInternalServerError
+}
+
+// ProbeHTTP - StatusError by RetryableStatus, cancellation is final, other errors are network failures
+func ProbeHTTP(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return RetryableStatus(se.StatusCode)
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// RetryFlags - registers -retry-* flags on command line
+func RetryFlags(p *RetryPolicy) {
+	flag.DurationVar(&p.InitialInterval, "retry-initial", p.InitialInterval, "first retry delay limit")
+	flag.DurationVar(&p.MaxInterval, "retry-max", p.MaxInterval, "retry delay limit")
+	flag.Float64Var(&p.Multiplier, "retry-multiplier", p.Multiplier, "retry delay growth")
+	flag.DurationVar(&p.MaxElapsedTime, "retry-max-elapsed", p.MaxElapsedTime, "retries time limit, 0 - unlimited")
+	flag.IntVar(&p.MaxRetries, "retry-max-retries", p.MaxRetries, "retries count limit, 0 - unlimited")
+}
+
+// RetryEnv - RETRY_INITIAL, RETRY_MAX, RETRY_MULTIPLIER, RETRY_MAX_ELAPSED and RETRY_MAX_RETRIES
+func RetryEnv(p *RetryPolicy) error {
+	durations := []struct {
+		name string
+		val  *time.Duration
+	}{
+		{"RETRY_INITIAL", &p.InitialInterval},
+		{"RETRY_MAX", &p.MaxInterval},
+		{"RETRY_MAX_ELAPSED", &p.MaxElapsedTime},
+	}
+	for _, d := range durations {
+		if env, ok := os.LookupEnv(d.name); ok {
+			val, err := time.ParseDuration(env)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.name, err)
+			}
+			*d.val = val
+		}
+	}
+	if env, ok := os.LookupEnv("RETRY_MULTIPLIER"); ok {
+		val, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return fmt.Errorf("RETRY_MULTIPLIER: %w", err)
+		}
+		p.Multiplier = val
+	}
+	if env, ok := os.LookupEnv("RETRY_MAX_RETRIES"); ok {
+		val, err := strconv.Atoi(env)
+		if err != nil {
+			return fmt.Errorf("RETRY_MAX_RETRIES: %w", err)
+		}
+		p.MaxRetries = val
+	}
+	return nil
+}
+
+// JSONRetry - retry section of json config, intervals are duration strings
+type JSONRetry struct {
+	InitialInterval *string  `json:"initial_interval,omitempty"`
+	MaxInterval     *string  `json:"max_interval,omitempty"`
+	Multiplier      *float64 `json:"multiplier,omitempty"`
+	MaxElapsedTime  *string  `json:"max_elapsed_time,omitempty"`
+	MaxRetries      *int     `json:"max_retries,omitempty"`
+}
+
+// Apply - sets fields present in json
+func (j *JSONRetry) Apply(p *RetryPolicy) error {
+	durations := []struct {
+		name string
+		src  *string
+		val  *time.Duration
+	}{
+		{"initial_interval", j.InitialInterval, &p.InitialInterval},
+		{"max_interval", j.MaxInterval, &p.MaxInterval},
+		{"max_elapsed_time", j.MaxElapsedTime, &p.MaxElapsedTime},
+	}
+	for _, d := range durations {
+		if d.src == nil {
+			continue
+		}
+		val, err := time.ParseDuration(*d.src)
+		if err != nil {
+			return fmt.Errorf("retry %s: %w", d.name, err)
+		}
+		*d.val = val
+	}
+	if j.Multiplier != nil {
+		p.Multiplier = *j.Multiplier
+	}
+	if j.MaxRetries != nil {
+		p.MaxRetries = *j.MaxRetries
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     50 * time.Millisecond,
+		Multiplier:      2,
+	}
+	limits := []time.Duration{10, 20, 40, 50, 50}
+	for attempt, limit := range limits {
+		for i := 0; i < 100; i++ {
+			d := p.Delay(attempt)
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, limit*time.Millisecond)
+		}
+	}
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		seen[p.Delay(3)] = true
+	}
+	assert.Greater(t, len(seen), 1, "delay is jittered")
+
+	assert.Equal(t, time.Duration(0), RetryPolicy{Multiplier: 2}.Delay(5))
+}
+
+func Test_RetryPolicyValidate(t *testing.T) {
+	assert.NoError(t, DefaultRetryPolicy().Validate())
+	assert.ErrorIs(t, RetryPolicy{Multiplier: 0.5}.Validate(), ErrRetryPolicy)
+	assert.ErrorIs(t, RetryPolicy{Multiplier: 1, InitialInterval: -1}.Validate(), ErrRetryPolicy)
+	assert.ErrorIs(t, RetryPolicy{Multiplier: 1, MaxRetries: -1}.Validate(), ErrRetryPolicy)
+}
+
+func Test_RetryPolicyDo(t *testing.T) {
+	errTemp := errors.New("temporary")
+	errFatal := errors.New("fatal")
+	p := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxRetries: 3}
+	probe := func(err error) bool { return errors.Is(err, errTemp) }
+
+	tests := []struct {
+		name  string
+		errs  []error
+		want  error
+		calls int
+	}{
+		{name: "success", errs: []error{nil}, calls: 1},
+		{name: "retry then success", errs: []error{errTemp, errTemp, nil}, calls: 3},
+		{name: "not retryable", errs: []error{errFatal, nil}, want: errFatal, calls: 1},
+		{name: "max retries", errs: []error{errTemp, errTemp, errTemp, errTemp, nil}, want: errTemp, calls: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := p.Do(context.Background(), func(ctx context.Context) error {
+				calls++
+				return tt.errs[calls-1]
+			}, probe)
+			assert.ErrorIs(t, err, tt.want)
+			if tt.want == nil {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
+
+func Test_RetryPolicyDoMaxElapsed(t *testing.T) {
+	p := RetryPolicy{
+		InitialInterval: 20 * time.Millisecond,
+		MaxInterval:     20 * time.Millisecond,
+		Multiplier:      1,
+		MaxElapsedTime:  30 * time.Millisecond,
+	}
+	calls := 0
+	start := time.Now()
+	err := p.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errors.New("down")
+	})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.GreaterOrEqual(t, calls, 2)
+}
+
+func Test_RetryPolicyDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := RetryPolicy{InitialInterval: time.Hour, Multiplier: 1}
+	calls := 0
+	err := p.Do(ctx, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errors.New("down")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func Test_ProbeHTTP(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &StatusError{StatusCode: 503}, want: true},
+		{err: &StatusError{StatusCode: 500}, want: true},
+		{err: &StatusError{StatusCode: 429}, want: true},
+		{err: &StatusError{StatusCode: 408}, want: true},
+		{err: &StatusError{StatusCode: 501}, want: false},
+		{err: &StatusError{StatusCode: 400}, want: false},
+		{err: fmt.Errorf("post: %w", &StatusError{StatusCode: 502}), want: true},
+		{err: errors.New("connection refused"), want: true},
+		{err: context.Canceled, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.want, ProbeHTTP(tt.err))
+		})
+	}
+}
+
+func Test_JSONRetryApply(t *testing.T) {
+	initial, elapsed, mult, retries := "200ms", "1m", 1.5, 7
+	p := DefaultRetryPolicy()
+	j := JSONRetry{InitialInterval: &initial, MaxElapsedTime: &elapsed, Multiplier: &mult, MaxRetries: &retries}
+	require.NoError(t, j.Apply(&p))
+	assert.Equal(t, RetryPolicy{
+		InitialInterval: 200 * time.Millisecond,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      1.5,
+		MaxElapsedTime:  time.Minute,
+		MaxRetries:      7,
+	}, p)
+
+	bad := "soon"
+	assert.Error(t, (&JSONRetry{MaxInterval: &bad}).Apply(&p))
+}
+
+func Test_RetryEnv(t *testing.T) {
+	t.Setenv("RETRY_INITIAL", "2s")
+	t.Setenv("RETRY_MULTIPLIER", "4")
+	t.Setenv("RETRY_MAX_RETRIES", "0")
+	p := DefaultRetryPolicy()
+	require.NoError(t, RetryEnv(&p))
+	assert.Equal(t, 2*time.Second, p.InitialInterval)
+	assert.Equal(t, 4.0, p.Multiplier)
+	assert.Equal(t, 0, p.MaxRetries)
+
+	t.Setenv("RETRY_MAX", "x")
+	assert.Error(t, RetryEnv(&p))
+}
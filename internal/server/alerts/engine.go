@@ -0,0 +1,202 @@
+package alerts
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"go.uber.org/zap"
+)
+
+type State string
+
+const (
+	StateInactive State = "inactive"
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+
+	DefaultInterval = 15 * time.Second
+)
+
+// Alert - current state of rule
+type Alert struct {
+	Labels   map[string]string `json:"labels,omitempty"`
+	ActiveAt *time.Time        `json:"active_at,omitempty"` // condition is true since
+	Value    *float64          `json:"value,omitempty"`     // nil when metric or rate is unknown
+	Name     string            `json:"name"`
+	Expr     string            `json:"expr"`
+	State    State             `json:"state"`
+}
+
+type metricsSource interface {
+	GetAllStoreValue(ctx context.Context, f func(string, valuemetric.ValueMetric) error) error
+}
+
+type notifier interface {
+	Notify(ctx context.Context, alerts []Alert, now time.Time)
+}
+
+type sample struct {
+	t time.Time
+	v float64
+}
+
+// Engine - periodic evaluation of rules against metrics source
+type Engine struct {
+	src      metricsSource
+	notifier notifier
+	l        *zap.Logger
+	prev     map[string]sample
+	mux      *sync.RWMutex
+	rules    []*rule
+	alerts   []Alert
+	interval time.Duration
+}
+
+type Option func(*Engine)
+
+// WithNotifier - send alerts to notifier after every evaluation
+func WithNotifier(n notifier) Option {
+	return func(e *Engine) {
+		e.notifier = n
+	}
+}
+
+func NewEngine(rules []Rule, src metricsSource, interval time.Duration, l *zap.Logger, opts ...Option) (*Engine, error) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	e := &Engine{
+		src:      src,
+		l:        l,
+		prev:     make(map[string]sample),
+		mux:      new(sync.RWMutex),
+		rules:    make([]*rule, 0, len(rules)),
+		alerts:   make([]Alert, 0, len(rules)),
+		interval: interval,
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	for _, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, c)
+		e.alerts = append(e.alerts, Alert{Name: r.Name, Expr: r.Expr, Labels: r.Labels, State: StateInactive})
+	}
+	return e, nil
+}
+
+func plainValue(val valuemetric.ValueMetric) (float64, bool) {
+	if v := val.ValueInt(); v != nil {
+		return float64(*v), true
+	}
+	if v := val.ValueFloat(); v != nil {
+		return *v, true
+	}
+	return 0, false
+}
+
+// value - left side of rule expression
+func (e *Engine) value(r *rule, values map[string]float64, now time.Time) (float64, bool) {
+	cur, ok := values[r.key]
+	if !ok {
+		return 0, false
+	}
+	if !r.rate {
+		return cur, true
+	}
+	prev, ok := e.prev[r.key]
+	if !ok || !now.After(prev.t) {
+		return 0, false
+	}
+	delta := cur - prev.v
+	if delta < 0 { // counter reset
+		delta = cur
+	}
+	return delta / now.Sub(prev.t).Seconds(), true
+}
+
+// Eval - evaluate all rules at moment now
+func (e *Engine) Eval(ctx context.Context, now time.Time) error {
+	values := make(map[string]float64)
+	err := e.src.GetAllStoreValue(ctx, func(key string, val valuemetric.ValueMetric) error {
+		if v, ok := plainValue(val); ok {
+			values[key] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	e.update(values, now)
+	if e.notifier != nil {
+		e.notifier.Notify(ctx, e.Alerts(), now)
+	}
+	return nil
+}
+
+func (e *Engine) update(values map[string]float64, now time.Time) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	for i, r := range e.rules {
+		a := &e.alerts[i]
+		v, ok := e.value(r, values, now)
+		a.Value = nil
+		if ok {
+			a.Value = &v
+		}
+		old := a.State
+		switch {
+		case !ok || !r.op.compare(v, r.threshold):
+			a.State = StateInactive
+			a.ActiveAt = nil
+		case a.State == StateInactive:
+			t := now
+			a.ActiveAt = &t
+			a.State = StatePending
+		}
+		if a.State == StatePending && now.Sub(*a.ActiveAt) >= r.forDur {
+			a.State = StateFiring
+		}
+		if old != a.State {
+			e.l.Info("alert state changed", zap.String("alert", a.Name),
+				zap.String("from", string(old)), zap.String("to", string(a.State)))
+		}
+	}
+	for _, r := range e.rules {
+		if v, ok := values[r.key]; ok && r.rate {
+			e.prev[r.key] = sample{t: now, v: v}
+		}
+	}
+}
+
+// Run - evaluate rules every interval until ctx is done
+func (e *Engine) Run(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := e.Eval(ctx, now); err != nil {
+					e.l.Error("alert rules evaluation failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Alerts - copy of current alerts in rules order
+func (e *Engine) Alerts() []Alert {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	res := make([]Alert, len(e.alerts))
+	copy(res, e.alerts)
+	return res
+}
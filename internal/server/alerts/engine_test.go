@@ -0,0 +1,77 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource map[string]valuemetric.ValueMetric
+
+func (s fakeSource) GetAllStoreValue(ctx context.Context, f func(string, valuemetric.ValueMetric) error) error {
+	for k, v := range s {
+		if err := f(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Test_EngineThreshold(t *testing.T) {
+	l, err := logger.NewLog("debug")
+	require.NoError(t, err)
+	src := fakeSource{"HeapAlloc": *valuemetric.ConvertToFloatValueMetric(600 << 20)}
+	e, err := NewEngine([]Rule{{Name: "HighHeap", Expr: "HeapAlloc > 500MB for 2m"}}, src, 0, l)
+	require.NoError(t, err)
+
+	start := time.Unix(1000, 0)
+	steps := []struct {
+		value float64
+		after time.Duration
+		want  State
+	}{
+		{value: 600 << 20, after: 0, want: StatePending},
+		{value: 600 << 20, after: time.Minute, want: StatePending},
+		{value: 600 << 20, after: 2 * time.Minute, want: StateFiring},
+		{value: 100, after: 3 * time.Minute, want: StateInactive},
+	}
+	for _, st := range steps {
+		src["HeapAlloc"] = *valuemetric.ConvertToFloatValueMetric(st.value)
+		require.NoError(t, e.Eval(context.Background(), start.Add(st.after)))
+		a := e.Alerts()[0]
+		assert.Equal(t, st.want, a.State, "after %v", st.after)
+		assert.Equal(t, st.value, *a.Value)
+	}
+}
+
+func Test_EngineRate(t *testing.T) {
+	l, err := logger.NewLog("debug")
+	require.NoError(t, err)
+	src := fakeSource{"PollCount": *valuemetric.ConvertToIntValueMetric(10)}
+	e, err := NewEngine([]Rule{{Name: "NoPolls", Expr: "rate(PollCount) == 0"}}, src, 0, l)
+	require.NoError(t, err)
+
+	start := time.Unix(1000, 0)
+	require.NoError(t, e.Eval(context.Background(), start))
+	a := e.Alerts()[0]
+	assert.Equal(t, StateInactive, a.State)
+	assert.Nil(t, a.Value)
+
+	src["PollCount"] = *valuemetric.ConvertToIntValueMetric(20)
+	require.NoError(t, e.Eval(context.Background(), start.Add(10*time.Second)))
+	a = e.Alerts()[0]
+	assert.Equal(t, StateInactive, a.State)
+	assert.Equal(t, 1.0, *a.Value)
+
+	require.NoError(t, e.Eval(context.Background(), start.Add(20*time.Second)))
+	assert.Equal(t, StateFiring, e.Alerts()[0].State)
+
+	delete(src, "PollCount")
+	require.NoError(t, e.Eval(context.Background(), start.Add(30*time.Second)))
+	assert.Equal(t, StateInactive, e.Alerts()[0].State)
+}
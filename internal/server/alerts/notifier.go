@@ -0,0 +1,231 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusFiring   string = "firing"
+	StatusResolved string = "resolved"
+
+	DefaultRepeatInterval = 4 * time.Hour
+
+	defaultWebhookTimeout = 5 * time.Second
+	defaultQueueSize      = 16
+)
+
+// Notice - alert in webhook message
+type Notice struct {
+	Alert
+	Status string `json:"status"` // firing or resolved
+}
+
+// Message - webhook payload, one message per group of alerts
+type Message struct {
+	GroupLabels map[string]string `json:"group_labels,omitempty"`
+	Status      string            `json:"status"` // firing if any alert of group is firing
+	Alerts      []Notice          `json:"alerts"`
+}
+
+// WebhookError - receiver answered with not 2xx status
+type WebhookError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook %s status %d", e.URL, e.StatusCode)
+}
+
+// probeWebhook - retry transport errors and statuses of utils.RetryableStatus
+func probeWebhook(err error) bool {
+	var we *WebhookError
+	if errors.As(err, &we) {
+		return utils.RetryableStatus(we.StatusCode)
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+type sent struct {
+	at     time.Time
+	status string
+}
+
+// batch - alerts of one evaluation queued for receiver
+type batch struct {
+	now    time.Time
+	alerts []Alert
+}
+
+// receiver - webhook with own delivery queue, sent is used only by delivery goroutine
+type receiver struct {
+	sent  map[string]sent // alert name
+	queue chan batch
+	url   string
+}
+
+// Notifier - deliver firing and resolved alerts to webhooks.
+// Same state of alert is sent to receiver once, firing alert is repeated every repeat interval.
+// Every receiver has bounded queue drained by own goroutine, so slow webhook does not block others
+type Notifier struct {
+	client    *http.Client
+	l         *zap.Logger
+	receivers []*receiver
+	groupBy   []string
+	retry     utils.RetryPolicy
+	repeat    time.Duration
+}
+
+func NewNotifier(urls []string, repeat time.Duration, groupBy []string, retry utils.RetryPolicy, l *zap.Logger) *Notifier {
+	if repeat <= 0 {
+		repeat = DefaultRepeatInterval
+	}
+	receivers := make([]*receiver, 0, len(urls))
+	for _, url := range urls {
+		receivers = append(receivers, &receiver{
+			sent:  make(map[string]sent),
+			queue: make(chan batch, defaultQueueSize),
+			url:   url,
+		})
+	}
+	return &Notifier{
+		client:    &http.Client{Timeout: defaultWebhookTimeout},
+		l:         l,
+		receivers: receivers,
+		groupBy:   groupBy,
+		retry:     retry,
+		repeat:    repeat,
+	}
+}
+
+// pending - alerts to send to receiver at moment now
+func (n *Notifier) pending(r *receiver, alerts []Alert, now time.Time) []Notice {
+	var res []Notice
+	for _, a := range alerts {
+		last, ok := r.sent[a.Name]
+		switch {
+		case a.State == StateFiring && (!ok || last.status != StatusFiring || now.Sub(last.at) >= n.repeat):
+			res = append(res, Notice{Alert: a, Status: StatusFiring})
+		case a.State != StateFiring && ok && last.status == StatusFiring:
+			res = append(res, Notice{Alert: a, Status: StatusResolved})
+		}
+	}
+	return res
+}
+
+func (n *Notifier) groupKey(a Alert) (string, map[string]string) {
+	if len(n.groupBy) == 0 {
+		return "", nil
+	}
+	labels := make(map[string]string, len(n.groupBy))
+	parts := make([]string, 0, len(n.groupBy))
+	for _, k := range n.groupBy {
+		labels[k] = a.Labels[k]
+		parts = append(parts, k+"="+a.Labels[k])
+	}
+	return strings.Join(parts, ","), labels
+}
+
+// group - messages by group_by labels in stable order
+func (n *Notifier) group(notices []Notice) []Message {
+	groups := make(map[string]*Message)
+	keys := make([]string, 0)
+	for _, nt := range notices {
+		key, labels := n.groupKey(nt.Alert)
+		m, ok := groups[key]
+		if !ok {
+			m = &Message{GroupLabels: labels, Status: StatusResolved}
+			groups[key] = m
+			keys = append(keys, key)
+		}
+		if nt.Status == StatusFiring {
+			m.Status = StatusFiring
+		}
+		m.Alerts = append(m.Alerts, nt)
+	}
+	sort.Strings(keys)
+	res := make([]Message, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, *groups[k])
+	}
+	return res
+}
+
+func (n *Notifier) post(ctx context.Context, url string, m *Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return n.retry.Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := n.client.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return &WebhookError{URL: url, StatusCode: resp.StatusCode}
+		}
+		return nil
+	}, probeWebhook)
+}
+
+// Notify - queue alerts for every receiver without waiting for delivery.
+// Batch for receiver with full queue is dropped, its changes are sent with next batch
+func (n *Notifier) Notify(_ context.Context, alerts []Alert, now time.Time) {
+	for _, r := range n.receivers {
+		select {
+		case r.queue <- batch{now: now, alerts: alerts}:
+		default:
+			n.l.Warn("alert webhook queue is full, notification dropped", zap.String("url", r.url))
+		}
+	}
+}
+
+// Run - deliver queued alerts to receivers until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	for _, r := range n.receivers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case b := <-r.queue:
+					n.deliver(ctx, r, b.alerts, b.now)
+				}
+			}
+		}()
+	}
+}
+
+// deliver - send changes of alerts to receiver, failed deliveries are repeated with next batch
+func (n *Notifier) deliver(ctx context.Context, r *receiver, alerts []Alert, now time.Time) {
+	for _, m := range n.group(n.pending(r, alerts, now)) {
+		if err := n.post(ctx, r.url, &m); err != nil {
+			n.l.Error("alert webhook delivery failed", zap.String("url", r.url), zap.Error(err))
+			continue
+		}
+		for _, nt := range m.Alerts {
+			if nt.Status == StatusResolved {
+				delete(r.sent, nt.Name)
+				continue
+			}
+			r.sent[nt.Name] = sent{at: now, status: nt.Status}
+		}
+	}
+}
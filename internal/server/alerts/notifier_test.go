@@ -0,0 +1,192 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/4aleksei/metricscum/internal/common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReceiver - in-process webhook sink, answers fail status to first failures requests
+type fakeReceiver struct {
+	srv      *httptest.Server
+	messages []Message
+	mux      sync.Mutex
+	requests int
+	failures int
+	fail     int
+}
+
+func newFakeReceiver(t *testing.T) *fakeReceiver {
+	r := &fakeReceiver{}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.requests++
+		if r.failures > 0 {
+			r.failures--
+			w.WriteHeader(r.fail)
+			return
+		}
+		var m Message
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.messages = append(r.messages, m)
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func (r *fakeReceiver) take() []Message {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	m := r.messages
+	r.messages = nil
+	return m
+}
+
+func newTestNotifier(t *testing.T, urls []string, groupBy []string) *Notifier {
+	l, err := logger.NewLog("debug")
+	require.NoError(t, err)
+	n := NewNotifier(urls, time.Hour, groupBy, utils.RetryPolicy{
+		InitialInterval: time.Millisecond,
+		Multiplier:      1,
+		MaxRetries:      2,
+	}, l)
+	return n
+}
+
+// deliverAll - synchronous delivery to every receiver, as delivery goroutines do
+func deliverAll(n *Notifier, alerts []Alert, now time.Time) {
+	for _, r := range n.receivers {
+		n.deliver(context.Background(), r, alerts, now)
+	}
+}
+
+func Test_NotifierDedupeRepeatResolve(t *testing.T) {
+	r := newFakeReceiver(t)
+	n := newTestNotifier(t, []string{r.srv.URL}, nil)
+	start := time.Unix(1000, 0)
+	firing := []Alert{{Name: "HighHeap", State: StateFiring}}
+
+	deliverAll(n, firing, start)
+	got := r.take()
+	require.Len(t, got, 1)
+	assert.Equal(t, StatusFiring, got[0].Status)
+	assert.Equal(t, StatusFiring, got[0].Alerts[0].Status)
+
+	deliverAll(n, firing, start.Add(time.Minute))
+	assert.Empty(t, r.take(), "same state is sent once")
+
+	deliverAll(n, firing, start.Add(time.Hour))
+	assert.Len(t, r.take(), 1, "firing alert is repeated after repeat interval")
+
+	resolved := []Alert{{Name: "HighHeap", State: StateInactive}}
+	deliverAll(n, resolved, start.Add(2*time.Hour))
+	got = r.take()
+	require.Len(t, got, 1)
+	assert.Equal(t, StatusResolved, got[0].Status)
+
+	deliverAll(n, resolved, start.Add(3*time.Hour))
+	assert.Empty(t, r.take(), "resolved is sent once")
+}
+
+func Test_NotifierGroup(t *testing.T) {
+	r := newFakeReceiver(t)
+	n := newTestNotifier(t, []string{r.srv.URL}, []string{"severity"})
+	alerts := []Alert{
+		{Name: "A", State: StateFiring, Labels: map[string]string{"severity": "page"}},
+		{Name: "B", State: StateFiring, Labels: map[string]string{"severity": "info"}},
+		{Name: "C", State: StateFiring, Labels: map[string]string{"severity": "page"}},
+		{Name: "D", State: StatePending, Labels: map[string]string{"severity": "page"}},
+	}
+	deliverAll(n, alerts, time.Now())
+	got := r.take()
+	require.Len(t, got, 2)
+	assert.Equal(t, map[string]string{"severity": "info"}, got[0].GroupLabels)
+	assert.Len(t, got[0].Alerts, 1)
+	assert.Equal(t, map[string]string{"severity": "page"}, got[1].GroupLabels)
+	assert.Len(t, got[1].Alerts, 2)
+}
+
+func Test_NotifierRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		fail         int
+		failures     int
+		wantRequests int
+		wantMessages int
+	}{
+		{name: "retry server error", fail: http.StatusServiceUnavailable, failures: 2, wantRequests: 3, wantMessages: 1},
+		{name: "no retry bad request", fail: http.StatusBadRequest, failures: 1, wantRequests: 1, wantMessages: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newFakeReceiver(t)
+			r.fail = tt.fail
+			r.failures = tt.failures
+			n := newTestNotifier(t, []string{r.srv.URL}, nil)
+			deliverAll(n, []Alert{{Name: "A", State: StateFiring}}, time.Now())
+			assert.Equal(t, tt.wantRequests, r.requests)
+			assert.Len(t, r.take(), tt.wantMessages)
+		})
+	}
+}
+
+func Test_EngineNotify(t *testing.T) {
+	r1 := newFakeReceiver(t)
+	r2 := newFakeReceiver(t)
+	n := newTestNotifier(t, []string{r1.srv.URL, r2.srv.URL}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Run(ctx)
+	src := fakeSource{"HeapAlloc": *valuemetric.ConvertToFloatValueMetric(600 << 20)}
+	e, err := NewEngine([]Rule{{Name: "HighHeap", Expr: "HeapAlloc > 500MB"}}, src, 0, n.l, WithNotifier(n))
+	require.NoError(t, err)
+
+	require.NoError(t, e.Eval(context.Background(), time.Now()))
+	for _, r := range []*fakeReceiver{r1, r2} {
+		var got []Message
+		require.Eventually(t, func() bool {
+			got = append(got, r.take()...)
+			return len(got) > 0
+		}, time.Second, 10*time.Millisecond)
+		require.Len(t, got, 1)
+		assert.Equal(t, "HighHeap", got[0].Alerts[0].Name)
+		assert.Equal(t, float64(600<<20), *got[0].Alerts[0].Value)
+	}
+}
+
+func Test_NotifyDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := newFakeReceiver(t)
+
+	n := newTestNotifier(t, []string{slow.URL, fast.srv.URL}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Run(ctx)
+
+	start := time.Now()
+	for i := 0; i < 2*defaultQueueSize; i++ {
+		n.Notify(ctx, []Alert{{Name: "A", State: StateFiring}}, start)
+	}
+	assert.Less(t, time.Since(start), time.Second, "Notify waits for unreachable webhook")
+	assert.Eventually(t, func() bool { return len(fast.take()) > 0 }, time.Second, 10*time.Millisecond,
+		"slow receiver blocks other receivers")
+}
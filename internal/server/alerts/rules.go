@@ -0,0 +1,145 @@
+// Package alerts - threshold rules evaluated against stored metrics
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule - alerting rule as written in rules file.
+// Expr is metric op number[KB|MB|GB|TB] or rate(metric) op number, optionally followed by "for 2m"
+type Rule struct {
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Name   string            `json:"name" yaml:"name"`
+	Expr   string            `json:"expr" yaml:"expr"`
+	For    string            `json:"for,omitempty" yaml:"for,omitempty"`
+}
+
+type rulesFile struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+var (
+	ErrBadRule = errors.New("invalid rule")
+)
+
+// LoadRules - read rules from .json, .yaml or .yml file
+func LoadRules(name string) ([]Rule, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var rf rulesFile
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &rf)
+	default:
+		err = json.Unmarshal(data, &rf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rf.Rules, nil
+}
+
+type operator string
+
+const (
+	opGT operator = ">"
+	opGE operator = ">="
+	opLT operator = "<"
+	opLE operator = "<="
+	opEQ operator = "=="
+	opNE operator = "!="
+)
+
+func (op operator) compare(a, b float64) bool {
+	switch op {
+	case opGT:
+		return a > b
+	case opGE:
+		return a >= b
+	case opLT:
+		return a < b
+	case opLE:
+		return a <= b
+	case opEQ:
+		return a == b
+	case opNE:
+		return a != b
+	default:
+		return false
+	}
+}
+
+var units = map[string]float64{
+	"":   1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+var exprRe = regexp.MustCompile(`^\s*(?:rate\(\s*(\S+?)\s*\)|(\S+?))\s*(>=|<=|==|!=|>|<)\s*` +
+	`([-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)\s*(KB|MB|GB|TB)?\s*(?:for\s+(\S+))?\s*$`)
+
+// rule - compiled rule
+type rule struct {
+	Rule
+	key       string
+	rate      bool
+	op        operator
+	threshold float64
+	forDur    time.Duration
+}
+
+// compile - parse expression, "for" field overrides "for" in expression
+func compile(r Rule) (*rule, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("%w: no name", ErrBadRule)
+	}
+	m := exprRe.FindStringSubmatch(r.Expr)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s: bad expression %q", ErrBadRule, r.Name, r.Expr)
+	}
+	c := &rule{Rule: r, op: operator(m[3])}
+	metric := m[2]
+	if m[1] != "" {
+		metric = m[1]
+		c.rate = true
+	}
+	id, labels := models.ParseSeriesKey(metric)
+	key, err := models.SeriesKey(id, labels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrBadRule, r.Name, err)
+	}
+	c.key = key
+
+	v, err := strconv.ParseFloat(m[4], 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrBadRule, r.Name, err)
+	}
+	c.threshold = v * units[m[5]]
+
+	forStr := m[6]
+	if r.For != "" {
+		forStr = r.For
+	}
+	if forStr != "" {
+		c.forDur, err = time.ParseDuration(forStr)
+		if err != nil || c.forDur < 0 {
+			return nil, fmt.Errorf("%w: %s: bad for %q", ErrBadRule, r.Name, forStr)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,75 @@
+package alerts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_compile(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		name      string
+		rule      Rule
+		key       string
+		op        operator
+		threshold float64
+		forDur    time.Duration
+		rate      bool
+	}{
+		{name: "threshold with unit and for", rule: Rule{Name: "a", Expr: "HeapAlloc > 500MB for 2m"},
+			key: "HeapAlloc", op: opGT, threshold: 500 << 20, forDur: 2 * time.Minute},
+		{name: "rate", rule: Rule{Name: "a", Expr: "rate(PollCount) == 0", For: "1m"},
+			key: "PollCount", op: opEQ, threshold: 0, forDur: time.Minute, rate: true},
+		{name: "labels", rule: Rule{Name: "a", Expr: `CPUutilization{host="a",cpu="1"} >= 90.5`},
+			key: `CPUutilization{cpu="1",host="a"}`, op: opGE, threshold: 90.5},
+		{name: "for field overrides", rule: Rule{Name: "a", Expr: "x < 1 for 2m", For: "5s"},
+			key: "x", op: opLT, threshold: 1, forDur: 5 * time.Second},
+		{name: "no name", rule: Rule{Expr: "x < 1"}, wantErr: ErrBadRule},
+		{name: "bad expr", rule: Rule{Name: "a", Expr: "x ~ 1"}, wantErr: ErrBadRule},
+		{name: "bad for", rule: Rule{Name: "a", Expr: "x < 1 for soon"}, wantErr: ErrBadRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compile(tt.rule)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			assert.Equal(t, tt.key, got.key)
+			assert.Equal(t, tt.op, got.op)
+			assert.Equal(t, tt.threshold, got.threshold)
+			assert.Equal(t, tt.forDur, got.forDur)
+			assert.Equal(t, tt.rate, got.rate)
+		})
+	}
+}
+
+func Test_LoadRules(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte(`
+rules:
+  - name: HighHeap
+    expr: HeapAlloc > 500MB
+    for: 2m
+    labels:
+      severity: page
+`), 0o600))
+	jsonFile := filepath.Join(dir, "rules.json")
+	require.NoError(t, os.WriteFile(jsonFile,
+		[]byte(`{"rules":[{"name":"HighHeap","expr":"HeapAlloc > 500MB","for":"2m","labels":{"severity":"page"}}]}`), 0o600))
+
+	want := []Rule{{Name: "HighHeap", Expr: "HeapAlloc > 500MB", For: "2m", Labels: map[string]string{"severity": "page"}}}
+	for _, name := range []string{yamlFile, jsonFile} {
+		t.Run(filepath.Ext(name), func(t *testing.T) {
+			got, err := LoadRules(name)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
@@ -2,13 +2,21 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/4aleksei/metricscum/internal/common/repository"
 	"github.com/4aleksei/metricscum/internal/common/store/pg"
+	"github.com/4aleksei/metricscum/internal/common/utils"
+)
+
+var (
+	ErrReplayConfig = errors.New("replay window and nonce cache size must be positive")
+	ErrNotPositive  = errors.New("value must be positive")
 )
 
 type Config struct {
@@ -16,13 +24,29 @@ type Config struct {
 	Level           string
 	FilePath        string
 	DBcfg           pg.Config
-	Key             string
+	Key             string // legacy key of agents without key id
+	KeyringFile     string // keys by id, reloaded on SIGHUP
 	Repcfg          repository.Config
 	PrivateKeyFile  string
 	ConfigJsonFile  string
 	Cidr            string
 	Grcp            string
 	PrivateCertFile string
+	ClientCAFile    string // CA bundle of agents certificates, mutual TLS of gRPC server and of HTTP server if HTTPS
+	HTTPS           bool   // HTTP server with PrivateCertFile and PrivateKeyFile
+	HistorySize     int
+	RulesFile       string
+	RulesInterval   int64
+	AlertWebhooks   []string
+	AlertGroupBy    []string
+	AlertRepeat     int64
+	StatsdAddress   string
+	StatsdFlush     int64
+	StatsdBatch     int
+	ReplayWindow    int64 // allowed clock skew of signed request timestamp, seconds
+	NonceCacheSize  int
+	StrictAuth      bool              // reject unsigned requests when Key or KeyringFile is set
+	Retry           utils.RetryPolicy // database and alert webhooks
 }
 
 const (
@@ -32,12 +56,25 @@ const (
 	FilePathDefault        string = "./data.store"
 	databaseDSNDefault     string = ""
 	KeyDefault             string = ""
+	KeyringFileDefault     string = ""
 	ConfigDefaultJson      string = ""
 	WriteIntervalDefault   int64  = 300
 	RestoreDefault         bool   = true
 	PrivateKeyFileDefault  string = ""
 	CidrDefault                   = ""
 	PrivateCertFileDefault string = ""
+	ClientCAFileDefault    string = ""
+	HTTPSDefault           bool   = false
+	HistorySizeDefault     int    = 1000
+	RulesFileDefault       string = ""
+	RulesIntervalDefault   int64  = 15
+	AlertRepeatDefault     int64  = 4 * 60 * 60
+	StatsdAddressDefault   string = ""
+	StatsdFlushDefault     int64  = 10
+	StatsdBatchDefault     int    = 1000
+	ReplayWindowDefault    int64  = 300
+	NonceCacheSizeDefault  int    = 100000
+	StrictAuthDefault      bool   = false
 )
 
 func initDefaultCfg() *Config {
@@ -46,7 +83,9 @@ func initDefaultCfg() *Config {
 	cfg.Level = LevelDefault
 	cfg.FilePath = FilePathDefault
 	cfg.DBcfg.DatabaseDSN = databaseDSNDefault
+	cfg.Retry = utils.DefaultRetryPolicy()
 	cfg.Key = KeyDefault
+	cfg.KeyringFile = KeyringFileDefault
 	cfg.Repcfg.Restore = RestoreDefault
 	cfg.Repcfg.Interval = WriteIntervalDefault
 	cfg.ConfigJsonFile = ConfigDefaultJson
@@ -54,6 +93,18 @@ func initDefaultCfg() *Config {
 	cfg.Cidr = CidrDefault
 	cfg.Grcp = GrcpAddressDefault
 	cfg.PrivateCertFile = PrivateCertFileDefault
+	cfg.ClientCAFile = ClientCAFileDefault
+	cfg.HTTPS = HTTPSDefault
+	cfg.HistorySize = HistorySizeDefault
+	cfg.RulesFile = RulesFileDefault
+	cfg.RulesInterval = RulesIntervalDefault
+	cfg.AlertRepeat = AlertRepeatDefault
+	cfg.StatsdAddress = StatsdAddressDefault
+	cfg.StatsdFlush = StatsdFlushDefault
+	cfg.StatsdBatch = StatsdBatchDefault
+	cfg.ReplayWindow = ReplayWindowDefault
+	cfg.NonceCacheSize = NonceCacheSizeDefault
+	cfg.StrictAuth = StrictAuthDefault
 	return cfg
 }
 
@@ -72,6 +123,29 @@ func getJsonFileName(key string, cfg *Config) bool {
 	return true
 }
 
+// splitList - comma separated list without empty items
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// positiveEnv - value of env variable name must be positive integer
+func positiveEnv(name, env string) (int, error) {
+	val, err := strconv.Atoi(env)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if val <= 0 {
+		return 0, fmt.Errorf("%s: %w", name, ErrNotPositive)
+	}
+	return val, nil
+}
+
 func readConfigFlagPg(cfg *pg.Config) {
 	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "DATABASE_DSN")
 }
@@ -135,10 +209,32 @@ func NewConfig() (*Config, error) {
 
 	readConfigFlagRep(&cfg.Repcfg)
 	readConfigFlagPg(&cfg.DBcfg)
+	utils.RetryFlags(&cfg.Retry)
 
 	flag.StringVar(&cfg.Key, "k", cfg.Key, "key for signature")
+	flag.StringVar(&cfg.KeyringFile, "keyring", cfg.KeyringFile, "Keyring file name of signature keys by key id (json), reloaded on SIGHUP")
 	flag.StringVar(&cfg.PrivateKeyFile, "crypto-key", cfg.PrivateKeyFile, "Private key file name (pem)")
 	flag.StringVar(&cfg.PrivateCertFile, "crypto-cert", cfg.PrivateCertFile, "Private cert file name (pem)")
+	flag.StringVar(&cfg.ClientCAFile, "client-ca", cfg.ClientCAFile, "CA bundle file name (pem) to verify client certificates, empty - no client certificates")
+	flag.BoolVar(&cfg.HTTPS, "https", cfg.HTTPS, "HTTP server with TLS of crypto-cert and crypto-key true/false")
+	flag.IntVar(&cfg.HistorySize, "history-size", cfg.HistorySize, "History points per metric, 0 - no history")
+	flag.StringVar(&cfg.RulesFile, "rules", cfg.RulesFile, "Alerting rules file name (json/yaml)")
+	flag.Int64Var(&cfg.RulesInterval, "rules-interval", cfg.RulesInterval, "Alerting rules evaluation interval")
+	flag.Func("alert-webhooks", "Alert webhook URLs, comma separated", func(s string) error {
+		cfg.AlertWebhooks = splitList(s)
+		return nil
+	})
+	flag.Func("alert-group-by", "Alert labels to group notifications by, comma separated", func(s string) error {
+		cfg.AlertGroupBy = splitList(s)
+		return nil
+	})
+	flag.Int64Var(&cfg.AlertRepeat, "alert-repeat", cfg.AlertRepeat, "Repeat interval of firing alert notification")
+	flag.StringVar(&cfg.StatsdAddress, "statsd", cfg.StatsdAddress, "StatsD UDP address, empty - disabled")
+	flag.Int64Var(&cfg.StatsdFlush, "statsd-flush", cfg.StatsdFlush, "StatsD flush interval")
+	flag.IntVar(&cfg.StatsdBatch, "statsd-batch", cfg.StatsdBatch, "StatsD max series in batch before flush")
+	flag.Int64Var(&cfg.ReplayWindow, "replay-window", cfg.ReplayWindow, "Allowed clock skew of signed request timestamp in seconds")
+	flag.IntVar(&cfg.NonceCacheSize, "nonce-cache", cfg.NonceCacheSize, "Max count of remembered nonces of signed requests, signed requests are rejected when full")
+	flag.BoolVar(&cfg.StrictAuth, "strict-auth", cfg.StrictAuth, "Reject requests without signature, timestamp and nonce when key or keyring is set true/false")
 
 	flag.Parse()
 
@@ -152,16 +248,119 @@ func NewConfig() (*Config, error) {
 		cfg.Key = envKey
 	}
 
+	if envKeyring := os.Getenv("KEYRING_FILE"); envKeyring != "" {
+		cfg.KeyringFile = envKeyring
+	}
+
 	if envPrivateKeyFile := os.Getenv("CRYPTO_KEY"); envPrivateKeyFile != "" {
 		cfg.PrivateKeyFile = envPrivateKeyFile
 	}
 
+	if envClientCA := os.Getenv("CLIENT_CA"); envClientCA != "" {
+		cfg.ClientCAFile = envClientCA
+	}
+
+	if envHTTPS := os.Getenv("HTTPS"); envHTTPS != "" {
+		val, err := strconv.ParseBool(envHTTPS)
+		if err != nil {
+			return nil, fmt.Errorf("HTTPS: %w", err)
+		}
+		cfg.HTTPS = val
+	}
+
 	if envTrustNet := os.Getenv("TRUSTED_SUBNET"); envTrustNet != "" {
 		cfg.Cidr = envTrustNet
 	}
 
+	if envHistorySize := os.Getenv("HISTORY_SIZE"); envHistorySize != "" {
+		val, err := strconv.Atoi(envHistorySize)
+		if err != nil {
+			return nil, fmt.Errorf("HISTORY_SIZE: %w", err)
+		}
+		if val < 0 {
+			return nil, fmt.Errorf("HISTORY_SIZE: negative value %d", val)
+		}
+		cfg.HistorySize = val
+	}
+
+	if envRulesFile := os.Getenv("RULES_FILE"); envRulesFile != "" {
+		cfg.RulesFile = envRulesFile
+	}
+	if envRulesInterval := os.Getenv("RULES_INTERVAL"); envRulesInterval != "" {
+		val, err := positiveEnv("RULES_INTERVAL", envRulesInterval)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RulesInterval = int64(val)
+	}
+
+	if envWebhooks := os.Getenv("ALERT_WEBHOOKS"); envWebhooks != "" {
+		cfg.AlertWebhooks = splitList(envWebhooks)
+	}
+	if envGroupBy := os.Getenv("ALERT_GROUP_BY"); envGroupBy != "" {
+		cfg.AlertGroupBy = splitList(envGroupBy)
+	}
+	if envAlertRepeat := os.Getenv("ALERT_REPEAT"); envAlertRepeat != "" {
+		val, err := positiveEnv("ALERT_REPEAT", envAlertRepeat)
+		if err != nil {
+			return nil, err
+		}
+		cfg.AlertRepeat = int64(val)
+	}
+
+	if envStatsd := os.Getenv("STATSD_ADDRESS"); envStatsd != "" {
+		cfg.StatsdAddress = envStatsd
+	}
+	if envStatsdFlush := os.Getenv("STATSD_FLUSH_INTERVAL"); envStatsdFlush != "" {
+		val, err := positiveEnv("STATSD_FLUSH_INTERVAL", envStatsdFlush)
+		if err != nil {
+			return nil, err
+		}
+		cfg.StatsdFlush = int64(val)
+	}
+	if envStatsdBatch := os.Getenv("STATSD_BATCH"); envStatsdBatch != "" {
+		val, err := positiveEnv("STATSD_BATCH", envStatsdBatch)
+		if err != nil {
+			return nil, err
+		}
+		cfg.StatsdBatch = val
+	}
+	if envReplayWindow := os.Getenv("REPLAY_WINDOW"); envReplayWindow != "" {
+		val, err := strconv.Atoi(envReplayWindow)
+		if err != nil {
+			return nil, fmt.Errorf("REPLAY_WINDOW: %w", err)
+		}
+		cfg.ReplayWindow = int64(val)
+	}
+	if envNonceCache := os.Getenv("NONCE_CACHE_SIZE"); envNonceCache != "" {
+		val, err := strconv.Atoi(envNonceCache)
+		if err != nil {
+			return nil, fmt.Errorf("NONCE_CACHE_SIZE: %w", err)
+		}
+		cfg.NonceCacheSize = val
+	}
+	if envStrictAuth := os.Getenv("STRICT_AUTH"); envStrictAuth != "" {
+		val, err := strconv.ParseBool(envStrictAuth)
+		if err != nil {
+			return nil, fmt.Errorf("STRICT_AUTH: %w", err)
+		}
+		cfg.StrictAuth = val
+	}
+	// replay settings are used only by signature check
+	if (cfg.Key != "" || cfg.KeyringFile != "") && (cfg.ReplayWindow <= 0 || cfg.NonceCacheSize <= 0) {
+		return nil, ErrReplayConfig
+	}
+
 	readConfigEnvRep(&cfg.Repcfg)
 	readConfigEnvPg(&cfg.DBcfg)
+	if err := utils.RetryEnv(&cfg.Retry); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Retry.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.DBcfg.Retry = cfg.Retry
 
 	return cfg, nil
 }
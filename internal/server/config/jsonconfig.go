@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/utils"
 )
 
 type (
@@ -43,9 +45,31 @@ type Jsonconfig struct {
 	StoreFile *string `json:"store_file,omitempty"`
 	CryptoKey *string `json:"crypto_key,omitempty"`
 	Key       *string `json:"key,omitempty"`
+	Keyring   *string `json:"keyring_file,omitempty"`
 	Level     *string `json:"level,omitempty"`
 
 	CryptoCert *string `json:"crypto_cert,omitempty"`
+	ClientCA   *string `json:"client_ca,omitempty"`
+	HTTPS      *bool   `json:"https,omitempty"`
+
+	HistorySize *int `json:"history_size,omitempty"`
+
+	RulesFile     *string   `json:"rules_file,omitempty"`
+	RulesInterval *Duration `json:"rules_interval,omitempty"`
+
+	AlertWebhooks []string  `json:"alert_webhooks,omitempty"`
+	AlertGroupBy  []string  `json:"alert_group_by,omitempty"`
+	AlertRepeat   *Duration `json:"alert_repeat_interval,omitempty"`
+
+	StatsdAddress *string   `json:"statsd_address,omitempty"`
+	StatsdFlush   *Duration `json:"statsd_flush_interval,omitempty"`
+	StatsdBatch   *int      `json:"statsd_batch,omitempty"`
+
+	ReplayWindow   *Duration `json:"replay_window,omitempty"`
+	NonceCacheSize *int      `json:"nonce_cache_size,omitempty"`
+	StrictAuth     *bool     `json:"strict_auth,omitempty"`
+
+	Retry *utils.JSONRetry `json:"retry,omitempty"`
 }
 
 func jsonConfigDecode(body io.ReadCloser) (*Jsonconfig, error) {
@@ -98,6 +122,10 @@ func loadConfigJson(name string, cfg *Config) error {
 		cfg.Key = *jsonconfig.Key
 	}
 
+	if jsonconfig.Keyring != nil {
+		cfg.KeyringFile = *jsonconfig.Keyring
+	}
+
 	if jsonconfig.CryptoKey != nil {
 		cfg.PrivateKeyFile = *jsonconfig.CryptoKey
 	}
@@ -114,5 +142,67 @@ func loadConfigJson(name string, cfg *Config) error {
 		cfg.PrivateCertFile = *jsonconfig.CryptoCert
 	}
 
+	if jsonconfig.ClientCA != nil {
+		cfg.ClientCAFile = *jsonconfig.ClientCA
+	}
+
+	if jsonconfig.HTTPS != nil {
+		cfg.HTTPS = *jsonconfig.HTTPS
+	}
+
+	if jsonconfig.HistorySize != nil {
+		cfg.HistorySize = *jsonconfig.HistorySize
+	}
+
+	if jsonconfig.RulesFile != nil {
+		cfg.RulesFile = *jsonconfig.RulesFile
+	}
+
+	if jsonconfig.RulesInterval != nil {
+		cfg.RulesInterval = int64(*jsonconfig.RulesInterval) / 1000000000
+	}
+
+	if jsonconfig.AlertWebhooks != nil {
+		cfg.AlertWebhooks = jsonconfig.AlertWebhooks
+	}
+
+	if jsonconfig.AlertGroupBy != nil {
+		cfg.AlertGroupBy = jsonconfig.AlertGroupBy
+	}
+
+	if jsonconfig.AlertRepeat != nil {
+		cfg.AlertRepeat = int64(*jsonconfig.AlertRepeat) / 1000000000
+	}
+
+	if jsonconfig.StatsdAddress != nil {
+		cfg.StatsdAddress = *jsonconfig.StatsdAddress
+	}
+
+	if jsonconfig.StatsdFlush != nil {
+		cfg.StatsdFlush = int64(*jsonconfig.StatsdFlush) / 1000000000
+	}
+
+	if jsonconfig.StatsdBatch != nil {
+		cfg.StatsdBatch = *jsonconfig.StatsdBatch
+	}
+
+	if jsonconfig.ReplayWindow != nil {
+		cfg.ReplayWindow = int64(*jsonconfig.ReplayWindow) / 1000000000
+	}
+
+	if jsonconfig.NonceCacheSize != nil {
+		cfg.NonceCacheSize = *jsonconfig.NonceCacheSize
+	}
+
+	if jsonconfig.StrictAuth != nil {
+		cfg.StrictAuth = *jsonconfig.StrictAuth
+	}
+
+	if jsonconfig.Retry != nil {
+		if err := jsonconfig.Retry.Apply(&cfg.Retry); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -7,15 +7,22 @@ import (
 	"errors"
 	"net"
 	"net/netip"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc/credentials"
 
+	"github.com/4aleksei/metricscum/internal/common/grpcmetrics/grpcsign"
 	pb "github.com/4aleksei/metricscum/internal/common/grpcmetrics/proto"
 	"github.com/4aleksei/metricscum/internal/common/models"
 	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
 	"github.com/4aleksei/metricscum/internal/common/utils"
 	"github.com/4aleksei/metricscum/internal/server/config"
+	"github.com/4aleksei/metricscum/internal/server/keyring"
+	"github.com/4aleksei/metricscum/internal/server/nonces"
 	"github.com/4aleksei/metricscum/internal/server/service"
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -42,13 +49,7 @@ func (s StreamMultiService) UpdateRequest(ctx context.Context, in *pb.Request) (
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, `%s`, err.Error())
 	} else {
-		k, _ := valuemetric.GetKind(val.MType)
-		response.Value = &pb.Metric{
-			Name:    val.ID,
-			Counter: utils.Setint64(val.Delta),
-			Gauge:   utils.Setfloat64(val.Value),
-			Type:    pb.Metric_Type(k),
-		}
+		response.Value = val.ConvertModelToProto()
 	}
 	return &response, nil
 }
@@ -61,13 +62,7 @@ func (s StreamMultiService) GetMetric(ctx context.Context, in *pb.Request) (*pb.
 	if err != nil {
 		return nil, status.Errorf(codes.NotFound, `%s`, err.Error())
 	} else {
-		k, _ := valuemetric.GetKind(val.MType)
-		response.Value = &pb.Metric{
-			Name:    val.ID,
-			Counter: utils.Setint64(val.Delta),
-			Gauge:   utils.Setfloat64(val.Value),
-			Type:    pb.Metric_Type(k),
-		}
+		response.Value = val.ConvertModelToProto()
 	}
 	return &response, nil
 }
@@ -88,13 +83,7 @@ func (s StreamMultiService) MultiUpdateRequest(ctx context.Context, in *pb.Multi
 	var metrics []*pb.Metric
 
 	for _, val := range resp {
-		k, _ := valuemetric.GetKind(val.MType)
-		metrics = append(metrics, &pb.Metric{
-			Name:    val.ID,
-			Counter: utils.Setint64(val.Delta),
-			Gauge:   utils.Setfloat64(val.Value),
-			Type:    pb.Metric_Type(k),
-		})
+		metrics = append(metrics, val.ConvertModelToProto())
 	}
 	response.Values = metrics
 	return &response, nil
@@ -102,13 +91,8 @@ func (s StreamMultiService) MultiUpdateRequest(ctx context.Context, in *pb.Multi
 
 func (s StreamMultiService) GetMetrics(in *pb.RequestMetrics, srv pb.StreamMultiService_GetMetricsServer) error {
 	err := s.store.GetAllStoreValue(srv.Context(), func(key string, val valuemetric.ValueMetric) error {
-		resp := pb.Metric{
-			Name:    key,
-			Counter: utils.Setint64(val.ValueInt()),
-			Gauge:   utils.Setfloat64(val.ValueFloat()),
-			Type:    pb.Metric_Type(val.GetKind()),
-		}
-		if err := srv.Send(&resp); err != nil {
+		resp := models.ConvertMetricToProto(key, val)
+		if err := srv.Send(resp); err != nil {
 			return err
 		}
 		return nil
@@ -121,27 +105,106 @@ func (s StreamMultiService) GetMetrics(in *pb.RequestMetrics, srv pb.StreamMulti
 	return nil
 }
 
+const defaultHistoryRange = time.Hour
+
+func (s StreamMultiService) GetHistory(ctx context.Context, in *pb.HistoryRequest) (*pb.HistoryResponse, error) {
+	k, _ := valuemetric.GetKindInt(int(in.GetValue().GetType()))
+	valModel := models.Metrics{
+		ID:     in.GetValue().GetName(),
+		MType:  valuemetric.GetKindStr(k),
+		Labels: in.GetValue().GetLabels(),
+	}
+	to := time.Now()
+	if in.GetTo() != 0 {
+		to = time.UnixMilli(in.GetTo())
+	}
+	from := to.Add(-defaultHistoryRange)
+	if in.GetFrom() != 0 {
+		from = time.UnixMilli(in.GetFrom())
+	}
+	if in.GetStep() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "negative step")
+	}
+	points, err := s.store.GetHistory(ctx, valModel, from, to, time.Duration(in.GetStep())*time.Millisecond)
+	if err != nil {
+		if errors.Is(err, service.ErrNoHistory) {
+			return nil, status.Errorf(codes.NotFound, `%s`, err.Error())
+		}
+		return nil, status.Errorf(codes.InvalidArgument, `%s`, err.Error())
+	}
+	response := &pb.HistoryResponse{Points: make([]*pb.HistoryPoint, 0, len(points))}
+	for _, p := range points {
+		response.Points = append(response.Points, &pb.HistoryPoint{
+			Time:    p.Time.UnixMilli(),
+			Counter: utils.Setint64(p.Delta),
+			Gauge:   utils.Setfloat64(p.Value),
+		})
+	}
+	return response, nil
+}
+
+// getTls - nil without certificate, client certificates are verified if client CA is set
 func getTls(cfg *config.Config) (*tls.Config, error) {
-	if cfg.PrivateCertFile == "" || cfg.PrivateKeyFile == "" {
-		return nil, nil
+	return tlsconfig.Server(cfg.PrivateCertFile, cfg.PrivateKeyFile, cfg.ClientCAFile)
+}
+
+// agentFields - common name of client certificate and verified signature key id in call logs
+func agentFields(ctx context.Context) logging.Fields {
+	var fields logging.Fields
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if id := tlsconfig.PeerIdentity(&tlsInfo.State); id != "" {
+				fields = append(fields, "agent", id)
+			}
+		}
 	}
+	if v, ok := ctx.Value(verifiedKeyCtx{}).(*verifiedKey); ok {
+		if id := v.id.Load(); id != nil && *id != "" {
+			fields = append(fields, "key_id", *id)
+		}
+	}
+	return fields
+}
 
-	serverCert, err := tls.LoadX509KeyPair(cfg.PrivateCertFile, cfg.PrivateKeyFile)
-	if err != nil {
-		return nil, err
+type verifiedKeyCtx struct{}
+
+// verifiedKey - key id of request set by HMAC interceptor after verification,
+// logging interceptor runs before and reads it on finish of call
+type verifiedKey struct {
+	id atomic.Pointer[string]
+}
+
+func setVerifiedKey(ctx context.Context, id string) {
+	if v, ok := ctx.Value(verifiedKeyCtx{}).(*verifiedKey); ok {
+		v.id.Store(&id)
 	}
+}
 
-	// Create TLS configuration
-	config := &tls.Config{
-		Certificates: []tls.Certificate{serverCert},
-		ClientAuth:   tls.NoClientCert, // Or tls.RequireAndVerifyClientCert for mTLS
+// UnaryServerKeyID - place for verified key id in call context, must be before logging interceptor
+func UnaryServerKeyID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(context.WithValue(ctx, verifiedKeyCtx{}, new(verifiedKey)), req)
 	}
+}
 
-	// Create gRPC server credentials
-	return config, nil
+// StreamServerKeyID - place for verified key id in stream context, must be before logging interceptor
+func StreamServerKeyID() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = context.WithValue(stream.Context(), verifiedKeyCtx{}, new(verifiedKey))
+		return handler(srv, wrapped)
+	}
 }
 
-func NewgPRC(s *service.HandlerStore, cfg *config.Config, l *zap.Logger) (*StreamMultiService, error) {
+// NewgPRC - gRPC server, requests are checked with keys of keyring, nil keys is keyring of cfg
+func NewgPRC(s *service.HandlerStore, cfg *config.Config, l *zap.Logger, keys *keyring.Keyring) (*StreamMultiService, error) {
+	if keys == nil {
+		var err error
+		if keys, err = keyring.Load(cfg.Key, cfg.KeyringFile); err != nil {
+			l.Debug("gRCP keyring Error: ", zap.Error(err))
+			return nil, err
+		}
+	}
 	listen, err := net.Listen("tcp", cfg.Grcp)
 	if err != nil {
 		l.Debug("gRCP Listen Error: ", zap.Error(err))
@@ -150,6 +213,7 @@ func NewgPRC(s *service.HandlerStore, cfg *config.Config, l *zap.Logger) (*Strea
 
 	opts := []logging.Option{
 		logging.WithLogOnEvents(logging.StartCall, logging.FinishCall),
+		logging.WithFieldsFromContext(agentFields),
 	}
 	var trustedPeers []net.IPNet
 	if cfg.Cidr != "" {
@@ -165,6 +229,10 @@ func NewgPRC(s *service.HandlerStore, cfg *config.Config, l *zap.Logger) (*Strea
 	optsMy := []Option{
 		WithTrustedCidr(trustedPeers),
 	}
+	if keys.Enabled() {
+		optsMy = append(optsMy, WithLogger(l), WithHMAC(keys, cfg.StrictAuth,
+			nonces.New(cfg)))
+	}
 
 	var grpcServer *grpc.Server
 
@@ -176,22 +244,30 @@ func NewgPRC(s *service.HandlerStore, cfg *config.Config, l *zap.Logger) (*Strea
 		tlsCredential := credentials.NewTLS(configTls)
 		grpcServer = grpc.NewServer(grpc.Creds(tlsCredential),
 			grpc.ChainUnaryInterceptor(
+				UnaryServerKeyID(),
 				logging.UnaryServerInterceptor(InterceptorLogger(l), opts...),
 				UnaryServerBlock(optsMy...),
+				UnaryServerHMAC(optsMy...),
 			),
 			grpc.ChainStreamInterceptor(
+				StreamServerKeyID(),
 				logging.StreamServerInterceptor(InterceptorLogger(l), opts...),
 				StreamServerBlock(optsMy...),
+				StreamServerHMAC(optsMy...),
 			),
 		)
 	} else {
 		grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(
+			UnaryServerKeyID(),
 			logging.UnaryServerInterceptor(InterceptorLogger(l), opts...),
 			UnaryServerBlock(optsMy...),
+			UnaryServerHMAC(optsMy...),
 		),
 			grpc.ChainStreamInterceptor(
+				StreamServerKeyID(),
 				logging.StreamServerInterceptor(InterceptorLogger(l), opts...),
 				StreamServerBlock(optsMy...),
+				StreamServerHMAC(optsMy...),
 			),
 		)
 	}
@@ -259,6 +335,10 @@ var (
 
 type options struct {
 	trustedCidr []net.IPNet
+	keys        *keyring.Keyring
+	strict      bool
+	nonces      *nonces.Cache
+	l           *zap.Logger
 }
 
 type Option func(*options)
@@ -277,6 +357,103 @@ func WithTrustedCidr(peers []net.IPNet) Option {
 	}
 }
 
+// WithHMAC - verify signature of requests in metadata with keys of keyring, unsigned requests are rejected if strict
+func WithHMAC(keys *keyring.Keyring, strict bool, cache *nonces.Cache) Option {
+	return func(o *options) {
+		o.keys = keys
+		o.strict = strict
+		o.nonces = cache
+	}
+}
+
+// verify - signature, timestamp and nonce of request, status error on reject
+func (o *options) verify(ctx context.Context, method string, req any) error {
+	timestamp, nonce, err := grpcsign.Verify(ctx, o.lookup, method, req)
+	switch {
+	case errors.Is(err, grpcsign.ErrUnsigned):
+		if o.strict {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil
+	case err != nil:
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	err = o.nonces.Check(timestamp, nonce, time.Now())
+	switch {
+	case err == nil:
+		setVerifiedKey(ctx, grpcsign.KeyID(ctx))
+		return nil
+	case errors.Is(err, nonces.ErrStale):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, nonces.ErrReplayed):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, nonces.ErrFull):
+		if o.l != nil {
+			o.l.Warn("Nonce cache is full, signed gRPC request rejected", zap.String("method", method))
+		}
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// WithLogger - logger of interceptors
+func WithLogger(l *zap.Logger) Option {
+	return func(o *options) {
+		o.l = l
+	}
+}
+
+// lookup - secret of key id, use of deprecated key is logged
+func (o *options) lookup(keyID string) ([]byte, error) {
+	key, err := o.keys.Lookup(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Status == keyring.StatusDeprecated && o.l != nil {
+		o.l.Warn("gRPC request signed with deprecated key", zap.String("key_id", keyID))
+	}
+	return []byte(key.Secret), nil
+}
+
+// UnaryServerHMAC - checks signature of request, see WithHMAC
+func UnaryServerHMAC(opts ...Option) grpc.UnaryServerInterceptor {
+	o := evaluateOpts(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if o.keys == nil || !o.keys.Enabled() {
+			return handler(ctx, req)
+		}
+		if err := o.verify(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerHMAC - checks signature of request message of server streaming call, see WithHMAC
+func StreamServerHMAC(opts ...Option) grpc.StreamServerInterceptor {
+	o := evaluateOpts(opts)
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.keys == nil || !o.keys.Enabled() || info.IsClientStream {
+			return handler(srv, stream)
+		}
+		return handler(srv, &verifiedStream{ServerStream: stream, o: o, method: info.FullMethod})
+	}
+}
+
+// verifiedStream - request message is verified when received
+type verifiedStream struct {
+	grpc.ServerStream
+	o      *options
+	method string
+}
+
+func (s *verifiedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.o.verify(s.Context(), s.method, m)
+}
+
 func UnaryServerBlock(opts ...Option) grpc.UnaryServerInterceptor {
 	o := evaluateOpts(opts)
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
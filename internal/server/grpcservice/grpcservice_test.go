@@ -2,20 +2,34 @@ package grpcmetrics
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/4aleksei/metricscum/internal/common/grpcmetrics/grpcsign"
 	pb "github.com/4aleksei/metricscum/internal/common/grpcmetrics/proto"
+	"github.com/4aleksei/metricscum/internal/common/repository/history"
 	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig/tlstest"
 	"github.com/4aleksei/metricscum/internal/common/utils"
+	"github.com/4aleksei/metricscum/internal/server/config"
+	"github.com/4aleksei/metricscum/internal/server/keyring"
+	"github.com/4aleksei/metricscum/internal/server/nonces"
 	"github.com/4aleksei/metricscum/internal/server/service"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
@@ -254,3 +268,232 @@ func TestServerMulti(t *testing.T) {
 	}
 
 }
+
+func TestServerHistory(t *testing.T) {
+	initNew()
+	grpcServer := grpc.NewServer()
+	store := service.NewHandlerStore(memstorage.NewStore(), service.WithHistory(history.NewRing(10)))
+
+	pb.RegisterStreamMultiServiceServer(grpcServer, StreamMultiService{store: store})
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := pb.NewStreamMultiServiceClient(conn)
+
+	for _, v := range []float64{1.5, 2.5} {
+		val := ValueName{name: "testGauge", value: *valuemetric.ConvertToFloatValueMetric(v)}
+		_, err := client.UpdateRequest(context.Background(), &pb.Request{Value: val.getMetric()})
+		assert.NoError(t, err)
+	}
+
+	req := &pb.Metric{Name: "testGauge", Type: pb.Metric_GAUGE}
+	resp, err := client.GetHistory(context.Background(), &pb.HistoryRequest{Value: req})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.GetPoints(), 2) {
+		assert.Equal(t, 1.5, resp.GetPoints()[0].GetGauge())
+		assert.Equal(t, 2.5, resp.GetPoints()[1].GetGauge())
+	}
+
+	_, err = client.GetHistory(context.Background(), &pb.HistoryRequest{Value: req, Step: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerHMAC(t *testing.T) {
+	key := []byte("secret")
+	initNew()
+	opts := []Option{WithHMAC(keyring.New(string(key)), true, nonces.NewCache(100, time.Minute))}
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerHMAC(opts...)),
+		grpc.ChainStreamInterceptor(StreamServerHMAC(opts...)),
+	)
+	store := service.NewHandlerStore(memstorage.NewStore())
+
+	pb.RegisterStreamMultiServiceServer(grpcServer, StreamMultiService{store: store})
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := pb.NewStreamMultiServiceClient(conn)
+	val := ValueName{name: "testCounter1", value: *valuemetric.ConvertToIntValueMetric(100)}
+	req := &pb.Request{Value: val.getMetric()}
+	update := pb.StreamMultiService_UpdateRequest_FullMethodName
+
+	_, err = client.UpdateRequest(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "unsigned")
+
+	ctx, err := grpcsign.SignContext(context.Background(), "", []byte("other"), update, req)
+	require.NoError(t, err)
+	_, err = client.UpdateRequest(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "other key")
+
+	ctx, err = grpcsign.SignContext(context.Background(), "", key, update, req)
+	require.NoError(t, err)
+	_, err = client.UpdateRequest(ctx, req)
+	assert.NoError(t, err)
+	_, err = client.UpdateRequest(ctx, req)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err), "replayed")
+
+	_, err = client.UpdateRequest(ctx, &pb.Request{Value: (&ValueName{name: "testCounter2",
+		value: *valuemetric.ConvertToIntValueMetric(1)}).getMetric()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "other request")
+
+	signed := pb.NewStreamMultiServiceClient(mustSignedConn(t, "", key))
+	resp, err := signed.GetMetric(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), resp.GetValue().GetCounter(), "counter updated once")
+
+	stream, err := client.GetMetrics(context.Background(), &pb.RequestMetrics{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "unsigned stream")
+
+	metricsReq := &pb.RequestMetrics{}
+	ctx, err = grpcsign.SignContext(context.Background(), "", key, pb.StreamMultiService_GetMetrics_FullMethodName, metricsReq)
+	require.NoError(t, err)
+	stream, err = client.GetMetrics(ctx, metricsReq)
+	require.NoError(t, err)
+	m, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "testCounter1", m.GetName())
+}
+
+func mustSignedConn(t *testing.T, keyID string, key []byte) *grpc.ClientConn {
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(bufDialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(grpcsign.UnaryClientInterceptor(keyID, key)))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServerKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys": [
+		{"id": "agent-1", "secret": "s1"},
+		{"id": "agent-2", "secret": "s2", "status": "deprecated"},
+		{"id": "agent-3", "secret": "s3", "status": "revoked"}]}`), 0o600))
+	keys, err := keyring.Load("", path)
+	require.NoError(t, err)
+
+	initNew()
+	opts := []Option{WithHMAC(keys, true, nonces.NewCache(100, time.Minute))}
+	// logged - fields of call log, taken on finish of call as logging interceptor does
+	logged := make(chan logging.Fields, 1)
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(UnaryServerKeyID(),
+		func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			resp, err := handler(ctx, req)
+			logged <- agentFields(ctx)
+			return resp, err
+		},
+		UnaryServerHMAC(opts...)))
+	store := service.NewHandlerStore(memstorage.NewStore())
+
+	pb.RegisterStreamMultiServiceServer(grpcServer, StreamMultiService{store: store})
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	val := ValueName{name: "testCounter1", value: *valuemetric.ConvertToIntValueMetric(1)}
+	req := &pb.Request{Value: val.getMetric()}
+
+	tests := []struct {
+		name, keyID, key string
+		want             codes.Code
+		wantFields       logging.Fields
+	}{
+		{name: "active", keyID: "agent-1", key: "s1", want: codes.OK, wantFields: logging.Fields{"key_id", "agent-1"}},
+		{name: "deprecated", keyID: "agent-2", key: "s2", want: codes.OK, wantFields: logging.Fields{"key_id", "agent-2"}},
+		{name: "revoked", keyID: "agent-3", key: "s3", want: codes.Unauthenticated},
+		{name: "unknown", keyID: "agent-4", key: "s1", want: codes.Unauthenticated},
+		{name: "secret of other key", keyID: "agent-1", key: "s2", want: codes.Unauthenticated},
+		{name: "without key id", key: "s1", want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := pb.NewStreamMultiServiceClient(mustSignedConn(t, tt.keyID, []byte(tt.key)))
+			_, err := client.UpdateRequest(context.Background(), req)
+			assert.Equal(t, tt.want, status.Code(err))
+			assert.Equal(t, tt.wantFields, <-logged, "only verified key id is logged")
+		})
+	}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	f := tlstest.Generate(t)
+	serverTLS, err := getTls(&config.Config{PrivateCertFile: f.ServerCert, PrivateKeyFile: f.ServerKey, ClientCAFile: f.CA})
+	require.NoError(t, err)
+
+	initNew()
+	agents := make(chan logging.Fields, 1)
+	grpcServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLS)),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			agents <- agentFields(ctx)
+			return handler(ctx, req)
+		}))
+	store := service.NewHandlerStore(memstorage.NewStore())
+
+	pb.RegisterStreamMultiServiceServer(grpcServer, StreamMultiService{store: store})
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	dial := func(t *testing.T, certFile, keyFile string) pb.StreamMultiServiceClient {
+		clientTLS, err := tlsconfig.Client(f.CA, certFile, keyFile)
+		require.NoError(t, err)
+		clientTLS.ServerName = "localhost"
+		conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(bufDialer),
+			grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return pb.NewStreamMultiServiceClient(conn)
+	}
+	val := ValueName{name: "testCounter1", value: *valuemetric.ConvertToIntValueMetric(1)}
+	req := &pb.Request{Value: val.getMetric()}
+
+	_, err = dial(t, f.ClientCert, f.ClientKey).UpdateRequest(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, logging.Fields{"agent", tlstest.ClientName}, <-agents)
+
+	_, err = dial(t, "", "").UpdateRequest(context.Background(), req)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "without client certificate")
+
+	_, err = dial(t, f.OtherCert, f.OtherKey).UpdateRequest(context.Background(), req)
+	assert.Equal(t, codes.Unavailable, status.Code(err), "client certificate of other CA")
+
+	// mutual TLS of gRPC server with plain HTTP server
+	serverTLS, err = getTls(&config.Config{HTTPS: false, PrivateCertFile: f.ServerCert, PrivateKeyFile: f.ServerKey, ClientCAFile: f.CA})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, serverTLS.ClientAuth)
+
+	_, err = getTls(&config.Config{ClientCAFile: f.CA})
+	assert.ErrorIs(t, err, tlsconfig.ErrServerCert)
+}
@@ -3,25 +3,37 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 
 	"crypto/rsa"
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/4aleksei/metricscum/internal/common/middleware/aescoder"
 	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
 	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/promtext"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
+	"github.com/4aleksei/metricscum/internal/server/alerts"
 	"github.com/4aleksei/metricscum/internal/server/config"
 	"github.com/4aleksei/metricscum/internal/server/handlers/middleware/httpaes"
 	"github.com/4aleksei/metricscum/internal/server/handlers/middleware/httpgzip"
 	"github.com/4aleksei/metricscum/internal/server/handlers/middleware/httphmacsha256"
 	"github.com/4aleksei/metricscum/internal/server/handlers/middleware/httplogs"
+	"github.com/4aleksei/metricscum/internal/server/influx"
+	"github.com/4aleksei/metricscum/internal/server/keyring"
+	"github.com/4aleksei/metricscum/internal/server/nonces"
 	"github.com/4aleksei/metricscum/internal/server/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -29,32 +41,71 @@ import (
 )
 
 type (
+	alertsSource interface {
+		Alerts() []alerts.Alert
+	}
+
 	HandlersServer struct {
 		store       *service.HandlerStore
 		cfg         *config.Config
 		Srv         *http.Server
 		l           *zap.Logger
-		key         string
+		keys        *keyring.Keyring
 		privateKey  *rsa.PrivateKey
 		trustedCidr *net.IPNet
+		alerts      alertsSource
+		nonces      *nonces.Cache
 	}
+
+	Option func(*HandlersServer)
 )
 
+// WithAlerts - serve current alerts at GET /alerts
+func WithAlerts(a alertsSource) Option {
+	return func(h *HandlersServer) {
+		h.alerts = a
+	}
+}
+
+// WithKeyring - signature keys shared with gRPC server, default is keyring of config
+func WithKeyring(k *keyring.Keyring) Option {
+	return func(h *HandlersServer) {
+		h.keys = k
+	}
+}
+
 const (
 	textHTMLContent        string = "text/html"
 	applicationJSONContent string = "application/json"
 )
 
+var (
+	// ErrHTTPSConfig - https without certificate and key
+	ErrHTTPSConfig = errors.New("https requires crypto-cert and crypto-key")
+)
+
 // NewServer - server constructor
 // store : store object
 // cfg : config
 // l :  logger realization
-func NewServer(store *service.HandlerStore, cfg *config.Config, l *zap.Logger) (*HandlersServer, error) {
+// opts : optional parts of server
+func NewServer(store *service.HandlerStore, cfg *config.Config, l *zap.Logger, opts ...Option) (*HandlersServer, error) {
 	h := new(HandlersServer)
 	h.store = store
 	h.cfg = cfg
-	h.key = h.cfg.Key
 	h.l = l
+	for _, o := range opts {
+		o(h)
+	}
+
+	if h.keys == nil {
+		keys, err := keyring.Load(cfg.Key, cfg.KeyringFile)
+		if err != nil {
+			h.l.Debug("HTTP server Load keyring error: ", zap.Error(err))
+			return nil, err
+		}
+		h.keys = keys
+	}
 
 	if cfg.Cidr != "" {
 		var err error
@@ -74,21 +125,48 @@ func NewServer(store *service.HandlerStore, cfg *config.Config, l *zap.Logger) (
 		}
 	}
 
+	tlsConfig, err := h.getTLS()
+	if err != nil {
+		h.l.Debug("HTTP server TLS config error: ", zap.Error(err))
+		return nil, err
+	}
+
 	h.Srv = &http.Server{
 		Addr:              h.cfg.Address,
 		Handler:           h.newRouter(),
 		ReadHeaderTimeout: 2 * time.Second,
+		TLSConfig:         tlsConfig,
 	}
 	return h, nil
 }
 
+// getTLS - nil for plain HTTP, client certificates are verified if client CA is set.
+// Client CA of plain HTTP is used by gRPC server only
+func (h *HandlersServer) getTLS() (*tls.Config, error) {
+	if !h.cfg.HTTPS {
+		if h.cfg.ClientCAFile != "" {
+			h.l.Info("Client certificates are not verified by plain HTTP server")
+		}
+		return nil, nil
+	}
+	tlsConfig, err := tlsconfig.Server(h.cfg.PrivateCertFile, h.cfg.PrivateKeyFile, h.cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return nil, ErrHTTPSConfig
+	}
+	return tlsConfig, nil
+}
+
 func (h *HandlersServer) withLogging(next http.Handler) http.Handler {
 	logFn := func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		responseData := httplogs.NewResponseData()
 		lw := httplogs.NewResponseWriter(responseData, w)
 
-		next.ServeHTTP(lw, r)
+		var keyID string
+		next.ServeHTTP(lw, r.WithContext(context.WithValue(r.Context(), verifiedKeyCtx{}, &keyID)))
 		duration := time.Since(start)
 		h.l.Info("got incoming HTTP request",
 			zap.String("uri", r.RequestURI),
@@ -98,6 +176,8 @@ func (h *HandlersServer) withLogging(next http.Handler) http.Handler {
 			zap.String("Accept", r.Header.Get("Accept")),
 			zap.String("AES-256", r.Header.Get("AES-256")),
 			zap.String("X-Real-IP", r.Header.Get("X-Real-IP")),
+			zap.String("key_id", keyID),
+			zap.String("agent", tlsconfig.PeerIdentity(r.TLS)),
 			zap.String("ContentType", r.Header.Get("Content-Type")),
 			zap.Duration("duration", duration),
 			zap.Int("resp_status", responseData.GetStatus()),
@@ -106,10 +186,24 @@ func (h *HandlersServer) withLogging(next http.Handler) http.Handler {
 	return http.HandlerFunc(logFn)
 }
 
+type verifiedKeyCtx struct{}
+
+// setVerifiedKey - key id of request after signature check, logging middleware runs before and logs it on finish
+func setVerifiedKey(req *http.Request, id string) {
+	if v, ok := req.Context().Value(verifiedKeyCtx{}).(*string); ok {
+		*v = id
+	}
+}
+
 // Serve - start server in go-routine
 func (h *HandlersServer) Serve() {
 	go func() {
-		if err := h.Srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		listen := h.Srv.ListenAndServe
+		if h.Srv.TLSConfig != nil {
+			// certificate is in TLSConfig
+			listen = func() error { return h.Srv.ListenAndServeTLS("", "") }
+		}
+		if err := listen(); !errors.Is(err, http.ErrServerClosed) {
 			h.l.Debug("HTTP server error: ", zap.Error(err))
 		}
 		h.l.Info("Stopped serving new connections.")
@@ -118,9 +212,14 @@ func (h *HandlersServer) Serve() {
 
 func (h *HandlersServer) aesMiddleware(next http.Handler) http.Handler {
 	aesfn := func(w http.ResponseWriter, r *http.Request) {
-		aesEncoding := r.Header.Get("AES-256")
+		newReader := httpaes.NewEnvelopeReader
+		aesEncoding := r.Header.Get(aescoder.HeaderEnvelope)
+		if aesEncoding == "" {
+			newReader = httpaes.NewAesReader
+			aesEncoding = r.Header.Get(aescoder.HeaderLegacy)
+		}
 		if aesEncoding != "" {
-			ar, err := httpaes.NewAesReader(r.Body, h.privateKey, aesEncoding)
+			ar, err := newReader(r.Body, h.privateKey, aesEncoding)
 			if err != nil {
 				h.l.Debug("cannot decode aes", zap.Error(err))
 				w.WriteHeader(http.StatusInternalServerError)
@@ -163,11 +262,29 @@ func (h *HandlersServer) gzipMiddleware(next http.Handler) http.Handler {
 
 func (h *HandlersServer) hmacsha256Middleware(next http.Handler) http.Handler {
 	hmacsha256fn := func(w http.ResponseWriter, r *http.Request) {
-		ow := httphmacsha256.NewWriter(w, []byte(h.cfg.Key))
+		keyID := r.Header.Get(hmacsha256.HeaderKeyID)
+		key, err := h.keys.Lookup(keyID)
+		if err != nil && r.Header.Get(hmacsha256.HeaderHash) != "" {
+			h.l.Debug("Signature key rejected", zap.String("key_id", keyID), zap.Error(err))
+			http.Error(w, "Unknown key!", http.StatusUnauthorized)
+			return
+		}
+		if key.Status == keyring.StatusDeprecated {
+			h.l.Warn("HTTP request signed with deprecated key", zap.String("key_id", keyID))
+		}
+		if err == nil {
+			// response is signed with key of request
+			w = httphmacsha256.NewWriter(w, []byte(key.Secret))
+		}
 
-		r.Body = hmacsha256.NewReader(r.Body, []byte(h.cfg.Key))
+		hr := hmacsha256.NewReader(r.Body, []byte(key.Secret))
+		timestamp, nonce := r.Header.Get(hmacsha256.HeaderTimestamp), r.Header.Get(hmacsha256.HeaderNonce)
+		if timestamp != "" && nonce != "" {
+			hr.Seed(hmacsha256.SignedPrefix(r.Method, r.RequestURI, timestamp, nonce))
+		}
+		r.Body = hr
 
-		next.ServeHTTP(ow, r)
+		next.ServeHTTP(w, r)
 	}
 	return http.HandlerFunc(hmacsha256fn)
 }
@@ -203,7 +320,6 @@ func (h *HandlersServer) newRouter() http.Handler {
 
 	mux.Use(h.withLogging)
 
-
 	if h.trustedCidr != nil {
 		mux.Use(h.trustedCIDRMiddleware)
 	}
@@ -213,7 +329,8 @@ func (h *HandlersServer) newRouter() http.Handler {
 	}
 
 	mux.Use(h.gzipMiddleware)
-	if h.key != "" {
+	if h.keys != nil && h.keys.Enabled() {
+		h.nonces = nonces.New(h.cfg)
 		mux.Use(h.hmacsha256Middleware)
 	}
 
@@ -223,19 +340,30 @@ func (h *HandlersServer) newRouter() http.Handler {
 
 	mux.Post("/update/", h.mainPageJSON)
 	mux.Post("/updates/", h.mainPageJSONs)
+	mux.Post("/write", h.mainPageWrite)
 	mux.Post("/update/{type}/{name}/{value}", h.mainPostPagePlain)
 	mux.Post("/update/{type}/", h.mainPageFoundErrors)
 	mux.Post("/*", h.mainPageError)
 	mux.Get("/value/{type}/{name}", h.mainPageGetPlain)
 	mux.Post("/value/", h.mainPageGetJSON)
 	mux.Get("/ping", h.mainPingDB)
+	mux.Get("/metrics", h.mainPageMetrics)
+	mux.Get("/history/{type}/{name}", h.mainPageHistory)
+	mux.Get("/alerts", h.mainPageAlerts)
 	mux.Get("/", h.mainPage)
 
 	return mux
 }
 
+// checkHmacSha256 - signature of whole body, method, target, timestamp and nonce.
+// Unsigned request is accepted only if strict auth is off
 func (h *HandlersServer) checkHmacSha256(res http.ResponseWriter, req *http.Request) bool {
-	if h.key != "" {
+	if h.keys != nil && h.keys.Enabled() {
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			h.l.Debug("error read request", zap.Error(err))
+			http.Error(res, "Bad request!", http.StatusBadRequest)
+			return false
+		}
 		sig, err := hmacsha256.GetSig(req.Body)
 		if err != nil {
 			h.l.Error("error read request", zap.Error(err))
@@ -243,8 +371,13 @@ func (h *HandlersServer) checkHmacSha256(res http.ResponseWriter, req *http.Requ
 			return false
 		}
 
-		sigBody := req.Header.Get("HashSHA256")
-		if sigBody == "" { // accept data if no hash in body, but with secret key in app parameters . strange
+		sigBody := req.Header.Get(hmacsha256.HeaderHash)
+		if sigBody == "" {
+			if h.cfg.StrictAuth {
+				http.Error(res, "Unsigned request!", http.StatusUnauthorized)
+				return false
+			}
+			// legacy agents may send data without hash
 			return true
 		}
 		sigString := hex.EncodeToString(sig)
@@ -255,10 +388,46 @@ func (h *HandlersServer) checkHmacSha256(res http.ResponseWriter, req *http.Requ
 		} else {
 			h.l.Debug("Signature in body accepted")
 		}
+		if !h.checkReplay(res, req) {
+			return false
+		}
+		setVerifiedKey(req, req.Header.Get(hmacsha256.HeaderKeyID))
+		return true
 	}
 	return true
 }
 
+// checkReplay - signed timestamp must be within replay window and nonce is accepted once.
+// Request without timestamp and nonce is accepted as signed by body only if strict auth is off
+func (h *HandlersServer) checkReplay(res http.ResponseWriter, req *http.Request) bool {
+	timestamp, nonce := req.Header.Get(hmacsha256.HeaderTimestamp), req.Header.Get(hmacsha256.HeaderNonce)
+	if timestamp == "" && nonce == "" {
+		if h.cfg.StrictAuth {
+			http.Error(res, "Unsigned request!", http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	err := h.nonces.Check(timestamp, nonce, time.Now())
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, nonces.ErrStale):
+		h.l.Debug("Signed request timestamp out of window", zap.String("timestamp", timestamp))
+		http.Error(res, "Stale request!", http.StatusUnauthorized)
+	case errors.Is(err, nonces.ErrReplayed):
+		h.l.Debug("Replayed signed request", zap.String("nonce", nonce))
+		http.Error(res, "Replayed request!", http.StatusConflict)
+	case errors.Is(err, nonces.ErrFull):
+		h.l.Warn("Nonce cache is full, signed request rejected", zap.String("nonce", nonce))
+		http.Error(res, "Server is busy!", http.StatusServiceUnavailable)
+	default:
+		h.l.Debug("Bad signed request nonce", zap.Error(err))
+		http.Error(res, "Bad request!", http.StatusBadRequest)
+	}
+	return false
+}
+
 // едпоинт  POST /update/
 func (h *HandlersServer) mainPageJSON(res http.ResponseWriter, req *http.Request) {
 	if req.Header.Get("Content-Type") != applicationJSONContent {
@@ -342,6 +511,29 @@ func (h *HandlersServer) mainPageJSONs(res http.ResponseWriter, req *http.Reques
 	}
 }
 
+// едпоинт  POST /write - InfluxDB line protocol
+func (h *HandlersServer) mainPageWrite(res http.ResponseWriter, req *http.Request) {
+	vals, err := influx.Parse(req.Body)
+	if err != nil {
+		h.l.Debug("cannot decode line protocol", zap.Error(err))
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkHmacSha256(res, req) {
+		return
+	}
+
+	if len(vals) > 0 {
+		if _, err = h.store.SetValueSModel(req.Context(), vals); err != nil {
+			h.l.Debug("cannot store line protocol values", zap.Error(err))
+			http.Error(res, "Invalid request!", http.StatusBadRequest)
+			return
+		}
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
 func (h *HandlersServer) mainPageGetJSON(res http.ResponseWriter, req *http.Request) {
 	if req.Header.Get("Content-Type") != applicationJSONContent {
 		http.Error(res, "Bad type!", http.StatusBadRequest)
@@ -400,6 +592,9 @@ func (h *HandlersServer) mainPageFoundErrors(res http.ResponseWriter, req *http.
 }
 
 func (h *HandlersServer) mainPostPagePlain(res http.ResponseWriter, req *http.Request) {
+	if !h.checkHmacSha256(res, req) {
+		return
+	}
 	typeVal := chi.URLParam(req, "type")
 	name := chi.URLParam(req, "name")
 	value := chi.URLParam(req, "value")
@@ -432,6 +627,9 @@ func (h *HandlersServer) mainPostPagePlain(res http.ResponseWriter, req *http.Re
 }
 
 func (h *HandlersServer) mainPageGetPlain(res http.ResponseWriter, req *http.Request) {
+	if !h.checkHmacSha256(res, req) {
+		return
+	}
 	typeVal := chi.URLParam(req, "type")
 	name := chi.URLParam(req, "name")
 	if typeVal == "" {
@@ -482,6 +680,119 @@ func (h *HandlersServer) mainPingDB(res http.ResponseWriter, req *http.Request)
 	res.WriteHeader(http.StatusOK)
 }
 
+// едпоинт  GET /metrics - Prometheus text exposition
+func (h *HandlersServer) mainPageMetrics(res http.ResponseWriter, req *http.Request) {
+	var samples []promtext.Sample
+	err := h.store.GetAllStoreValue(req.Context(), func(key string, val valuemetric.ValueMetric) error {
+		samples = append(samples, promtext.Sample{Name: key, Value: val})
+		return nil
+	})
+	if err != nil {
+		h.l.Debug("error read metrics", zap.Error(err))
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	err = promtext.Encode(&buf, samples, promtext.OnCollision(func(id, family string) {
+		h.l.Warn("metric skipped, name collides with other metric family", zap.String("id", id), zap.String("family", family))
+	}))
+	if err != nil {
+		h.l.Debug("error encoding response", zap.Error(err))
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Add("Content-Type", promtext.ContentType)
+	res.WriteHeader(http.StatusOK)
+	if _, err := res.Write(buf.Bytes()); err != nil {
+		h.l.Debug("error writing response", zap.Error(err))
+		return
+	}
+}
+
+const defaultHistoryRange = time.Hour
+
+// parseTime - RFC3339 or unix seconds, empty string is def
+func parseTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseStep - duration like 30s or seconds
+func parseStep(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// едпоинт  GET /history/{type}/{name}?from=&to=&step= - values of series in time range,
+// name may contain labels name{k="v"}, from and to are RFC3339 or unix seconds, default is last hour
+func (h *HandlersServer) mainPageHistory(res http.ResponseWriter, req *http.Request) {
+	typeVal := chi.URLParam(req, "type")
+	name := chi.URLParam(req, "name")
+	if name == "" {
+		http.Error(res, "Bad name!", http.StatusNotFound)
+		return
+	}
+	q := req.URL.Query()
+	to, errT := parseTime(q.Get("to"), time.Now())
+	from, errF := parseTime(q.Get("from"), to.Add(-defaultHistoryRange))
+	step, errS := parseStep(q.Get("step"))
+	if errT != nil || errF != nil || errS != nil || step < 0 {
+		http.Error(res, "Bad request!", http.StatusBadRequest)
+		return
+	}
+	var valModel models.Metrics
+	valModel.ID, valModel.Labels = models.ParseSeriesKey(name)
+	valModel.MType = typeVal
+
+	points, err := h.store.GetHistory(req.Context(), valModel, from, to, step)
+	if err != nil {
+		h.l.Debug("error get history", zap.Error(err))
+		if errors.Is(err, service.ErrNoHistory) {
+			http.Error(res, "Not found!", http.StatusNotFound)
+			return
+		}
+		http.Error(res, "Bad request!", http.StatusBadRequest)
+		return
+	}
+	res.Header().Add("Content-Type", applicationJSONContent)
+	res.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(res).Encode(points); err != nil {
+		h.l.Debug("error encoding response", zap.Error(err))
+		return
+	}
+}
+
+// едпоинт  GET /alerts - state of all alerting rules, ?state=firing selects alerts by state
+func (h *HandlersServer) mainPageAlerts(res http.ResponseWriter, req *http.Request) {
+	if h.alerts == nil {
+		http.Error(res, "Not found!", http.StatusNotFound)
+		return
+	}
+	state := req.URL.Query().Get("state")
+	list := make([]alerts.Alert, 0)
+	for _, a := range h.alerts.Alerts() {
+		if state == "" || string(a.State) == state {
+			list = append(list, a)
+		}
+	}
+	res.Header().Add("Content-Type", applicationJSONContent)
+	res.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		h.l.Debug("error encoding response", zap.Error(err))
+		return
+	}
+}
+
 func (h *HandlersServer) mainPage(res http.ResponseWriter, req *http.Request) {
 	if req.URL.String() == "" || req.URL.String() == "/" {
 		val, err := h.store.GetAllStore(req.Context())
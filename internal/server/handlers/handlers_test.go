@@ -4,20 +4,40 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/4aleksei/metricscum/internal/common/logger"
+	"github.com/4aleksei/metricscum/internal/common/middleware/aescoder"
+	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig"
+	"github.com/4aleksei/metricscum/internal/common/tlsconfig/tlstest"
+	"github.com/4aleksei/metricscum/internal/server/config"
+	"github.com/4aleksei/metricscum/internal/server/keyring"
 
+	"github.com/4aleksei/metricscum/internal/server/alerts"
 	"github.com/4aleksei/metricscum/internal/server/service"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/4aleksei/metricscum/internal/common/repository/history"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
 
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func testRequest(t *testing.T, ts *httptest.Server, method,
@@ -27,7 +47,7 @@ func testRequest(t *testing.T, ts *httptest.Server, method,
 		gz := gzip.NewWriter(&buf)
 		_, err := gz.Write([]byte(body))
 		require.NoError(t, err)
-		gz.Flush()
+		require.NoError(t, gz.Close())
 	} else {
 		buf.WriteString(body)
 	}
@@ -86,6 +106,7 @@ func Test_handlers_mainHTTPPlain(t *testing.T) {
 		{name: "Test No10", req: request{method: http.MethodPost, url: "/update/counter/testreal/10"}, want: want{statusCode: http.StatusOK, contentType: "text/plain; charset=utf-8"}},
 		{name: "Test No11", req: request{method: http.MethodGet, url: "/value/counter/testreal"}, want: want{statusCode: http.StatusOK, contentType: "text/plain; charset=utf-8", body: "10"}},
 		{name: "Test No12", req: request{method: http.MethodGet, url: "/ping"}, want: want{statusCode: http.StatusOK, contentType: "text/plain; charset=utf-8", body: ""}},
+		{name: "Test No13 labels in name", req: request{method: http.MethodPost, url: "/update/counter/m%7Bcpu=%221%22%7D/5"}, want: want{statusCode: http.StatusBadRequest, contentType: "text/plain; charset=utf-8"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -137,6 +158,7 @@ func Test_handlers_mainHTTPJSON(t *testing.T) {
 		{name: "JSON Test No6", req: request{method: http.MethodPost, url: "/value/", body: " {\"id\":\"test5\" , \"type\":\"gauge\" }  ", contentType: "application/json", contentEnc: "gzip"}, want: want{statusCode: http.StatusOK, contentType: "application/json", body: "{\"id\":\"test5\" , \"type\":\"gauge\" , \"value\": 10.10 } ", contentEnc: "gzip"}},
 		{name: "JSON Test No7", req: request{method: http.MethodPost, url: "/value/", body: " {\"id\":\"test5\" , \"type\":\"gauge\" }  ", contentType: "application/json"}, want: want{statusCode: http.StatusOK, contentType: "application/json", body: "{\"id\":\"test5\" , \"type\":\"gauge\" , \"value\": 10.10 } "}},
 		{name: "JSON Test No8", req: request{method: http.MethodPost, url: "/updates/", body: "[ {\"id\":\"test6\" , \"type\":\"gauge\" , \"value\": 20.20 } ,  {\"id\":\"test7\" , \"type\":\"gauge\" ,  \"value\": 20.30 } ] ", contentType: "application/json"}, want: want{statusCode: http.StatusOK, contentType: "application/json", body: "[ {\"id\":\"test6\" , \"type\":\"gauge\" , \"value\": 20.20 } ,  {\"id\":\"test7\" , \"type\":\"gauge\" , \"value\": 20.30 } ]"}},
+		{name: "JSON Test No9 braces in id", req: request{method: http.MethodPost, url: "/update/", body: " {\"id\":\"m{cpu=\\\"1\\\"}\" , \"type\":\"counter\" , \"delta\": 1 }  ", contentType: "application/json"}, want: want{statusCode: http.StatusBadRequest, contentType: "", body: ""}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -162,3 +184,505 @@ func Test_handlers_mainHTTPJSON(t *testing.T) {
 		})
 	}
 }
+
+func Test_handlers_mainPageMetrics(t *testing.T) {
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	var errL error
+	h.l, errL = logger.NewLog("debug")
+	require.NoError(t, errL)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	resp, _ := testRequest(t, ts, http.MethodPost, "/update/counter/PollCount/5", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testRequest(t, ts, http.MethodPost, "/update/gauge/Alloc/10.5", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	tests := []struct {
+		name       string
+		contentEnc string
+	}{
+		{name: "Metrics plain"},
+		{name: "Metrics gzip", contentEnc: "gzip"},
+	}
+	want := "# TYPE Alloc gauge\nAlloc 10.5\n# TYPE PollCount counter\nPollCount 5\n"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, respBody := testRequest(t, ts, http.MethodGet, "/metrics", "", "", tt.contentEnc)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+			if tt.contentEnc != "" {
+				assert.Equal(t, tt.contentEnc, resp.Header.Get("Content-Encoding"))
+				body, err := gzip.NewReader(strings.NewReader(respBody))
+				require.NoError(t, err)
+				buf, errR := io.ReadAll(body)
+				require.NoError(t, errR)
+				respBody = string(buf)
+			}
+			assert.Equal(t, want, respBody)
+		})
+	}
+}
+
+func Test_handlers_mainPageHistory(t *testing.T) {
+	store := service.NewHandlerStore(memstorage.NewStore(), service.WithHistory(history.NewRing(10)))
+	h := new(HandlersServer)
+	h.store = store
+	var errL error
+	h.l, errL = logger.NewLog("debug")
+	require.NoError(t, errL)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	resp, _ := testRequest(t, ts, http.MethodPost, "/update/counter/PollCount/5", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = testRequest(t, ts, http.MethodPost, "/update/counter/PollCount/2", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	tests := []struct {
+		name       string
+		path       string
+		want       string
+		statusCode int
+	}{
+		{name: "History raw", path: "/history/counter/PollCount", want: "[5,7]", statusCode: http.StatusOK},
+		{name: "History step", path: "/history/counter/PollCount?step=3600", want: "[7]", statusCode: http.StatusOK},
+		{name: "History empty", path: "/history/gauge/PollCount", want: "[]", statusCode: http.StatusOK},
+		{name: "History bad step", path: "/history/counter/PollCount?step=x", statusCode: http.StatusBadRequest},
+		{name: "History bad type", path: "/history/histogram/PollCount", statusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, respBody := testRequest(t, ts, http.MethodGet, tt.path, "", "", "")
+			assert.Equal(t, tt.statusCode, resp.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			var points []history.Point
+			require.NoError(t, json.Unmarshal([]byte(respBody), &points))
+			deltas := make([]int64, 0, len(points))
+			for _, p := range points {
+				require.NotNil(t, p.Delta)
+				deltas = append(deltas, *p.Delta)
+			}
+			got, _ := json.Marshal(deltas)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func Test_handlers_mainPageAlerts(t *testing.T) {
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	var errL error
+	h.l, errL = logger.NewLog("debug")
+	require.NoError(t, errL)
+
+	ts := httptest.NewServer(h.newRouter())
+	resp, _ := testRequest(t, ts, http.MethodGet, "/alerts", "", "", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	ts.Close()
+
+	engine, err := alerts.NewEngine([]alerts.Rule{
+		{Name: "HighAlloc", Expr: "Alloc > 1KB"},
+		{Name: "LowAlloc", Expr: "Alloc < 1KB"},
+	}, store, time.Second, h.l)
+	require.NoError(t, err)
+	WithAlerts(engine)(h)
+	ts = httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	resp, _ = testRequest(t, ts, http.MethodPost, "/update/gauge/Alloc/2048", "", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, engine.Eval(context.Background(), time.Now()))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "All alerts", path: "/alerts", want: `[{"name":"HighAlloc","expr":"Alloc > 1KB","state":"firing","value":2048},
+			{"name":"LowAlloc","expr":"Alloc < 1KB","state":"inactive","value":2048}]`},
+		{name: "Firing alerts", path: "/alerts?state=firing", want: `[{"name":"HighAlloc","expr":"Alloc > 1KB","state":"firing","value":2048}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, respBody := testRequest(t, ts, http.MethodGet, tt.path, "", "", "")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			var got []map[string]any
+			require.NoError(t, json.Unmarshal([]byte(respBody), &got))
+			for _, a := range got {
+				delete(a, "active_at")
+			}
+			body, _ := json.Marshal(got)
+			assert.JSONEq(t, tt.want, string(body))
+		})
+	}
+}
+
+func Test_handlers_mainPageWrite(t *testing.T) {
+	h := new(HandlersServer)
+	h.store = service.NewHandlerStore(memstorage.NewStore())
+	var errL error
+	h.l, errL = logger.NewLog("debug")
+	require.NoError(t, errL)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		body       string
+		contentEnc string
+		statusCode int
+	}{
+		{name: "Write lines", body: "cpu,host=a usage=0.5,procs=3i 1700000000000000000\nmem value=42", statusCode: http.StatusNoContent},
+		{name: "Write gzip", body: "cpu,host=a procs=2i", contentEnc: "gzip", statusCode: http.StatusNoContent},
+		{name: "Write bad line", body: "cpu usage=x", statusCode: http.StatusBadRequest},
+		{name: "Write bad label", body: "cpu,host-name=a usage=1", statusCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := testRequest(t, ts, http.MethodPost, "/write", tt.body, "text/plain", tt.contentEnc)
+			assert.Equal(t, tt.statusCode, resp.StatusCode)
+		})
+	}
+
+	values := []struct {
+		req  string
+		want string
+	}{
+		{req: `{"id":"cpu_usage","type":"gauge","labels":{"host":"a"}}`, want: `{"id":"cpu_usage","type":"gauge","labels":{"host":"a"},"value":0.5}`},
+		{req: `{"id":"cpu_procs","type":"counter","labels":{"host":"a"}}`, want: `{"id":"cpu_procs","type":"counter","labels":{"host":"a"},"delta":5}`},
+		{req: `{"id":"mem","type":"gauge"}`, want: `{"id":"mem","type":"gauge","value":42}`},
+	}
+	for _, v := range values {
+		resp, body := testRequest(t, ts, http.MethodPost, "/value/", v.req, "application/json", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.JSONEq(t, v.want, body)
+	}
+}
+
+func Test_handlers_envelopeBatch(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	h.cfg = &config.Config{Key: "secret"}
+	h.keys = keyring.New(h.cfg.Key)
+	h.privateKey = privateKey
+	h.l, err = logger.NewLog("debug")
+	require.NoError(t, err)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	// batch compressed to several envelope chunks
+	batch := make([]models.Metrics, 0, 20000)
+	for i := 0; i < cap(batch); i++ {
+		v := float64(i) * 1.37
+		batch = append(batch, models.Metrics{ID: "g" + strconv.Itoa(i), MType: "gauge", Value: &v})
+	}
+
+	post := func(t *testing.T, tamper bool) int {
+		key := make([]byte, aescoder.KeySize)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &privateKey.PublicKey, key, nil)
+		require.NoError(t, err)
+
+		var body bytes.Buffer
+		ew, err := aescoder.NewEnvelopeWriter(&body, key)
+		require.NoError(t, err)
+		gz := gzip.NewWriter(ew)
+		hw := hmacsha256.NewWriter(gz, []byte("secret"))
+		require.NoError(t, models.JSONSEncodeBytes(hw, batch))
+		require.NoError(t, gz.Close())
+		require.NoError(t, ew.Close())
+		require.Greater(t, body.Len(), aescoder.ChunkSize)
+		if tamper {
+			body.Bytes()[body.Len()/2] ^= 1
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/updates/", &body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("HashSHA256", hex.EncodeToString(hw.GetSig()))
+		req.Header.Set(aescoder.HeaderEnvelope, hex.EncodeToString(wrapped))
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, post(t, false))
+	assert.NotEqual(t, http.StatusOK, post(t, true), "tampered envelope is rejected")
+}
+
+func Test_handlers_replay(t *testing.T) {
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	h.cfg = &config.Config{Key: "secret", ReplayWindow: 60, NonceCacheSize: 10}
+	h.keys = keyring.New(h.cfg.Key)
+	var err error
+	h.l, err = logger.NewLog("debug")
+	require.NoError(t, err)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	body := []byte(`{"id":"PollCount","type":"counter","delta":5}`)
+	post := func(t *testing.T, timestamp, nonce string) int {
+		hw := hmacsha256.NewWriter(io.Discard, []byte("secret"))
+		if timestamp != "" {
+			hw.Seed(hmacsha256.SignedPrefix(http.MethodPost, "/update/", timestamp, nonce))
+		}
+		_, _ = hw.Write(body)
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/update/", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(hmacsha256.HeaderHash, hex.EncodeToString(hw.GetSig()))
+		if timestamp != "" {
+			req.Header.Set(hmacsha256.HeaderTimestamp, timestamp)
+			req.Header.Set(hmacsha256.HeaderNonce, nonce)
+		}
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	timestamp, nonce, err := hmacsha256.NewNonce(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, post(t, timestamp, nonce))
+	assert.Equal(t, http.StatusConflict, post(t, timestamp, nonce), "replayed request")
+
+	stale, nonce2, err := hmacsha256.NewNonce(time.Now().Add(-2 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, post(t, stale, nonce2))
+	future, nonce3, err := hmacsha256.NewNonce(time.Now().Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, post(t, future, nonce3))
+
+	assert.Equal(t, http.StatusBadRequest, post(t, timestamp, "short"))
+	assert.Equal(t, http.StatusOK, post(t, "", ""), "signed by body only")
+
+	for i := 1; i < h.cfg.NonceCacheSize; i++ {
+		timestamp, nonce, err := hmacsha256.NewNonce(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, post(t, timestamp, nonce))
+	}
+	timestamp, nonce, err = hmacsha256.NewNonce(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, post(t, timestamp, nonce), "nonce cache is full")
+
+	val, err := store.GetValueModel(context.Background(), models.Metrics{ID: "PollCount", MType: "counter"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(55), *val.Delta)
+}
+
+func Test_handlers_strictAuth(t *testing.T) {
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	h.cfg = &config.Config{Key: "secret", StrictAuth: true}
+	h.keys = keyring.New(h.cfg.Key)
+	var err error
+	h.l, err = logger.NewLog("debug")
+	require.NoError(t, err)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	// do - request signed for method and target, empty target - unsigned
+	do := func(t *testing.T, method, path, signedPath, contentType, body string) int {
+		req, err := http.NewRequestWithContext(context.Background(), method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if signedPath != "" {
+			timestamp, nonce, err := hmacsha256.NewNonce(time.Now())
+			require.NoError(t, err)
+			hw := hmacsha256.NewWriter(io.Discard, []byte("secret"))
+			hw.Seed(hmacsha256.SignedPrefix(method, signedPath, timestamp, nonce))
+			_, _ = hw.Write([]byte(body))
+			req.Header.Set(hmacsha256.HeaderHash, hex.EncodeToString(hw.GetSig()))
+			req.Header.Set(hmacsha256.HeaderTimestamp, timestamp)
+			req.Header.Set(hmacsha256.HeaderNonce, nonce)
+		}
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	jsonBody := `{"id":"g","type":"gauge","value":1.5}`
+	tests := []struct {
+		name, method, path, signedPath, contentType, body string
+		want                                              int
+	}{
+		{name: "plain unsigned", method: http.MethodPost, path: "/update/counter/c/1", want: http.StatusUnauthorized},
+		{name: "plain signed", method: http.MethodPost, path: "/update/counter/c/1", signedPath: "/update/counter/c/1", want: http.StatusOK},
+		{name: "plain other target", method: http.MethodPost, path: "/update/counter/c/1000", signedPath: "/update/counter/c/1", want: http.StatusBadRequest},
+		{name: "get plain unsigned", method: http.MethodGet, path: "/value/counter/c", want: http.StatusUnauthorized},
+		{name: "get plain signed", method: http.MethodGet, path: "/value/counter/c", signedPath: "/value/counter/c", want: http.StatusOK},
+		{name: "json unsigned", method: http.MethodPost, path: "/update/", contentType: "application/json", body: jsonBody, want: http.StatusUnauthorized},
+		{name: "json signed", method: http.MethodPost, path: "/update/", signedPath: "/update/", contentType: "application/json", body: jsonBody, want: http.StatusOK},
+		{name: "batch unsigned", method: http.MethodPost, path: "/updates/", contentType: "application/json", body: "[" + jsonBody + "]", want: http.StatusUnauthorized},
+		{name: "batch signed", method: http.MethodPost, path: "/updates/", signedPath: "/updates/", contentType: "application/json", body: "[" + jsonBody + "]", want: http.StatusOK},
+		{name: "get json unsigned", method: http.MethodPost, path: "/value/", contentType: "application/json", body: `{"id":"g","type":"gauge"}`, want: http.StatusUnauthorized},
+		{name: "get json signed", method: http.MethodPost, path: "/value/", signedPath: "/value/", contentType: "application/json", body: `{"id":"g","type":"gauge"}`, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, do(t, tt.method, tt.path, tt.signedPath, tt.contentType, tt.body))
+		})
+	}
+
+	t.Run("body signature without nonce", func(t *testing.T) {
+		hw := hmacsha256.NewWriter(io.Discard, []byte("secret"))
+		_, _ = hw.Write([]byte(jsonBody))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/update/", strings.NewReader(jsonBody))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(hmacsha256.HeaderHash, hex.EncodeToString(hw.GetSig()))
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	val, err := store.GetValuePlain(context.Background(), "c", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, "1", val)
+}
+
+func Test_handlers_keyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys": [
+		{"id": "agent-1", "secret": "s1"},
+		{"id": "agent-2", "secret": "s2", "status": "deprecated"},
+		{"id": "agent-3", "secret": "s3", "status": "revoked"}]}`), 0o600))
+	keys, err := keyring.Load("", path)
+	require.NoError(t, err)
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	h.cfg = &config.Config{KeyringFile: path, StrictAuth: true}
+	h.keys = keys
+	core, logs := observer.New(zap.DebugLevel)
+	h.l = zap.New(core)
+	ts := httptest.NewServer(h.newRouter())
+	defer ts.Close()
+
+	body := `{"id":"c","type":"counter","delta":1}`
+	post := func(t *testing.T, keyID, secret string) *http.Response {
+		timestamp, nonce, err := hmacsha256.NewNonce(time.Now())
+		require.NoError(t, err)
+		hw := hmacsha256.NewWriter(io.Discard, []byte(secret))
+		hw.Seed(hmacsha256.SignedPrefix(http.MethodPost, "/update/", timestamp, nonce))
+		_, _ = hw.Write([]byte(body))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+"/update/", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(hmacsha256.HeaderHash, hex.EncodeToString(hw.GetSig()))
+		req.Header.Set(hmacsha256.HeaderTimestamp, timestamp)
+		req.Header.Set(hmacsha256.HeaderNonce, nonce)
+		if keyID != "" {
+			req.Header.Set(hmacsha256.HeaderKeyID, keyID)
+		}
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	tests := []struct {
+		name, keyID, secret string
+		want                int
+		wantKeyID           string
+	}{
+		{name: "active", keyID: "agent-1", secret: "s1", want: http.StatusOK, wantKeyID: "agent-1"},
+		{name: "deprecated", keyID: "agent-2", secret: "s2", want: http.StatusOK, wantKeyID: "agent-2"},
+		{name: "revoked", keyID: "agent-3", secret: "s3", want: http.StatusUnauthorized},
+		{name: "unknown", keyID: "agent-4", secret: "s1", want: http.StatusUnauthorized},
+		{name: "secret of other key", keyID: "agent-1", secret: "s2", want: http.StatusBadRequest},
+		{name: "without key id", secret: "s1", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := post(t, tt.keyID, tt.secret)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.want, resp.StatusCode)
+			var logged []any
+			for _, e := range logs.TakeAll() {
+				if e.Message == "got incoming HTTP request" {
+					logged = append(logged, e.ContextMap()["key_id"])
+				}
+			}
+			assert.Equal(t, []any{tt.wantKeyID}, logged, "only verified key id is logged")
+		})
+	}
+
+	val, err := store.GetValuePlain(context.Background(), "c", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, "2", val)
+}
+
+func Test_handlers_mutualTLS(t *testing.T) {
+	f := tlstest.Generate(t)
+	store := service.NewHandlerStore(memstorage.NewStore())
+	h := new(HandlersServer)
+	h.store = store
+	h.cfg = &config.Config{HTTPS: true, PrivateCertFile: f.ServerCert, PrivateKeyFile: f.ServerKey, ClientCAFile: f.CA}
+	var err error
+	h.l, err = logger.NewLog("debug")
+	require.NoError(t, err)
+	serverTLS, err := h.getTLS()
+	require.NoError(t, err)
+	ts := httptest.NewUnstartedServer(h.newRouter())
+	ts.TLS = serverTLS
+	ts.StartTLS()
+	defer ts.Close()
+
+	post := func(t *testing.T, certFile, keyFile string) (int, error) {
+		clientTLS, err := tlsconfig.Client(f.CA, certFile, keyFile)
+		require.NoError(t, err)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		resp, err := client.Post(ts.URL+"/update/counter/c/1", "text/plain", http.NoBody)
+		if err != nil {
+			return 0, err
+		}
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode, nil
+	}
+
+	code, err := post(t, f.ClientCert, f.ClientKey)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, err = post(t, "", "")
+	assert.Error(t, err, "without client certificate")
+	_, err = post(t, f.OtherCert, f.OtherKey)
+	assert.Error(t, err, "client certificate of other CA")
+
+	val, err := store.GetValuePlain(context.Background(), "c", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, "1", val)
+
+	h.cfg = &config.Config{HTTPS: true}
+	_, err = h.getTLS()
+	assert.ErrorIs(t, err, ErrHTTPSConfig)
+
+	// client CA without https is mutual TLS of gRPC server only
+	h.cfg = &config.Config{ClientCAFile: f.CA, PrivateCertFile: f.ServerCert, PrivateKeyFile: f.ServerKey}
+	serverTLS, err = h.getTLS()
+	assert.NoError(t, err)
+	assert.Nil(t, serverTLS, "plain HTTP")
+}
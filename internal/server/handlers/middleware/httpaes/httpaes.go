@@ -4,6 +4,7 @@ package httpaes
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
@@ -15,9 +16,33 @@ import (
 
 type aesReader struct {
 	r  io.ReadCloser
-	zr *aescoder.AesReader
+	zr io.ReadCloser
 }
 
+// NewEnvelopeReader - opens aescoder envelope, key is hex RSA-OAEP SHA-256 wrapped
+func NewEnvelopeReader(r io.ReadCloser, privateKeyLoaded *rsa.PrivateKey, aesSkey string) (*aesReader, error) {
+	key, err := hex.DecodeString(aesSkey)
+	if err != nil {
+		return nil, err
+	}
+
+	decryptedKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKeyLoaded, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := aescoder.NewEnvelopeReader(r, decryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesReader{
+		r:  r,
+		zr: zr,
+	}, nil
+}
+
+// NewAesReader - legacy AES-256 header, key is hex RSA PKCS#1 v1.5 wrapped
 func NewAesReader(r io.ReadCloser, privateKeyLoaded *rsa.PrivateKey, aesSkey string) (*aesReader, error) {
 	key, err := hex.DecodeString(aesSkey)
 	if err != nil {
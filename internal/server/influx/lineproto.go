@@ -0,0 +1,169 @@
+// Package influx - InfluxDB line protocol ingestion
+package influx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+)
+
+var (
+	ErrBadLine = errors.New("invalid line protocol")
+)
+
+// maxLineSize - longest accepted line
+const maxLineSize = 1024 * 1024
+
+// Parse - all lines of body, error reports number of first broken line
+func Parse(r io.Reader) ([]models.Metrics, error) {
+	var res []models.Metrics
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		vals, err := ParseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		res = append(res, vals...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ParseLine - measurement[,tag=val...] field=val[,field=val...] [timestamp]
+// Each field is a metric measurement_field (field "value" is measurement itself), tags are labels.
+// Float fields are gauges, integer fields are counters, booleans are gauges 0/1, strings are skipped.
+// Timestamp is validated, value is stored with receive time.
+func ParseLine(line string) ([]models.Metrics, error) {
+	sections := split(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, ErrBadLine
+	}
+	if len(sections) == 3 {
+		if _, err := strconv.ParseInt(sections[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: timestamp", ErrBadLine)
+		}
+	}
+
+	head := split(sections[0], ',', false)
+	measurement := unescape(head[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("%w: measurement", ErrBadLine)
+	}
+	var labels map[string]string
+	for _, t := range head[1:] {
+		k, v, ok := cut(t)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%w: tag %q", ErrBadLine, t)
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[unescape(k)] = unescape(v)
+	}
+
+	var res []models.Metrics
+	for _, f := range split(sections[1], ',', true) {
+		k, v, ok := cut(f)
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("%w: field %q", ErrBadLine, f)
+		}
+		m := models.Metrics{ID: measurement, Labels: labels}
+		if name := unescape(k); name != "value" {
+			m.ID = measurement + "_" + name
+		}
+		skip, err := setField(&m, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %q", err, f)
+		}
+		if !skip {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+// setField - typed field value, skip is true for string fields
+func setField(m *models.Metrics, v string) (skip bool, err error) {
+	switch {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return false, ErrBadLine
+		}
+		return true, nil
+	case strings.HasSuffix(v, "i"), strings.HasSuffix(v, "u"):
+		d, errP := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		if errP != nil {
+			return false, ErrBadLine
+		}
+		m.MType = "counter"
+		m.Delta = &d
+	default:
+		var f float64
+		switch v {
+		case "t", "T", "true", "True", "TRUE":
+			f = 1
+		case "f", "F", "false", "False", "FALSE":
+			f = 0
+		default:
+			var errP error
+			if f, errP = strconv.ParseFloat(v, 64); errP != nil {
+				return false, ErrBadLine
+			}
+		}
+		m.MType = "gauge"
+		m.Value = &f
+	}
+	return false, nil
+}
+
+// split - split by separator not escaped by backslash, quoted strings are skipped if quotes is true
+func split(s string, sep byte, quotes bool) []string {
+	var res []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			quoted = !quoted
+		case c == sep && !quoted:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, s[start:])
+}
+
+// cut - key and value around first not escaped '='
+func cut(s string) (key, val string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '=':
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+var unescaper = strings.NewReplacer(`\,`, `,`, `\ `, ` `, `\=`, `=`, `\\`, `\`)
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
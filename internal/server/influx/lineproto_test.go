@@ -0,0 +1,65 @@
+package influx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gauge(id string, v float64, labels map[string]string) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &v, Labels: labels}
+}
+
+func counter(id string, d int64, labels map[string]string) models.Metrics {
+	return models.Metrics{ID: id, MType: "counter", Delta: &d, Labels: labels}
+}
+
+func Test_ParseLine(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		line    string
+		want    []models.Metrics
+	}{
+		{name: "single field", line: "cpu usage=0.5", want: []models.Metrics{gauge("cpu_usage", 0.5, nil)}},
+		{name: "value field", line: "mem value=42", want: []models.Metrics{gauge("mem", 42, nil)}},
+		{name: "tags and timestamp", line: "cpu,host=a,region=eu usage=1,procs=3i 1700000000000000000",
+			want: []models.Metrics{
+				gauge("cpu_usage", 1, map[string]string{"host": "a", "region": "eu"}),
+				counter("cpu_procs", 3, map[string]string{"host": "a", "region": "eu"}),
+			}},
+		{name: "unsigned and bool", line: "disk full=t,inodes=10u", want: []models.Metrics{gauge("disk_full", 1, nil), counter("disk_inodes", 10, nil)}},
+		{name: "string skipped", line: `sys uptime="1 day, 3 hours",load=0.1`, want: []models.Metrics{gauge("sys_load", 0.1, nil)}},
+		{name: "escaped", line: `my\ cpu,host=a\,b usage=2`, want: []models.Metrics{gauge("my cpu_usage", 2, map[string]string{"host": "a,b"})}},
+		{name: "no fields", line: "cpu", wantErr: ErrBadLine},
+		{name: "bad field", line: "cpu usage", wantErr: ErrBadLine},
+		{name: "bad value", line: "cpu usage=abc", wantErr: ErrBadLine},
+		{name: "bad integer", line: "cpu usage=1.5i", wantErr: ErrBadLine},
+		{name: "bad tag", line: "cpu,host usage=1", wantErr: ErrBadLine},
+		{name: "bad timestamp", line: "cpu usage=1 now", wantErr: ErrBadLine},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	got, err := Parse(strings.NewReader("# comment\ncpu usage=1\n\nmem value=2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []models.Metrics{gauge("cpu_usage", 1, nil), gauge("mem", 2, nil)}, got)
+
+	_, err = Parse(strings.NewReader("cpu usage=1\ncpu usage=x\n"))
+	assert.ErrorIs(t, err, ErrBadLine)
+	assert.Contains(t, err.Error(), "line 2")
+}
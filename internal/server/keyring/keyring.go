@@ -0,0 +1,158 @@
+// Package keyring - signature secrets of agents by key id, file is reloaded on SIGHUP
+package keyring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Status - lifecycle of key: new key is added active, old one is deprecated
+// while agents move to new key and then revoked
+type Status string
+
+const (
+	StatusActive     Status = "active"
+	StatusDeprecated Status = "deprecated" // accepted with warning
+	StatusRevoked    Status = "revoked"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown key id")
+	ErrRevoked    = errors.New("key is revoked")
+	ErrKeyring    = errors.New("invalid keyring")
+)
+
+type Key struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	Status Status `json:"status,omitempty"` // empty is active
+}
+
+// file - keyring file, json
+//
+//	{"keys": [{"id": "agent-1", "secret": "...", "status": "deprecated"}, ...]}
+type file struct {
+	Keys []Key `json:"keys"`
+}
+
+// Keyring - keys by id. Legacy key of single shared Key setting is used for requests without key id
+type Keyring struct {
+	mux    sync.RWMutex
+	keys   map[string]Key
+	legacy string
+	path   string
+}
+
+func New(legacy string) *Keyring {
+	return &Keyring{keys: make(map[string]Key), legacy: legacy}
+}
+
+// Load - keyring with keys of file at path, empty path is legacy key only
+func Load(legacy, path string) (*Keyring, error) {
+	k := New(legacy)
+	k.path = path
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Enabled - requests must be checked, keyring may become empty after reload
+func (k *Keyring) Enabled() bool {
+	return k.legacy != "" || k.path != ""
+}
+
+// Lookup - key of signed request, deprecated key is returned without error
+func (k *Keyring) Lookup(id string) (Key, error) {
+	if id == "" {
+		if k.legacy == "" {
+			return Key{}, ErrUnknownKey
+		}
+		return Key{Secret: k.legacy, Status: StatusActive}, nil
+	}
+	k.mux.RLock()
+	key, ok := k.keys[id]
+	k.mux.RUnlock()
+	switch {
+	case !ok:
+		return Key{}, ErrUnknownKey
+	case key.Status == StatusRevoked:
+		return Key{}, ErrRevoked
+	}
+	return key, nil
+}
+
+func (k *Keyring) Len() int {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return len(k.keys)
+}
+
+// Reload - reads file again, on error keys are kept
+func (k *Keyring) Reload() error {
+	if k.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parse(data)
+	if err != nil {
+		return err
+	}
+	k.mux.Lock()
+	k.keys = keys
+	k.mux.Unlock()
+	return nil
+}
+
+func parse(data []byte) (map[string]Key, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyring, err)
+	}
+	keys := make(map[string]Key, len(f.Keys))
+	for i, key := range f.Keys {
+		if key.Status == "" {
+			key.Status = StatusActive
+		}
+		switch {
+		case key.ID == "":
+			return nil, fmt.Errorf("%w: key %d without id", ErrKeyring, i)
+		case key.Secret == "" && key.Status != StatusRevoked:
+			return nil, fmt.Errorf("%w: key %q without secret", ErrKeyring, key.ID)
+		case key.Status != StatusActive && key.Status != StatusDeprecated && key.Status != StatusRevoked:
+			return nil, fmt.Errorf("%w: key %q unknown status %q", ErrKeyring, key.ID, key.Status)
+		}
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("%w: duplicate key id %q", ErrKeyring, key.ID)
+		}
+		keys[key.ID] = key
+	}
+	return keys, nil
+}
+
+// Watch - reloads keyring on every signal until ctx is done
+func (k *Keyring) Watch(ctx context.Context, sigs <-chan os.Signal, l *zap.Logger) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigs:
+				if err := k.Reload(); err != nil {
+					l.Error("Keyring reload error, keys are kept:", zap.Error(err))
+					continue
+				}
+				l.Info("Keyring reloaded", zap.String("file", k.path), zap.Int("keys", k.Len()))
+			}
+		}
+	}()
+}
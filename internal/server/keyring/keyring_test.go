@@ -0,0 +1,105 @@
+package keyring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeKeys(t *testing.T, path, data string) {
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+func Test_KeyringLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, `{"keys": [
+		{"id": "a1", "secret": "s1"},
+		{"id": "a2", "secret": "s2", "status": "deprecated"},
+		{"id": "a3", "secret": "s3", "status": "revoked"}]}`)
+	k, err := Load("legacy", path)
+	require.NoError(t, err)
+	assert.True(t, k.Enabled())
+	assert.Equal(t, 3, k.Len())
+
+	key, err := k.Lookup("a1")
+	require.NoError(t, err)
+	assert.Equal(t, Key{ID: "a1", Secret: "s1", Status: StatusActive}, key)
+
+	key, err = k.Lookup("a2")
+	require.NoError(t, err)
+	assert.Equal(t, StatusDeprecated, key.Status)
+
+	_, err = k.Lookup("a3")
+	assert.ErrorIs(t, err, ErrRevoked)
+	_, err = k.Lookup("a4")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	key, err = k.Lookup("")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", key.Secret)
+
+	_, err = New("").Lookup("")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.False(t, New("").Enabled())
+}
+
+func Test_KeyringInvalid(t *testing.T) {
+	tests := []struct {
+		name, data string
+	}{
+		{name: "not json", data: `keys`},
+		{name: "without id", data: `{"keys": [{"secret": "s"}]}`},
+		{name: "without secret", data: `{"keys": [{"id": "a"}]}`},
+		{name: "unknown status", data: `{"keys": [{"id": "a", "secret": "s", "status": "old"}]}`},
+		{name: "duplicate", data: `{"keys": [{"id": "a", "secret": "s"}, {"id": "a", "secret": "t"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			writeKeys(t, path, tt.data)
+			_, err := Load("", path)
+			assert.ErrorIs(t, err, ErrKeyring)
+		})
+	}
+	_, err := Load("", filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func Test_KeyringWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, `{"keys": [{"id": "a1", "secret": "s1"}]}`)
+	k, err := Load("", path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal)
+	k.Watch(ctx, sigs, zap.NewNop())
+
+	// rotation: new key added, old one deprecated
+	writeKeys(t, path, `{"keys": [{"id": "a1", "secret": "s1", "status": "deprecated"}, {"id": "a2", "secret": "s2"}]}`)
+	sigs <- syscall.SIGHUP
+	assert.Eventually(t, func() bool { return k.Len() == 2 }, time.Second, 10*time.Millisecond)
+
+	// broken file keeps keys
+	writeKeys(t, path, `{"keys": [`)
+	sigs <- syscall.SIGHUP
+	sigs <- syscall.SIGHUP // second send waits for first reload
+	key, err := k.Lookup("a2")
+	require.NoError(t, err)
+	assert.Equal(t, "s2", key.Secret)
+
+	writeKeys(t, path, `{"keys": [{"id": "a1", "status": "revoked"}, {"id": "a2", "secret": "s2"}]}`)
+	sigs <- syscall.SIGHUP
+	assert.Eventually(t, func() bool {
+		_, err := k.Lookup("a1")
+		return err == ErrRevoked
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,105 @@
+// Package nonces - bounded cache of nonces of accepted signed requests
+package nonces
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
+	"github.com/4aleksei/metricscum/internal/server/config"
+)
+
+var (
+	ErrStale    = errors.New("request timestamp out of window")
+	ErrReplayed = errors.New("replayed request")
+	ErrFull     = errors.New("nonce cache is full")
+)
+
+type entry struct {
+	nonce  string
+	expiry time.Time
+}
+
+// Cache - request timestamp must be within window of now, so nonces are kept for two windows.
+// Every nonce has same ttl so queue is ordered by expiry.
+// Nonce is never dropped before expiry, when cache is full new nonces are rejected,
+// size must cover requests rate of ttl
+type Cache struct {
+	mux    sync.Mutex
+	seen   map[string]struct{}
+	queue  []entry
+	window time.Duration
+	ttl    time.Duration
+	size   int
+}
+
+func NewCache(size int, window time.Duration) *Cache {
+	return &Cache{
+		seen:   make(map[string]struct{}),
+		window: window,
+		ttl:    2 * window,
+		size:   size,
+	}
+}
+
+// New - cache of replay window and nonce cache size of server config, not set values are defaults
+func New(cfg *config.Config) *Cache {
+	size, window := config.NonceCacheSizeDefault, config.ReplayWindowDefault
+	if cfg != nil && cfg.NonceCacheSize > 0 {
+		size = cfg.NonceCacheSize
+	}
+	if cfg != nil && cfg.ReplayWindow > 0 {
+		window = cfg.ReplayWindow
+	}
+	return NewCache(size, time.Duration(window)*time.Second)
+}
+
+// Check - values of hmacsha256.HeaderTimestamp and hmacsha256.HeaderNonce of signed request received at now
+func (c *Cache) Check(timestamp, nonce string, now time.Time) error {
+	sent, err := hmacsha256.ParseNonce(timestamp, nonce)
+	if err != nil {
+		return err
+	}
+	if skew := now.Sub(sent); skew > c.window || -skew > c.window {
+		return ErrStale
+	}
+	return c.Add(nonce, now)
+}
+
+// Add - ErrReplayed if nonce was seen within ttl, ErrFull if cache holds size not expired nonces
+func (c *Cache) Add(nonce string, now time.Time) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.expire(now)
+	if _, ok := c.seen[nonce]; ok {
+		return ErrReplayed
+	}
+	if len(c.queue) >= c.size {
+		return ErrFull
+	}
+	c.seen[nonce] = struct{}{}
+	c.queue = append(c.queue, entry{nonce: nonce, expiry: now.Add(c.ttl)})
+	return nil
+}
+
+func (c *Cache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.queue)
+}
+
+func (c *Cache) expire(now time.Time) {
+	for len(c.queue) > 0 && !c.queue[0].expiry.After(now) {
+		c.drop()
+	}
+	// release memory of dropped head
+	if cap(c.queue) > 2*c.size && len(c.queue) < c.size {
+		c.queue = append(make([]entry, 0, c.size), c.queue...)
+	}
+}
+
+func (c *Cache) drop() {
+	delete(c.seen, c.queue[0].nonce)
+	c.queue = c.queue[1:]
+}
@@ -0,0 +1,62 @@
+package nonces
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/middleware/hmacsha256"
+	"github.com/4aleksei/metricscum/internal/server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CacheReplay(t *testing.T) {
+	c := NewCache(10, 30*time.Second)
+	now := time.Unix(1000, 0)
+	assert.NoError(t, c.Add("a", now))
+	assert.ErrorIs(t, c.Add("a", now.Add(time.Second)), ErrReplayed, "replayed nonce")
+	assert.NoError(t, c.Add("b", now.Add(30*time.Second)))
+	assert.NoError(t, c.Add("a", now.Add(time.Minute)), "expired nonce")
+	assert.Equal(t, 2, c.Len())
+}
+
+func Test_CacheBounded(t *testing.T) {
+	c := NewCache(3, time.Hour)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.Add(strconv.Itoa(i), now))
+	}
+	for i := 3; i < 100; i++ {
+		assert.ErrorIs(t, c.Add(strconv.Itoa(i), now), ErrFull)
+		assert.Equal(t, 3, c.Len())
+	}
+	assert.ErrorIs(t, c.Add("0", now.Add(time.Hour)), ErrReplayed, "nonce is not dropped before expiry")
+	assert.NoError(t, c.Add("3", now.Add(2*time.Hour)), "expired nonces free cache")
+	assert.Equal(t, 1, c.Len())
+}
+
+func Test_CacheCheck(t *testing.T) {
+	c := NewCache(10, time.Minute)
+	now := time.Unix(1700000000, 0)
+	nonce := "000102030405060708090a0b0c0d0e0f"
+	assert.NoError(t, c.Check("1700000030", nonce, now))
+	assert.ErrorIs(t, c.Check("1700000030", nonce, now), ErrReplayed)
+	assert.ErrorIs(t, c.Check("1699999900", "100102030405060708090a0b0c0d0e0f", now), ErrStale)
+	assert.ErrorIs(t, c.Check("1700000100", "200102030405060708090a0b0c0d0e0f", now), ErrStale)
+	assert.ErrorIs(t, c.Check("now", nonce, now), hmacsha256.ErrBadTimestamp)
+
+	full := NewCache(1, time.Minute)
+	assert.NoError(t, full.Check("1700000000", nonce, now))
+	assert.ErrorIs(t, full.Check("1700000000", "300102030405060708090a0b0c0d0e0f", now), ErrFull)
+	assert.ErrorIs(t, c.Check("1700000000", "0001", now), hmacsha256.ErrBadNonce)
+}
+
+func Test_New(t *testing.T) {
+	c := New(&config.Config{NonceCacheSize: 5, ReplayWindow: 10})
+	assert.Equal(t, 5, c.size)
+	assert.Equal(t, 10*time.Second, c.window)
+
+	c = New(&config.Config{})
+	assert.Equal(t, config.NonceCacheSizeDefault, c.size, "zero size is default")
+	assert.Equal(t, time.Duration(config.ReplayWindowDefault)*time.Second, c.window, "zero window is default")
+}
@@ -2,10 +2,12 @@ package resources
 
 import (
 	"context"
+	"time"
 
 	"github.com/4aleksei/metricscum/internal/common/models"
 	"github.com/4aleksei/metricscum/internal/common/repository"
 	"github.com/4aleksei/metricscum/internal/common/repository/dbstorage"
+	"github.com/4aleksei/metricscum/internal/common/repository/history"
 	"github.com/4aleksei/metricscum/internal/common/repository/longtermfile"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstoragemux"
@@ -27,10 +29,16 @@ type resoucesMetricsStorage interface {
 	AddMulti(context.Context, []models.Metrics) ([]models.Metrics, error)
 }
 
+type resoucesMetricsHistory interface {
+	Append(context.Context, time.Time, []models.Metrics) error
+	Range(context.Context, string, int, time.Time, time.Time, history.FuncReadPoint) error
+}
+
 type handleResources struct {
-	Store resoucesMetricsStorage
-	DB    store.Store
-	FILE  *repository.MemStorageMuxLongTerm
+	Store   resoucesMetricsStorage
+	History resoucesMetricsHistory // nil without history
+	DB      store.Store
+	FILE    *repository.MemStorageMuxLongTerm
 }
 
 func CreateResouces(cfg *config.Config, l *zap.Logger) (*handleResources, error) {
@@ -43,6 +51,9 @@ func CreateResouces(cfg *config.Config, l *zap.Logger) (*handleResources, error)
 			return nil, errDB
 		}
 		hs.Store = dbstorage.NewStoreDB(db, l)
+		if cfg.HistorySize > 0 {
+			hs.History = dbstorage.NewHistoryDB(db, l, cfg.HistorySize)
+		}
 		hs.DB = db
 	} else {
 		if cfg.FilePath != "" {
@@ -60,6 +71,9 @@ func CreateResouces(cfg *config.Config, l *zap.Logger) (*handleResources, error)
 		} else {
 			hs.Store = memstoragemux.NewStoreMux()
 		}
+		if cfg.HistorySize > 0 {
+			hs.History = history.NewRing(cfg.HistorySize)
+		}
 	}
 	return hs, nil
 }
@@ -5,8 +5,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/history"
 	"github.com/4aleksei/metricscum/internal/common/repository/memstorage"
 	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
 )
@@ -19,22 +21,49 @@ type serverMetricsStorage interface {
 	AddMulti(context.Context, []models.Metrics) ([]models.Metrics, error)
 }
 
+type serverMetricsHistory interface {
+	Append(context.Context, time.Time, []models.Metrics) error
+	Range(context.Context, string, int, time.Time, time.Time, history.FuncReadPoint) error
+}
+
 type HandlerStore struct {
-	store serverMetricsStorage
+	store   serverMetricsStorage
+	history serverMetricsHistory
+}
+
+type Option func(*HandlerStore)
+
+// WithHistory - keep every accepted gauge and counter value in history
+func WithHistory(hist serverMetricsHistory) Option {
+	return func(h *HandlerStore) {
+		h.history = hist
+	}
 }
 
-func NewHandlerStore(store serverMetricsStorage) *HandlerStore {
+func NewHandlerStore(store serverMetricsStorage, opts ...Option) *HandlerStore {
 	h := new(HandlerStore)
 	h.store = store
+	for _, o := range opts {
+		o(h)
+	}
 	return h
 }
 
 var (
-	ErrBadValue = errors.New("invalid value")
-	ErrBadName  = errors.New("no name")
-	ErrNoDB     = errors.New("no db")
+	ErrBadValue  = errors.New("invalid value")
+	ErrBadName   = errors.New("no name")
+	ErrNoDB      = errors.New("no db")
+	ErrNoHistory = errors.New("no history")
 )
 
+// appendHistory - history is best effort, update is already stored
+func (h *HandlerStore) appendHistory(ctx context.Context, vals []models.Metrics) {
+	if h.history == nil {
+		return
+	}
+	_ = h.history.Append(ctx, time.Now(), vals)
+}
+
 func (h *HandlerStore) CheckType(s string) error {
 	_, errKind := valuemetric.GetKind(s)
 	if errKind != nil {
@@ -48,6 +77,7 @@ func (h *HandlerStore) SetValueSModel(ctx context.Context, valModel []models.Met
 	if errA != nil {
 		return nil, fmt.Errorf("add failed %w", errA)
 	}
+	h.appendHistory(ctx, valNewModel)
 	return valNewModel, nil
 }
 
@@ -59,17 +89,22 @@ func (h *HandlerStore) SetValueModel(ctx context.Context, valModel models.Metric
 	if valModel.ID == "" {
 		return nil, fmt.Errorf("failed %w", ErrBadName)
 	}
-	val, err := valuemetric.ConvertToValueMetricInt(kind, valModel.Delta, valModel.Value)
+	key, errKey := valModel.SeriesKey()
+	if errKey != nil {
+		return nil, fmt.Errorf("failed %w", errKey)
+	}
+	val, err := valuemetric.ConvertToValueMetricHist(kind, valModel.Delta, valModel.Value, valModel.Histogram)
 	if err != nil {
 		return nil, fmt.Errorf("failed %w", err)
 	}
-	newval, errA := h.store.Add(ctx, valModel.ID, *val)
+	newval, errA := h.store.Add(ctx, key, *val)
 	if errA != nil {
 		return nil, fmt.Errorf("add failed %w", errA)
 	}
 
 	valNewModel := new(models.Metrics)
-	valNewModel.ConvertMetricToModel(valModel.ID, newval)
+	valNewModel.ConvertMetricToModel(key, newval)
+	h.appendHistory(ctx, []models.Metrics{*valNewModel})
 	return valNewModel, nil
 }
 
@@ -81,7 +116,11 @@ func (h *HandlerStore) GetValueModel(ctx context.Context, valModel models.Metric
 	if valModel.ID == "" {
 		return nil, fmt.Errorf("failed %w", ErrBadName)
 	}
-	val, err := h.store.Get(ctx, valModel.ID)
+	key, errKey := valModel.SeriesKey()
+	if errKey != nil {
+		return nil, fmt.Errorf("failed %w", errKey)
+	}
+	val, err := h.store.Get(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed %w", err)
 	}
@@ -89,7 +128,7 @@ func (h *HandlerStore) GetValueModel(ctx context.Context, valModel models.Metric
 		return nil, fmt.Errorf("failed %w", err)
 	}
 	valNewModel := new(models.Metrics)
-	valNewModel.ConvertMetricToModel(valModel.ID, val)
+	valNewModel.ConvertMetricToModel(key, val)
 	return valNewModel, nil
 }
 
@@ -98,17 +137,57 @@ func (h *HandlerStore) RecievePlainValue(ctx context.Context, typeVal, name, val
 	if errKind != nil {
 		return fmt.Errorf("kind failed %w", errKind)
 	}
+	// name is series key without labels, labels text in name is rejected
+	if _, err := models.SeriesKey(name, nil); err != nil {
+		return fmt.Errorf("failed %w", err)
+	}
 	val, err := valuemetric.ConvertToValueMetric(kind, valstr)
 	if err != nil {
 		return fmt.Errorf("failed %w", err)
 	}
-	_, err = h.store.Add(ctx, name, *val)
+	newval, err := h.store.Add(ctx, name, *val)
 	if err != nil {
 		return fmt.Errorf("failed %w", err)
 	}
+	var valNewModel models.Metrics
+	valNewModel.ConvertMetricToModel(name, newval)
+	h.appendHistory(ctx, []models.Metrics{valNewModel})
 	return nil
 }
 
+// GetHistory - gauge or counter values with from <= time <= to, last value of every step when step > 0
+func (h *HandlerStore) GetHistory(ctx context.Context, valModel models.Metrics, from, to time.Time, step time.Duration) ([]history.Point, error) {
+	if h.history == nil {
+		return nil, ErrNoHistory
+	}
+	kind, errKind := valuemetric.GetKind(valModel.MType)
+	if errKind != nil {
+		return nil, fmt.Errorf("kind failed %w", errKind)
+	}
+	if valModel.MType == "histogram" {
+		return nil, fmt.Errorf("kind failed %w", valuemetric.ErrBadKindType)
+	}
+	if valModel.ID == "" {
+		return nil, fmt.Errorf("failed %w", ErrBadName)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("failed %w", ErrBadValue)
+	}
+	key, errKey := valModel.SeriesKey()
+	if errKey != nil {
+		return nil, fmt.Errorf("failed %w", errKey)
+	}
+	points := make([]history.Point, 0)
+	err := h.history.Range(ctx, key, int(kind), from, to, func(p history.Point) error {
+		points = append(points, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed %w", err)
+	}
+	return history.Downsample(points, from, step), nil
+}
+
 func (h *HandlerStore) GetValuePlain(ctx context.Context, name, typeVal string) (string, error) {
 	val, err := h.store.Get(ctx, name)
 	if err != nil {
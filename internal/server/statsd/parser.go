@@ -0,0 +1,102 @@
+// Package statsd - StatsD UDP ingestion
+package statsd
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	typeCounter string = "c"
+	typeGauge   string = "g"
+	typeTimer   string = "ms"
+	typeHist    string = "h"
+
+	// MinRate - lowest accepted sample rate, one sample stands for at most 1000 events
+	MinRate float64 = 1e-3
+)
+
+var (
+	ErrBadLine = errors.New("invalid statsd line")
+)
+
+// DefaultTimerBounds - histogram buckets of timers, milliseconds
+var DefaultTimerBounds = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Sample - one statsd metric: name:value|type[|@rate][|#tag:val,...]
+type Sample struct {
+	Labels map[string]string
+	Name   string
+	Type   string
+	Value  float64
+	Rate   float64
+}
+
+// ParseLine - parse one statsd line, DogStatsD tags become labels
+func ParseLine(line string) (Sample, error) {
+	var s Sample
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return s, ErrBadLine
+	}
+	s.Name = line[:colon]
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 {
+		return s, ErrBadLine
+	}
+
+	val, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+		return s, ErrBadLine
+	}
+	s.Value = val
+
+	switch parts[1] {
+	case typeCounter, typeGauge, typeTimer:
+		s.Type = parts[1]
+	case typeHist:
+		s.Type = typeTimer
+	default:
+		return s, ErrBadLine
+	}
+
+	s.Rate = 1
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			rate, errR := strconv.ParseFloat(p[1:], 64)
+			if errR != nil || rate < MinRate || rate > 1 {
+				return s, ErrBadLine
+			}
+			s.Rate = rate
+		case strings.HasPrefix(p, "#"):
+			s.Labels = parseTags(p[1:])
+		default:
+			return s, ErrBadLine
+		}
+	}
+	return s, nil
+}
+
+// parseTags - tag:val,tag2:val2, tag without value gets empty value
+func parseTags(s string) map[string]string {
+	labels := make(map[string]string)
+	for _, t := range strings.Split(s, ",") {
+		if t == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(t, ":")
+		labels[k] = v
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// count - number of events represented by sample
+func (s *Sample) count() uint64 {
+	return uint64(math.Round(1 / s.Rate))
+}
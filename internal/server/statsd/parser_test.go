@@ -0,0 +1,43 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseLine(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		line    string
+		want    Sample
+	}{
+		{name: "counter", line: "requests:1|c", want: Sample{Name: "requests", Type: "c", Value: 1, Rate: 1}},
+		{name: "gauge", line: "temp:3.2|g", want: Sample{Name: "temp", Type: "g", Value: 3.2, Rate: 1}},
+		{name: "timer", line: "latency:120|ms", want: Sample{Name: "latency", Type: "ms", Value: 120, Rate: 1}},
+		{name: "histogram as timer", line: "latency:12|h", want: Sample{Name: "latency", Type: "ms", Value: 12, Rate: 1}},
+		{name: "sample rate", line: "requests:1|c|@0.1", want: Sample{Name: "requests", Type: "c", Value: 1, Rate: 0.1}},
+		{name: "tags", line: "requests:2|c|@0.5|#env:prod,host", want: Sample{Name: "requests", Type: "c", Value: 2, Rate: 0.5,
+			Labels: map[string]string{"env": "prod", "host": ""}}},
+		{name: "no value", line: "requests|c", wantErr: ErrBadLine},
+		{name: "no type", line: "requests:1", wantErr: ErrBadLine},
+		{name: "bad value", line: "requests:x|c", wantErr: ErrBadLine},
+		{name: "set not supported", line: "users:42|s", wantErr: ErrBadLine},
+		{name: "bad rate", line: "requests:1|c|@2", wantErr: ErrBadLine},
+		{name: "tiny rate", line: "latency:1|h|@1e-12", wantErr: ErrBadLine},
+		{name: "min rate", line: "latency:1|h|@0.001", want: Sample{Name: "latency", Type: "ms", Value: 1, Rate: 0.001}},
+		{name: "bad section", line: "requests:1|c|x", wantErr: ErrBadLine},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
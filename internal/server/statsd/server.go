@@ -0,0 +1,194 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/4aleksei/metricscum/internal/common/repository/valuemetric"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultFlushInterval = 10 * time.Second
+	DefaultBatchSize     = 1000
+
+	maxPacketSize = 65535
+)
+
+type metricsStore interface {
+	SetValueSModel(ctx context.Context, valModel []models.Metrics) ([]models.Metrics, error)
+}
+
+// Server - UDP listener, aggregates samples and flushes them to store in batches
+type Server struct {
+	store    metricsStore
+	conn     net.PacketConn
+	l        *zap.Logger
+	pending  map[string]*models.Metrics
+	order    []string
+	mux      sync.Mutex
+	wg       sync.WaitGroup
+	flush    time.Duration
+	maxBatch int
+	stop     chan struct{}
+}
+
+// NewServer - listen UDP on addr
+func NewServer(store metricsStore, addr string, flush time.Duration, maxBatch int, l *zap.Logger) (*Server, error) {
+	if flush <= 0 {
+		flush = DefaultFlushInterval
+	}
+	if maxBatch <= 0 {
+		maxBatch = DefaultBatchSize
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		store:    store,
+		conn:     conn,
+		l:        l,
+		pending:  make(map[string]*models.Metrics),
+		flush:    flush,
+		maxBatch: maxBatch,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// Addr - local address of listener
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Run - start reading packets and periodic flush
+func (s *Server) Run(ctx context.Context) {
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.readLoop(ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.flush)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.Flush(ctx)
+			}
+		}
+	}()
+}
+
+// Stop - close listener and flush rest of samples
+func (s *Server) Stop() {
+	close(s.stop)
+	_ = s.conn.Close()
+	s.wg.Wait()
+	s.Flush(context.Background())
+}
+
+func (s *Server) readLoop(ctx context.Context) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.l.Debug("statsd read error", zap.Error(err))
+			continue
+		}
+		if s.Add(string(buf[:n])) {
+			s.Flush(ctx)
+		}
+	}
+}
+
+// Add - parse packet of newline separated lines, returns true when batch is full
+func (s *Server) Add(packet string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sample, err := ParseLine(line)
+		if err != nil {
+			s.l.Debug("statsd bad line", zap.String("line", line), zap.Error(err))
+			continue
+		}
+		if err = s.aggregate(sample); err != nil {
+			s.l.Debug("statsd bad metric", zap.String("line", line), zap.Error(err))
+		}
+	}
+	return len(s.pending) >= s.maxBatch
+}
+
+// aggregate - counters are summed, last gauge wins, timers are observed into histogram
+func (s *Server) aggregate(sample Sample) error {
+	key, err := models.SeriesKey(sample.Name, sample.Labels)
+	if err != nil {
+		return err
+	}
+	key = sample.Type + "|" + key
+	m, ok := s.pending[key]
+	if !ok {
+		m = &models.Metrics{ID: sample.Name, Labels: sample.Labels}
+		switch sample.Type {
+		case typeCounter:
+			m.MType = "counter"
+			m.Delta = new(int64)
+		case typeGauge:
+			m.MType = "gauge"
+			m.Value = new(float64)
+		default:
+			m.MType = "histogram"
+			m.Histogram = valuemetric.NewHistogram(DefaultTimerBounds)
+		}
+		s.pending[key] = m
+		s.order = append(s.order, key)
+	}
+
+	switch sample.Type {
+	case typeCounter:
+		*m.Delta += int64(math.Round(sample.Value / sample.Rate))
+	case typeGauge:
+		*m.Value = sample.Value
+	default:
+		m.Histogram.ObserveN(sample.Value, sample.count())
+	}
+	return nil
+}
+
+// Flush - write aggregated samples to store
+func (s *Server) Flush(ctx context.Context) {
+	s.mux.Lock()
+	if len(s.order) == 0 {
+		s.mux.Unlock()
+		return
+	}
+	batch := make([]models.Metrics, 0, len(s.order))
+	for _, key := range s.order {
+		batch = append(batch, *s.pending[key])
+	}
+	s.pending = make(map[string]*models.Metrics)
+	s.order = nil
+	s.mux.Unlock()
+
+	if _, err := s.store.SetValueSModel(ctx, batch); err != nil {
+		s.l.Error("statsd flush failed", zap.Int("len", len(batch)), zap.Error(err))
+		return
+	}
+	s.l.Debug("statsd flush", zap.Int("len", len(batch)))
+}
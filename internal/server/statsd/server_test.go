@@ -0,0 +1,102 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4aleksei/metricscum/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeStore struct {
+	batches [][]models.Metrics
+	mux     sync.Mutex
+}
+
+func (f *fakeStore) SetValueSModel(ctx context.Context, valModel []models.Metrics) ([]models.Metrics, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.batches = append(f.batches, valModel)
+	return valModel, nil
+}
+
+func (f *fakeStore) len() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return len(f.batches)
+}
+
+func Test_ServerAggregate(t *testing.T) {
+	store := &fakeStore{}
+	s, err := NewServer(store, "127.0.0.1:0", time.Hour, 0, zap.NewNop())
+	require.NoError(t, err)
+	defer s.Stop()
+
+	s.Add("requests:1|c\nrequests:2|c|@0.5\ntemp:1|g\ntemp:3.5|g\nbroken\nlatency:7|ms|@0.5\nrequests:1|c|#env:prod")
+	s.Flush(context.Background())
+
+	require.Equal(t, 1, store.len())
+	batch := store.batches[0]
+	require.Len(t, batch, 4)
+
+	assert.Equal(t, "requests", batch[0].ID)
+	assert.Equal(t, "counter", batch[0].MType)
+	assert.Equal(t, int64(5), *batch[0].Delta)
+
+	assert.Equal(t, "gauge", batch[1].MType)
+	assert.Equal(t, 3.5, *batch[1].Value)
+
+	assert.Equal(t, "histogram", batch[2].MType)
+	assert.Equal(t, uint64(2), batch[2].Histogram.Count)
+	assert.Equal(t, 14.0, batch[2].Histogram.Sum)
+	assert.NoError(t, batch[2].Histogram.Validate())
+
+	assert.Equal(t, map[string]string{"env": "prod"}, batch[3].Labels)
+	assert.Equal(t, int64(1), *batch[3].Delta)
+
+	s.Flush(context.Background())
+	assert.Equal(t, 1, store.len(), "empty batch is not flushed")
+}
+
+func Test_ServerSampledHistogram(t *testing.T) {
+	store := &fakeStore{}
+	s, err := NewServer(store, "127.0.0.1:0", time.Hour, 0, zap.NewNop())
+	require.NoError(t, err)
+	defer s.Stop()
+
+	s.Add("latency:1|h|@1e-12\nlatency:3|ms|@0.001")
+	s.Flush(context.Background())
+
+	require.Equal(t, 1, store.len())
+	batch := store.batches[0]
+	require.Len(t, batch, 1, "tiny rate is rejected")
+	assert.Equal(t, uint64(1000), batch[0].Histogram.Count)
+	assert.Equal(t, 3000.0, batch[0].Histogram.Sum)
+	assert.NoError(t, batch[0].Histogram.Validate())
+}
+
+func Test_ServerUDP(t *testing.T) {
+	store := &fakeStore{}
+	s, err := NewServer(store, "127.0.0.1:0", time.Hour, 2, zap.NewNop())
+	require.NoError(t, err)
+	s.Run(context.Background())
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("a:1|c\nb:2|g"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return store.len() == 1 }, time.Second, 10*time.Millisecond, "full batch is flushed")
+
+	_, err = conn.Write([]byte("c:1|c"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+	assert.Equal(t, 2, store.len(), "rest is flushed on stop")
+}